@@ -0,0 +1,77 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ImportStatus is the state of an import job
+type ImportStatus string
+
+const (
+	ImportPending   ImportStatus = "pending"
+	ImportRunning   ImportStatus = "running"
+	ImportSucceeded ImportStatus = "succeeded"
+	ImportFailed    ImportStatus = "failed"
+)
+
+// ImportRowStatus is the outcome of a row of an imported file
+type ImportRowStatus string
+
+const (
+	// ImportRowValid is a row a dry run would create
+	ImportRowValid ImportRowStatus = "valid"
+	// ImportRowCreated is a row created as a todo
+	ImportRowCreated ImportRowStatus = "created"
+	// ImportRowDuplicate is a row whose external ID was already imported
+	ImportRowDuplicate ImportRowStatus = "duplicate"
+	// ImportRowInvalid is a row violating validation rules
+	ImportRowInvalid ImportRowStatus = "invalid"
+	// ImportRowFailed is a valid row that could not be created
+	ImportRowFailed ImportRowStatus = "failed"
+)
+
+// ImportJob represents the import of a file of todos and its outcome
+type ImportJob struct {
+	ID     uuid.UUID
+	Format string
+	DryRun bool
+	Status ImportStatus
+	// Total is the number of rows of the file, and Processed the number of rows with an outcome
+	Total      int
+	Processed  int
+	Valid      int
+	Created    int
+	Duplicates int
+	Invalid    int
+	Failed     int
+	// Rows lists the outcome of rows, in file order
+	Rows []ImportRowResult
+	// Error describes why a failed job stopped
+	Error     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// ImportRowResult represents the outcome of a row of an imported file
+type ImportRowResult struct {
+	// Row is the position of the row in the file, from 1
+	Row        int
+	ExternalID string
+	Status     ImportRowStatus
+	// TodoID is the created todo, or the todo a duplicate row was imported as
+	TodoID uuid.UUID
+	// Errors lists the validation rules an invalid row violates
+	Errors []ImportFieldError
+	// Reason describes why a valid row failed
+	Reason string
+}
+
+// ImportFieldError describes a field of an imported row violating a validation rule
+type ImportFieldError struct {
+	Field   string
+	Rule    string
+	Message string
+}
@@ -12,19 +12,68 @@ type Todo struct {
 	Title       string
 	Description string
 	Completed   bool
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	// ListID is the list the todo belongs to, empty when it is not in a list
+	ListID string
+	// ParentID is the todo this todo is a child of, uuid.Nil for top-level todos
+	ParentID uuid.UUID
+	// Due is when the todo is due, the zero time when it has no due date
+	Due time.Time
+	// Priority ranks the todo from 1 (highest) to 9 (lowest) as in RFC 5545, 0 when undefined
+	Priority int
+	// ExternalID identifies the todo in the tool it was imported from, empty when not imported
+	ExternalID string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
 }
 
 // TodoCreate represents the data needed to create a new todo
 type TodoCreate struct {
 	Title       string
 	Description string
+	Completed   bool
+	ListID      string
+	ParentID    uuid.UUID
+	Due         time.Time
+	Priority    int
 }
 
-// TodoUpdate represents the data needed to update an existing todo
+// TodoUpdate represents the data needed to update an existing todo.
+// Nil fields are left unchanged.
 type TodoUpdate struct {
+	Title       *string
+	Description *string
+	Completed   *bool
+	// ListID moves the todo to another list, or out of its list when empty
+	ListID *string
+	// ParentID moves the todo under another todo, or to the top level when uuid.Nil
+	ParentID *uuid.UUID
+	// Due sets the due date, or removes it when the zero time
+	Due      *time.Time
+	Priority *int
+}
+
+// TodoReplace represents the data needed to create or fully replace a todo
+type TodoReplace struct {
 	Title       string
 	Description string
 	Completed   bool
+	ListID      string
+	ParentID    uuid.UUID
+	Due         time.Time
+	Priority    int
+}
+
+// TodoFilter selects todos. Nil fields match every todo.
+type TodoFilter struct {
+	Completed *bool
+	// ListID selects the todos of a list, or the todos not in a list when empty
+	ListID *string
+	// ParentID selects the children of a todo, or top-level todos when uuid.Nil
+	ParentID *uuid.UUID
+}
+
+// TodoCounts represents the number of todos by state
+type TodoCounts struct {
+	Open      int
+	Completed int
 }
@@ -0,0 +1,30 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TodoEventType is the kind of write a todo event reports
+type TodoEventType string
+
+const (
+	// TodoCreated reports a todo that was created
+	TodoCreated TodoEventType = "todo.created"
+	// TodoUpdated reports a todo that was updated or replaced
+	TodoUpdated TodoEventType = "todo.updated"
+	// TodoDeleted reports a todo that was deleted
+	TodoDeleted TodoEventType = "todo.deleted"
+)
+
+// TodoEvent reports a successful write of a todo
+type TodoEvent struct {
+	// ID orders the events of a tenant, assigned when the event is published
+	ID     uint64
+	Type   TodoEventType
+	TodoID uuid.UUID
+	// Todo is the todo as written, nil for deletions
+	Todo       *Todo
+	OccurredAt time.Time
+}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+)
+
+// IdempotencyRepository defines the interface for idempotency record data access.
+// Every method is scoped to the tenant bound to ctx.
+type IdempotencyRepository interface {
+	// Reserve stores record unless an unexpired record exists for the same key,
+	// in which case the existing record is returned instead.
+	Reserve(ctx context.Context, record *entity.IdempotencyRecord) (*entity.IdempotencyRecord, error)
+	// Complete stores the response of a reserved record
+	Complete(ctx context.Context, record *entity.IdempotencyRecord) error
+	// Release removes a reservation so the request can be retried
+	Release(ctx context.Context, key string) error
+}
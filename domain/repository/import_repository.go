@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+)
+
+// ImportJobRepository defines the interface for import job persistence.
+// Every method is scoped to the tenant bound to ctx.
+type ImportJobRepository interface {
+	// Save creates or overwrites a job
+	Save(ctx context.Context, job *entity.ImportJob) error
+	// FindByID returns nil when the job does not exist or has expired
+	FindByID(ctx context.Context, id uuid.UUID) (*entity.ImportJob, error)
+}
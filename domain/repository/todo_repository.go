@@ -1,18 +1,86 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"time"
+
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/google/uuid"
 	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
 )
 
-// TodoRepository defines the interface for todo data access
+// TodoRepository defines the interface for todo data access.
+// Every method is scoped to the tenant bound to ctx.
+//
+// The number of todos of the tenant is maintained with every write creating or deleting
+// todos. Writes given a quota fail with ErrQuotaExceeded when the tenant would own more
+// todos than quota afterwards; a zero quota means unlimited.
 type TodoRepository interface {
-	Create(todo *entity.Todo) (*entity.Todo, error)
-	FindAll() ([]*entity.Todo, error)
-	FindByID(id uuid.UUID) (*entity.Todo, error)
-	Update(todo *entity.Todo) (*entity.Todo, error)
-	Delete(id uuid.UUID) error
+	// Create stores a todo that must not exist yet
+	Create(ctx context.Context, todo *entity.Todo, quota int) (*entity.Todo, error)
+	FindAll(ctx context.Context, filter entity.TodoFilter) ([]*entity.Todo, error)
+	// ForEachPage calls fn with each page of the todos selected by filter, as they are read,
+	// and stops at the first error returned by fn
+	ForEachPage(ctx context.Context, filter entity.TodoFilter, fn func(todos []*entity.Todo) error) error
+	FindByID(ctx context.Context, id uuid.UUID) (*entity.Todo, error)
+	Update(ctx context.Context, todo *entity.Todo) (*entity.Todo, error)
+	// Replace creates the todo or replaces the existing one with the same ID, keeping its
+	// creation time. It reports whether the todo was created.
+	Replace(ctx context.Context, todo *entity.Todo, quota int) (*entity.Todo, bool, error)
+	// Delete removes the todo, reporting whether it existed
+	Delete(ctx context.Context, id uuid.UUID) (bool, error)
+	Count(ctx context.Context) (int, error)
+	// FindByIDs retrieves the todos with the given IDs. Todos that do not exist are left out of the map.
+	FindByIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*entity.Todo, error)
+	// BatchWrite applies the writes independently. Creates require the todo not to exist,
+	// updates require it to be unchanged since it was read, and deletes fail with ErrNotFound
+	// when it does not exist. It returns the error of each write, nil for the writes that were applied.
+	BatchWrite(ctx context.Context, writes []TodoWrite, quota int) ([]error, error)
+	// NewUnitOfWork starts collecting writes to commit atomically
+	NewUnitOfWork() UnitOfWork
 	GetClient() *dynamodb.Client
 	GetTableName() string
 }
+
+var (
+	// ErrWriteUnprocessed is returned for writes that were throttled, still after retrying for batches
+	ErrWriteUnprocessed = errors.New("write was not processed")
+	// ErrQuotaExceeded is returned when a write would make the tenant own more todos than its quota
+	ErrQuotaExceeded = errors.New("todo quota exceeded")
+	// ErrNotFound is returned when a deleted todo does not exist
+	ErrNotFound = errors.New("todo not found")
+	// ErrDuplicateExternalID is returned when a created todo has the external ID of another todo of the tenant
+	ErrDuplicateExternalID = errors.New("external ID already imported")
+)
+
+// WriteKind is the kind of a write of a batch
+type WriteKind int
+
+const (
+	// WriteCreate stores a todo that must not exist yet
+	WriteCreate WriteKind = iota
+	// WriteUpdate stores a todo that must already exist
+	WriteUpdate
+	// WriteDelete removes a todo that must exist
+	WriteDelete
+)
+
+// TodoWrite is a single write of a batch
+type TodoWrite struct {
+	Kind WriteKind
+	// Todo is the todo stored by creates and updates
+	Todo *entity.Todo
+	// ID is the todo removed by deletes
+	ID uuid.UUID
+	// ReadUpdatedAt is the update time of the todo of an update when it was read
+	ReadUpdatedAt time.Time
+}
+
+// TargetID returns the ID of the todo the write applies to
+func (w TodoWrite) TargetID() uuid.UUID {
+	if w.Kind == WriteDelete {
+		return w.ID
+	}
+	return w.Todo.ID
+}
@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+)
+
+// TodoStatisticsRepository defines the interface for aggregate todo statistics.
+// Unlike TodoRepository it spans every tenant and exposes aggregate counts only.
+type TodoStatisticsRepository interface {
+	// Count returns the number of todos by state across every tenant
+	Count(ctx context.Context) (entity.TodoCounts, error)
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+)
+
+// MaxUnitOfWorkWrites is the maximum number of writes of a unit of work. One less fits when
+// the writes change the number of todos, whose count is updated in the same transaction.
+const MaxUnitOfWorkWrites = 100
+
+var (
+	// ErrAlreadyExists is returned when a created todo already exists
+	ErrAlreadyExists = errors.New("todo already exists")
+	// ErrConcurrentModification is returned when an updated todo was changed or deleted since it was read
+	ErrConcurrentModification = errors.New("todo was modified concurrently")
+	// ErrTransactionConflict is returned when another request wrote the same todos at the same time
+	ErrTransactionConflict = errors.New("transaction conflicted with another request")
+	// ErrTooManyWrites is returned when a unit of work holds too many writes to be committed at once
+	ErrTooManyWrites = errors.New("too many writes for a single transaction")
+)
+
+// UnitOfWork collects todo writes that are committed atomically: either all of them
+// are applied or none is. Writes apply to the tenant bound to the context of Commit.
+type UnitOfWork interface {
+	// Create adds a write storing a todo that must not exist yet
+	Create(todo *entity.Todo)
+	// Update adds a write storing a todo that must be unchanged since it was read,
+	// readUpdatedAt being its update time at that moment
+	Update(todo *entity.Todo, readUpdatedAt time.Time)
+	// Delete adds a write removing a todo that must exist
+	Delete(id uuid.UUID)
+	// DeleteUnchanged adds a write removing a todo that must be unchanged since it was read,
+	// readUpdatedAt being its update time at that moment
+	DeleteUnchanged(id uuid.UUID, readUpdatedAt time.Time)
+	// LimitTodos fails the commit with ErrQuotaExceeded when the tenant would own more than
+	// quota todos afterwards. Zero, the default, means unlimited.
+	LimitTodos(quota int)
+	// Len returns the number of writes added
+	Len() int
+	// Commit applies the writes. When the transaction is cancelled, the returned
+	// *TransactionError wraps the domain error of the write that caused it, except for
+	// ErrQuotaExceeded which is returned as is.
+	Commit(ctx context.Context) error
+}
+
+// TransactionError is returned when a write cancelled a unit of work
+type TransactionError struct {
+	// Index is the position of the write that cancelled the transaction, in the order writes were added
+	Index int
+	// Err is the domain error of the write, such as ErrConcurrentModification
+	Err error
+}
+
+func (e *TransactionError) Error() string {
+	return fmt.Sprintf("transaction cancelled by write %d: %v", e.Index, e.Err)
+}
+
+func (e *TransactionError) Unwrap() error {
+	return e.Err
+}
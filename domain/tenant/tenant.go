@@ -0,0 +1,42 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"regexp"
+)
+
+// ID identifies the tenant that owns a set of todos
+type ID string
+
+// ErrMissing is returned when an operation requires a tenant but none is bound to the context
+var ErrMissing = errors.New("tenant is not resolved")
+
+// ErrInvalid is returned when a tenant identifier does not match the allowed format
+var ErrInvalid = errors.New("invalid tenant identifier")
+
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+type contextKey struct{}
+
+// Parse validates raw and converts it to an ID
+func Parse(raw string) (ID, error) {
+	if !idPattern.MatchString(raw) {
+		return "", ErrInvalid
+	}
+	return ID(raw), nil
+}
+
+// NewContext returns a copy of ctx bound to the given tenant
+func NewContext(ctx context.Context, id ID) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the tenant bound to ctx
+func FromContext(ctx context.Context) (ID, error) {
+	id, ok := ctx.Value(contextKey{}).(ID)
+	if !ok || id == "" {
+		return "", ErrMissing
+	}
+	return id, nil
+}
@@ -1,15 +1,131 @@
 package config
 
 import (
+	"errors"
 	"fmt"
-	"os"
+	"maps"
+	"net"
+	"net/url"
+	pathpkg "path"
+	"slices"
+	"strings"
 	"time"
 )
 
 // Config represents the application configuration
 type Config struct {
-	DynamoDB        DynamoDBConfig `yaml:"dynamodb"`
-	ShutdownTimeout string         `yaml:"shutdown_timeout"`
+	Server      ServerConfig      `yaml:"server"`
+	CORS        CORSConfig        `yaml:"cors"`
+	DynamoDB    DynamoDBConfig    `yaml:"dynamodb"`
+	Tenant      TenantConfig      `yaml:"tenant"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit"`
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	Import      ImportConfig      `yaml:"import"`
+	Calendar    CalendarConfig    `yaml:"calendar"`
+	Events      EventsConfig      `yaml:"events"`
+	Logging     LoggingConfig     `yaml:"logging"`
+	Metrics     MetricsConfig     `yaml:"metrics"`
+	Health      HealthConfig      `yaml:"health"`
+	Admin       AdminConfig       `yaml:"admin"`
+	// Features enables optional features by name: batch serves POST /todos:batch
+	Features        map[string]bool `yaml:"features"`
+	ShutdownTimeout time.Duration   `yaml:"shutdown_timeout"`
+	// DrainDelay is how long the server keeps serving after failing readiness on shutdown,
+	// giving load balancers time to stop routing new requests to it
+	DrainDelay time.Duration `yaml:"drain_delay"`
+}
+
+// ServerConfig represents HTTP server configuration
+type ServerConfig struct {
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
+	// UnixSocket is the path of a Unix domain socket to listen on instead of Host and Port
+	UnixSocket        string        `yaml:"unix_socket"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	MaxHeaderBytes    int           `yaml:"max_header_bytes"`
+	MaxBodyBytes      int64         `yaml:"max_body_bytes"`
+	// HTTP2 enables HTTP/2 over TLS
+	HTTP2 bool `yaml:"http2"`
+	// H2C enables HTTP/2 over cleartext connections, for deployments behind a proxy terminating TLS
+	H2C bool `yaml:"h2c"`
+	// TrustedProxies lists the IPs and CIDRs of the proxies whose X-Forwarded-For header is
+	// trusted to report the client IP; the header is ignored when it is empty
+	TrustedProxies []string  `yaml:"trusted_proxies"`
+	TLS            TLSConfig `yaml:"tls"`
+}
+
+// TLSConfig represents TLS configuration. TLS is enabled when CertFile and KeyFile are set.
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// ReloadInterval is how often the certificate files are checked for changes
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+	// ClientCAFile enables mutual TLS with client certificates issued by these CAs
+	ClientCAFile string `yaml:"client_ca_file"`
+	// ClientAuth is none, request, verify_if_given or require
+	ClientAuth string `yaml:"client_auth"`
+	// MinVersion is 1.2 or 1.3
+	MinVersion string `yaml:"min_version"`
+}
+
+// Enabled reports whether the server serves TLS
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" && c.KeyFile != ""
+}
+
+// CORSConfig represents the cross-origin resource sharing policy
+type CORSConfig struct {
+	// AllowOrigins lists the allowed origins. An entry may contain one wildcard for
+	// subdomains, e.g. https://*.example.com, and "*" allows every origin without credentials.
+	// No origin disables CORS.
+	AllowOrigins     []string      `yaml:"allow_origins"`
+	AllowMethods     []string      `yaml:"allow_methods"`
+	AllowHeaders     []string      `yaml:"allow_headers"`
+	ExposeHeaders    []string      `yaml:"expose_headers"`
+	AllowCredentials bool          `yaml:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age"`
+	// Routes overrides the policy for the request paths matching a pattern, first match wins
+	Routes []CORSRoute `yaml:"routes"`
+}
+
+// CORSRoute overrides the CORS policy for request paths matching Path, a path.Match pattern.
+// Unset fields inherit the top-level policy.
+type CORSRoute struct {
+	Path             string        `yaml:"path"`
+	AllowOrigins     []string      `yaml:"allow_origins"`
+	AllowMethods     []string      `yaml:"allow_methods"`
+	AllowHeaders     []string      `yaml:"allow_headers"`
+	ExposeHeaders    []string      `yaml:"expose_headers"`
+	AllowCredentials *bool         `yaml:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age"`
+}
+
+// PolicyFor returns the policy applying to route, with unset fields inherited from c
+func (c CORSConfig) PolicyFor(route CORSRoute) CORSConfig {
+	policy := c
+	policy.Routes = nil
+	if route.AllowOrigins != nil {
+		policy.AllowOrigins = route.AllowOrigins
+	}
+	if route.AllowMethods != nil {
+		policy.AllowMethods = route.AllowMethods
+	}
+	if route.AllowHeaders != nil {
+		policy.AllowHeaders = route.AllowHeaders
+	}
+	if route.ExposeHeaders != nil {
+		policy.ExposeHeaders = route.ExposeHeaders
+	}
+	if route.AllowCredentials != nil {
+		policy.AllowCredentials = *route.AllowCredentials
+	}
+	if route.MaxAge != 0 {
+		policy.MaxAge = route.MaxAge
+	}
+	return policy
 }
 
 // DynamoDBConfig represents DynamoDB specific configuration
@@ -17,44 +133,447 @@ type DynamoDBConfig struct {
 	Endpoint  string `yaml:"endpoint"`
 	Region    string `yaml:"region"`
 	TableName string `yaml:"table_name"`
-	Timeout   string `yaml:"timeout"`
+	// ConstraintTableName is the DynamoDB table storing the todo count and imported external IDs of every tenant
+	ConstraintTableName string        `yaml:"constraint_table_name"`
+	Timeout             time.Duration `yaml:"timeout"`
+}
+
+// TenantConfig represents multi-tenancy configuration
+type TenantConfig struct {
+	// Resolvers lists the sources the tenant is read from: header, subdomain and claim
+	Resolvers []string `yaml:"resolvers"`
+	// TrustedGateway declares that requests only reach the service through a gateway that
+	// authenticates them, sets the tenant header and verifies bearer tokens. The header and
+	// claim resolvers require it, as neither is verified by the service.
+	TrustedGateway bool           `yaml:"trusted_gateway"`
+	Header         string         `yaml:"header"`
+	Claim          string         `yaml:"claim"`
+	BaseDomain     string         `yaml:"base_domain"`
+	MaxTodos       int            `yaml:"max_todos"`
+	Quotas         map[string]int `yaml:"quotas"`
+}
+
+// RateLimitConfig represents rate limiting configuration
+type RateLimitConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// KeyBy lists the client identities tried in order: api_key, user and ip. The API key
+	// and the user are only authenticated by a gateway, so they require tenant.trusted_gateway.
+	KeyBy        []string      `yaml:"key_by"`
+	APIKeyHeader string        `yaml:"api_key_header"`
+	UserClaim    string        `yaml:"user_claim"`
+	Default      RateLimitRule `yaml:"default"`
+	// MaxClients bounds the buckets kept in memory; clients beyond it share a bucket per route
+	MaxClients int `yaml:"max_clients"`
+	// Routes overrides the default rule per route, keyed by "METHOD /route/template"
+	Routes map[string]RateLimitRule `yaml:"routes"`
+}
+
+// RateLimitRule represents a token bucket refilled at RequestsPerSecond and holding up to Burst tokens
+type RateLimitRule struct {
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+	Burst             int     `yaml:"burst"`
+}
+
+// RuleFor returns the rule applying to the given route
+func (c RateLimitConfig) RuleFor(route string) RateLimitRule {
+	if rule, ok := c.Routes[route]; ok {
+		return rule
+	}
+	return c.Default
+}
+
+// IdempotencyConfig represents Idempotency-Key handling configuration
+type IdempotencyConfig struct {
+	TableName string `yaml:"table_name"`
+	// TTL is how long a completed response is replayed
+	TTL time.Duration `yaml:"ttl"`
+	// LockTimeout is how long an in-flight request holds its key
+	LockTimeout time.Duration `yaml:"lock_timeout"`
+}
+
+// ImportConfig represents todo import configuration
+type ImportConfig struct {
+	// TableName is the DynamoDB table storing import jobs
+	TableName string `yaml:"table_name"`
+	// TTL is how long the outcome of an import job can be retrieved
+	TTL time.Duration `yaml:"ttl"`
+	// SyncRows is the number of rows above which a file is imported by a background job
+	SyncRows int `yaml:"sync_rows"`
+}
+
+// CalendarConfig represents the configuration of the calendar feed
+type CalendarConfig struct {
+	// TokenSecret signs the tokens of subscription URLs, which are disabled when it is empty
+	TokenSecret string `yaml:"token_secret" secret:"true"`
+	// TokenTTL is how long subscription URLs are valid, forever when zero
+	TokenTTL time.Duration `yaml:"token_ttl"`
+	// CalDAVTokenTTL is how long CalDAV passwords are valid, forever when zero
+	CalDAVTokenTTL time.Duration `yaml:"caldav_token_ttl"`
+	// BaseURL is the public URL of the API prefixing subscription URLs, the request host when empty
+	BaseURL string `yaml:"base_url"`
+}
+
+// EventsConfig represents the configuration of the todo event stream
+type EventsConfig struct {
+	// ReplayBuffer is the number of latest events of each tenant kept for clients resuming with Last-Event-ID
+	ReplayBuffer int `yaml:"replay_buffer"`
+	// ReplayTTL is how long the events of a tenant without subscribers are kept after its last event
+	ReplayTTL time.Duration `yaml:"replay_ttl"`
+	// HeartbeatInterval is how often an idle stream sends a comment, keeping proxies from closing it
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
+}
+
+// LoggingConfig represents request logging configuration
+type LoggingConfig struct {
+	// Level is the minimum level logged: debug, info, warn or error
+	Level string `yaml:"level"`
+	// UserClaim is the bearer token claim identifying the user in request logs
+	UserClaim         string   `yaml:"user_claim"`
+	RedactHeaders     []string `yaml:"redact_headers"`
+	RedactQueryParams []string `yaml:"redact_query_params"`
+	RedactBodyFields  []string `yaml:"redact_body_fields"`
+}
+
+// MetricsConfig represents Prometheus metrics configuration
+type MetricsConfig struct {
+	// TodoRefreshInterval is how often the todo count gauges are recomputed by scanning
+	// the whole table, zero to disable them
+	TodoRefreshInterval time.Duration `yaml:"todo_refresh_interval"`
 }
 
-func LoadConfig() (*Config, error) {
-	// Default configuration
-	config := &Config{
+// HealthConfig represents readiness probe configuration
+type HealthConfig struct {
+	// CacheTTL is how long a dependency check result is reused
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+	// FailureThreshold is the number of consecutive failed checks before the application is not ready
+	FailureThreshold int `yaml:"failure_threshold"`
+}
+
+// AdminConfig represents the configuration of the admin endpoints
+type AdminConfig struct {
+	// Token is the bearer token required by the admin endpoints, which are disabled when it is empty
+	Token string `yaml:"token" secret:"true"`
+}
+
+// MaxTodosFor returns the todo quota of the given tenant. Zero means unlimited.
+func (c TenantConfig) MaxTodosFor(tenantID string) int {
+	if quota, ok := c.Quotas[tenantID]; ok {
+		return quota
+	}
+	return c.MaxTodos
+}
+
+// Default returns the configuration used when nothing overrides it
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:              8080,
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			MaxHeaderBytes:    1 << 20,
+			MaxBodyBytes:      10 << 20,
+			HTTP2:             true,
+			TrustedProxies:    []string{},
+			TLS: TLSConfig{
+				ReloadInterval: time.Minute,
+				ClientAuth:     "none",
+				MinVersion:     "1.2",
+			},
+		},
+		CORS: CORSConfig{
+			// Swagger UI started by compose.yaml
+			AllowOrigins: []string{"http://localhost:8081"},
+			AllowMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+			AllowHeaders: []string{
+				"Origin", "Content-Type", "Accept", "Authorization", "Idempotency-Key", "Prefer",
+				"If-Match", "If-None-Match", "X-Request-ID", "traceparent", "tracestate",
+			},
+			ExposeHeaders: []string{
+				"Content-Length", "Location", "ETag", "X-Request-ID", "Retry-After",
+				"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset",
+				"Idempotent-Replayed", "Preference-Applied",
+			},
+			AllowCredentials: true,
+			MaxAge:           12 * time.Hour,
+		},
 		DynamoDB: DynamoDBConfig{
-			Region:    "ap-northeast-1",
-			TableName: "goto-dev-todo",
-			Timeout:   "1s",
+			Region:              "ap-northeast-1",
+			TableName:           "goto-dev-todo",
+			ConstraintTableName: "goto-dev-todo-constraints",
+			Timeout:             time.Second,
+		},
+		Tenant: TenantConfig{
+			Resolvers: []string{"header"},
+			Header:    "X-Tenant-ID",
+			Claim:     "tenant_id",
+			Quotas:    map[string]int{},
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:      true,
+			KeyBy:        []string{"ip"},
+			APIKeyHeader: "X-API-Key",
+			UserClaim:    "sub",
+			Default: RateLimitRule{
+				RequestsPerSecond: 10,
+				Burst:             20,
+			},
+			Routes:     map[string]RateLimitRule{},
+			MaxClients: 100000,
+		},
+		Idempotency: IdempotencyConfig{
+			TableName:   "goto-dev-todo-idempotency",
+			TTL:         24 * time.Hour,
+			LockTimeout: 30 * time.Second,
+		},
+		Import: ImportConfig{
+			TableName: "goto-dev-todo-imports",
+			TTL:       7 * 24 * time.Hour,
+			SyncRows:  1000,
 		},
-		ShutdownTimeout: "5s",
+		Calendar: CalendarConfig{
+			CalDAVTokenTTL: 90 * 24 * time.Hour,
+		},
+		Events: EventsConfig{
+			ReplayBuffer:      1000,
+			ReplayTTL:         time.Hour,
+			HeartbeatInterval: 15 * time.Second,
+		},
+		Logging: LoggingConfig{
+			Level:             "info",
+			UserClaim:         "sub",
+			RedactHeaders:     []string{"Authorization", "Cookie", "Set-Cookie", "X-API-Key", "Proxy-Authorization"},
+			RedactQueryParams: []string{"token", "access_token", "id_token", "api_key", "key", "signature", "password"},
+			RedactBodyFields:  []string{"password", "token", "secret", "api_key"},
+		},
+		Metrics: MetricsConfig{
+			TodoRefreshInterval: time.Hour,
+		},
+		Health: HealthConfig{
+			CacheTTL:         5 * time.Second,
+			FailureThreshold: 3,
+		},
+		Features:        map[string]bool{"batch": true},
+		ShutdownTimeout: 5 * time.Second,
+		DrainDelay:      5 * time.Second,
+	}
+}
+
+// Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(path, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+	}
+
+	if c.Server.UnixSocket == "" && (c.Server.Port < 0 || c.Server.Port > 65535) {
+		invalid("server.port", "must be between 0 and 65535, got %d", c.Server.Port)
+	}
+	for _, timeout := range []struct {
+		path  string
+		value time.Duration
+	}{
+		{"server.read_timeout", c.Server.ReadTimeout},
+		{"server.read_header_timeout", c.Server.ReadHeaderTimeout},
+		{"server.write_timeout", c.Server.WriteTimeout},
+		{"server.idle_timeout", c.Server.IdleTimeout},
+	} {
+		if timeout.value <= 0 {
+			invalid(timeout.path, "must be positive, got %s", timeout.value)
+		}
+	}
+	if c.Server.MaxHeaderBytes <= 0 {
+		invalid("server.max_header_bytes", "must be positive, got %d", c.Server.MaxHeaderBytes)
+	}
+	if c.Server.MaxBodyBytes <= 0 {
+		invalid("server.max_body_bytes", "must be positive, got %d", c.Server.MaxBodyBytes)
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			invalid("server.trusted_proxies", "must be IPs or CIDRs, got %q", proxy)
+		}
+	}
+	tls := c.Server.TLS
+	if (tls.CertFile == "") != (tls.KeyFile == "") {
+		invalid("server.tls", "cert_file and key_file must be set together")
+	}
+	if tls.Enabled() && tls.ReloadInterval <= 0 {
+		invalid("server.tls.reload_interval", "must be positive, got %s", tls.ReloadInterval)
+	}
+	switch tls.ClientAuth {
+	case "none":
+	case "request", "verify_if_given", "require":
+		if tls.ClientCAFile == "" && tls.ClientAuth != "request" {
+			invalid("server.tls.client_ca_file", "must be set when client_auth is %s", tls.ClientAuth)
+		}
+		if !tls.Enabled() {
+			invalid("server.tls.client_auth", "requires cert_file and key_file")
+		}
+	default:
+		invalid("server.tls.client_auth", "unknown mode %q, want none, request, verify_if_given or require", tls.ClientAuth)
+	}
+	switch tls.MinVersion {
+	case "1.2", "1.3":
+	default:
+		invalid("server.tls.min_version", "unknown version %q, want 1.2 or 1.3", tls.MinVersion)
+	}
+
+	validateCORS := func(path string, policy CORSConfig) {
+		for _, origin := range policy.AllowOrigins {
+			switch {
+			case origin == "*":
+				if policy.AllowCredentials {
+					invalid(path+".allow_origins", `"*" cannot be combined with allow_credentials, list the origins instead`)
+				}
+			case strings.Count(origin, "*") > 1:
+				invalid(path+".allow_origins", "origin %q may contain only one wildcard", origin)
+			case !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://"):
+				invalid(path+".allow_origins", "origin %q must start with http:// or https://", origin)
+			}
+		}
+		if slices.Contains(policy.AllowOrigins, "*") && len(policy.AllowOrigins) > 1 {
+			invalid(path+".allow_origins", `"*" cannot be combined with other origins`)
+		}
+	}
+	validateCORS("cors", c.CORS)
+	for i, route := range c.CORS.Routes {
+		path := fmt.Sprintf("cors.routes[%d]", i)
+		if _, err := pathpkg.Match(route.Path, "/"); err != nil || route.Path == "" {
+			invalid(path+".path", "invalid pattern %q", route.Path)
+		}
+		validateCORS(path, c.CORS.PolicyFor(route))
+	}
+
+	if c.DynamoDB.Region == "" {
+		invalid("dynamodb.region", "must not be empty")
+	}
+	if c.DynamoDB.TableName == "" {
+		invalid("dynamodb.table_name", "must not be empty")
+	}
+	if c.DynamoDB.ConstraintTableName == "" {
+		invalid("dynamodb.constraint_table_name", "must not be empty")
+	}
+	if c.DynamoDB.Timeout <= 0 {
+		invalid("dynamodb.timeout", "must be positive, got %s", c.DynamoDB.Timeout)
+	}
+
+	if len(c.Tenant.Resolvers) == 0 {
+		invalid("tenant.resolvers", "must list at least one resolver")
+	}
+	for _, resolver := range c.Tenant.Resolvers {
+		switch resolver {
+		case "header", "claim":
+			if !c.Tenant.TrustedGateway {
+				invalid("tenant.resolvers", "resolver %q trusts what clients send and requires tenant.trusted_gateway", resolver)
+			}
+		case "subdomain":
+		default:
+			invalid("tenant.resolvers", "unknown resolver %q, want header, subdomain or claim", resolver)
+		}
+	}
+	if c.Tenant.MaxTodos < 0 {
+		invalid("tenant.max_todos", "must not be negative, got %d", c.Tenant.MaxTodos)
+	}
+	for _, tenantID := range slices.Sorted(maps.Keys(c.Tenant.Quotas)) {
+		if quota := c.Tenant.Quotas[tenantID]; quota < 0 {
+			invalid("tenant.quotas."+tenantID, "must not be negative, got %d", quota)
+		}
+	}
+
+	for _, keyBy := range c.RateLimit.KeyBy {
+		switch keyBy {
+		case "api_key", "user":
+			if !c.Tenant.TrustedGateway {
+				invalid("rate_limit.key_by", "identity %q is not authenticated by the service and requires tenant.trusted_gateway",
+					keyBy)
+			}
+		case "ip":
+		default:
+			invalid("rate_limit.key_by", "unknown identity %q, want api_key, user or ip", keyBy)
+		}
+	}
+	validateRule := func(path string, rule RateLimitRule) {
+		if rule.RequestsPerSecond < 0 || rule.Burst < 0 {
+			invalid(path, "must not be negative, got %g:%d", rule.RequestsPerSecond, rule.Burst)
+		}
+	}
+	validateRule("rate_limit.default", c.RateLimit.Default)
+	for _, route := range slices.Sorted(maps.Keys(c.RateLimit.Routes)) {
+		validateRule("rate_limit.routes."+route, c.RateLimit.Routes[route])
+	}
+	if c.RateLimit.MaxClients <= 0 {
+		invalid("rate_limit.max_clients", "must be positive, got %d", c.RateLimit.MaxClients)
 	}
 
-	// Override with environment variables if they exist
-	if endpoint := os.Getenv("DYNAMODB_ENDPOINT"); endpoint != "" {
-		config.DynamoDB.Endpoint = endpoint
+	if c.Idempotency.TableName == "" {
+		invalid("idempotency.table_name", "must not be empty")
 	}
-	if region := os.Getenv("AWS_REGION"); region != "" {
-		config.DynamoDB.Region = region
+	if c.Idempotency.TTL <= 0 {
+		invalid("idempotency.ttl", "must be positive, got %s", c.Idempotency.TTL)
 	}
-	if table := os.Getenv("DYNAMODB_TABLE"); table != "" {
-		config.DynamoDB.TableName = table
+	if c.Idempotency.LockTimeout <= 0 {
+		invalid("idempotency.lock_timeout", "must be positive, got %s", c.Idempotency.LockTimeout)
+	}
+
+	if c.Import.TableName == "" {
+		invalid("import.table_name", "must not be empty")
 	}
-	if timeout := os.Getenv("DYNAMODB_CONNECTION_TIMEOUT"); timeout != "" {
-		config.DynamoDB.Timeout = timeout
+	if c.Import.TTL <= 0 {
+		invalid("import.ttl", "must be positive, got %s", c.Import.TTL)
+	}
+	if c.Import.SyncRows < 0 {
+		invalid("import.sync_rows", "must not be negative, got %d", c.Import.SyncRows)
+	}
+
+	if c.Calendar.TokenSecret != "" && len(c.Calendar.TokenSecret) < 32 {
+		invalid("calendar.token_secret", "must be at least 32 bytes, got %d", len(c.Calendar.TokenSecret))
+	}
+	if c.Calendar.TokenTTL < 0 {
+		invalid("calendar.token_ttl", "must not be negative, got %s", c.Calendar.TokenTTL)
+	}
+	if c.Calendar.CalDAVTokenTTL < 0 {
+		invalid("calendar.caldav_token_ttl", "must not be negative, got %s", c.Calendar.CalDAVTokenTTL)
+	}
+	if c.Calendar.BaseURL != "" {
+		if u, err := url.Parse(c.Calendar.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("calendar.base_url", "must be an absolute http or https URL, got %q", c.Calendar.BaseURL)
+		}
+	}
+
+	if c.Events.ReplayBuffer <= 0 {
+		invalid("events.replay_buffer", "must be positive, got %d", c.Events.ReplayBuffer)
+	}
+	if c.Events.ReplayTTL <= 0 {
+		invalid("events.replay_ttl", "must be positive, got %s", c.Events.ReplayTTL)
+	}
+	if c.Events.HeartbeatInterval <= 0 {
+		invalid("events.heartbeat_interval", "must be positive, got %s", c.Events.HeartbeatInterval)
+	}
+
+	switch c.Logging.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		invalid("logging.level", "unknown level %q, want debug, info, warn or error", c.Logging.Level)
+	}
+
+	if c.Metrics.TodoRefreshInterval < 0 {
+		invalid("metrics.todo_refresh_interval", "must not be negative, got %s", c.Metrics.TodoRefreshInterval)
+	}
+
+	if c.Health.CacheTTL < 0 {
+		invalid("health.cache_ttl", "must not be negative, got %s", c.Health.CacheTTL)
 	}
-	if timeout := os.Getenv("SHUTDOWN_TIMEOUT"); timeout != "" {
-		config.ShutdownTimeout = timeout
+	if c.Health.FailureThreshold < 1 {
+		invalid("health.failure_threshold", "must be at least 1, got %d", c.Health.FailureThreshold)
 	}
 
-	// Validate durations
-	if _, err := time.ParseDuration(config.DynamoDB.Timeout); err != nil {
-		panic(fmt.Sprintf("Invalid format for DYNAMODB_CONNECTION_TIMEOUT: %v", err))
+	if c.ShutdownTimeout <= 0 {
+		invalid("shutdown_timeout", "must be positive, got %s", c.ShutdownTimeout)
 	}
-	if _, err := time.ParseDuration(config.ShutdownTimeout); err != nil {
-		panic(fmt.Sprintf("Invalid format for SHUTDOWN_TIMEOUT: %v", err))
+	if c.DrainDelay < 0 {
+		invalid("drain_delay", "must not be negative, got %s", c.DrainDelay)
 	}
 
-	return config, nil
+	return errors.Join(errs...)
 }
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envBindings maps environment variables to the configuration paths they override
+var envBindings = []struct {
+	name string
+	path string
+}{
+	{"SERVER_HOST", "server.host"},
+	{"PORT", "server.port"},
+	{"SERVER_UNIX_SOCKET", "server.unix_socket"},
+	{"SERVER_READ_TIMEOUT", "server.read_timeout"},
+	{"SERVER_READ_HEADER_TIMEOUT", "server.read_header_timeout"},
+	{"SERVER_WRITE_TIMEOUT", "server.write_timeout"},
+	{"SERVER_IDLE_TIMEOUT", "server.idle_timeout"},
+	{"SERVER_MAX_HEADER_BYTES", "server.max_header_bytes"},
+	{"SERVER_MAX_BODY_BYTES", "server.max_body_bytes"},
+	{"SERVER_HTTP2", "server.http2"},
+	{"SERVER_H2C", "server.h2c"},
+	{"SERVER_TRUSTED_PROXIES", "server.trusted_proxies"},
+	{"TLS_CERT_FILE", "server.tls.cert_file"},
+	{"TLS_KEY_FILE", "server.tls.key_file"},
+	{"TLS_RELOAD_INTERVAL", "server.tls.reload_interval"},
+	{"TLS_CLIENT_CA_FILE", "server.tls.client_ca_file"},
+	{"TLS_CLIENT_AUTH", "server.tls.client_auth"},
+	{"TLS_MIN_VERSION", "server.tls.min_version"},
+	{"CORS_ALLOW_ORIGINS", "cors.allow_origins"},
+	{"CORS_ALLOW_METHODS", "cors.allow_methods"},
+	{"CORS_ALLOW_HEADERS", "cors.allow_headers"},
+	{"CORS_EXPOSE_HEADERS", "cors.expose_headers"},
+	{"CORS_ALLOW_CREDENTIALS", "cors.allow_credentials"},
+	{"CORS_MAX_AGE", "cors.max_age"},
+	{"DYNAMODB_ENDPOINT", "dynamodb.endpoint"},
+	{"AWS_REGION", "dynamodb.region"},
+	{"DYNAMODB_TABLE", "dynamodb.table_name"},
+	{"DYNAMODB_CONSTRAINT_TABLE", "dynamodb.constraint_table_name"},
+	{"DYNAMODB_CONNECTION_TIMEOUT", "dynamodb.timeout"},
+	{"SHUTDOWN_TIMEOUT", "shutdown_timeout"},
+	{"SHUTDOWN_DRAIN_DELAY", "drain_delay"},
+	{"TENANT_RESOLVERS", "tenant.resolvers"},
+	{"TENANT_TRUSTED_GATEWAY", "tenant.trusted_gateway"},
+	{"TENANT_HEADER", "tenant.header"},
+	{"TENANT_CLAIM", "tenant.claim"},
+	{"TENANT_BASE_DOMAIN", "tenant.base_domain"},
+	{"TENANT_MAX_TODOS", "tenant.max_todos"},
+	{"TENANT_QUOTAS", "tenant.quotas"},
+	{"RATE_LIMIT_ENABLED", "rate_limit.enabled"},
+	{"RATE_LIMIT_KEY_BY", "rate_limit.key_by"},
+	{"RATE_LIMIT_API_KEY_HEADER", "rate_limit.api_key_header"},
+	{"RATE_LIMIT_USER_CLAIM", "rate_limit.user_claim"},
+	{"RATE_LIMIT_DEFAULT", "rate_limit.default"},
+	{"RATE_LIMIT_ROUTES", "rate_limit.routes"},
+	{"RATE_LIMIT_MAX_CLIENTS", "rate_limit.max_clients"},
+	{"IDEMPOTENCY_TABLE", "idempotency.table_name"},
+	{"IDEMPOTENCY_TTL", "idempotency.ttl"},
+	{"IDEMPOTENCY_LOCK_TIMEOUT", "idempotency.lock_timeout"},
+	{"IMPORT_TABLE", "import.table_name"},
+	{"IMPORT_TTL", "import.ttl"},
+	{"IMPORT_SYNC_ROWS", "import.sync_rows"},
+	{"CALENDAR_TOKEN_SECRET", "calendar.token_secret"},
+	{"CALENDAR_TOKEN_TTL", "calendar.token_ttl"},
+	{"CALENDAR_CALDAV_TOKEN_TTL", "calendar.caldav_token_ttl"},
+	{"CALENDAR_BASE_URL", "calendar.base_url"},
+	{"EVENTS_REPLAY_BUFFER", "events.replay_buffer"},
+	{"EVENTS_REPLAY_TTL", "events.replay_ttl"},
+	{"EVENTS_HEARTBEAT_INTERVAL", "events.heartbeat_interval"},
+	{"LOG_LEVEL", "logging.level"},
+	{"LOG_USER_CLAIM", "logging.user_claim"},
+	{"LOG_REDACT_HEADERS", "logging.redact_headers"},
+	{"LOG_REDACT_QUERY_PARAMS", "logging.redact_query_params"},
+	{"LOG_REDACT_BODY_FIELDS", "logging.redact_body_fields"},
+	{"METRICS_TODO_REFRESH_INTERVAL", "metrics.todo_refresh_interval"},
+	{"HEALTH_CACHE_TTL", "health.cache_ttl"},
+	{"HEALTH_FAILURE_THRESHOLD", "health.failure_threshold"},
+	{"ADMIN_TOKEN", "admin.token"},
+	{"FEATURES", "features"},
+}
+
+// Loader builds the configuration from, in increasing order of precedence,
+// the defaults, a YAML file, environment variables and command-line flags.
+// Every setting can be overridden by a flag named after its YAML path, e.g. --dynamodb.timeout=3s.
+type Loader struct {
+	args []string
+
+	// File is the YAML file read, set with --config or CONFIG_FILE. Empty means no file.
+	File string
+	// PrintConfig is set by --print-config
+	PrintConfig bool
+}
+
+// override is a value given on the command line for a configuration path
+type override struct {
+	path  string
+	value string
+}
+
+// NewLoader creates a Loader for the given command-line arguments, without the program name
+func NewLoader(args []string) *Loader {
+	return &Loader{args: args}
+}
+
+// Load reads every source and returns the validated configuration.
+// All invalid settings are reported together in the returned error.
+func (l *Loader) Load() (*Config, error) {
+	cfg := Default()
+
+	overrides, err := l.parseFlags()
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	if l.File != "" {
+		if err := readFile(cfg, l.File); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, binding := range envBindings {
+		if value := os.Getenv(binding.name); value != "" {
+			if err := setPath(cfg, binding.path, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", binding.name, err))
+			}
+		}
+	}
+	for _, o := range overrides {
+		if err := setPath(cfg, o.path, o.value); err != nil {
+			errs = append(errs, fmt.Errorf("--%s: %w", o.path, err))
+		}
+	}
+	// Settings that failed to parse keep their previous value, so validating the rest is still meaningful
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return cfg, nil
+}
+
+// parseFlags parses the command line, recording the setting overrides in the given order
+func (l *Loader) parseFlags() ([]override, error) {
+	fs := flag.NewFlagSet("todo-app", flag.ContinueOnError)
+	l.File = os.Getenv("CONFIG_FILE")
+	fs.StringVar(&l.File, "config", l.File, "YAML configuration `file`")
+	fs.BoolVar(&l.PrintConfig, "print-config", false, "print the effective configuration with secrets masked and exit")
+
+	var overrides []override
+	for _, path := range settingPaths(Default()) {
+		fs.Func(path, "overrides the "+path+" setting", func(value string) error {
+			overrides = append(overrides, override{path: path, value: value})
+			return nil
+		})
+	}
+
+	if err := fs.Parse(l.args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	return overrides, nil
+}
+
+// readFile decodes the YAML file over cfg, rejecting unknown keys
+func readFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
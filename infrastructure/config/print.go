@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// secretMask replaces the value of settings tagged secret:"true" when printed
+const secretMask = "********"
+
+// Print writes the configuration as YAML, in the layout accepted by --config,
+// with durations human readable and secrets masked
+func (c *Config) Print(w io.Writer) error {
+	node, err := toNode(reflect.ValueOf(c).Elem(), false)
+	if err != nil {
+		return err
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// toNode converts v into a YAML node, masking it when secret and set
+func toNode(v reflect.Value, secret bool) (*yaml.Node, error) {
+	if secret && !v.IsZero() {
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: secretMask}, nil
+	}
+	if v.Type() == durationType {
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprint(v.Interface())}, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for i := range v.NumField() {
+			field := v.Type().Field(i)
+			name := yamlName(field)
+			if name == "" {
+				continue
+			}
+			value, err := toNode(v.Field(i), field.Tag.Get("secret") == "true")
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, value)
+		}
+		return node, nil
+	case reflect.Map:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		keys := v.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int {
+			return strings.Compare(a.String(), b.String())
+		})
+		for _, key := range keys {
+			value, err := toNode(v.MapIndex(key), false)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key.String()}, value)
+		}
+		return node, nil
+	case reflect.Slice:
+		node := &yaml.Node{Kind: yaml.SequenceNode}
+		for i := range v.Len() {
+			value, err := toNode(v.Index(i), false)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, value)
+		}
+		return node, nil
+	default:
+		node := &yaml.Node{}
+		if err := node.Encode(v.Interface()); err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+}
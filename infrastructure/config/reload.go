@@ -0,0 +1,191 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// reloadablePaths lists the settings, or groups of settings, that take effect without a restart
+var reloadablePaths = []string{"logging.level", "rate_limit", "cors", "features"}
+
+// Change describes a setting whose value differs after a reload
+type Change struct {
+	Path string `json:"path"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+	// Applied is false for settings that only take effect after a restart
+	Applied bool `json:"applied"`
+}
+
+// Reloader reads the configuration again on demand and hands the reloadable
+// settings over to the subscribed components
+type Reloader struct {
+	loader      *Loader
+	logger      *zap.Logger
+	mu          sync.Mutex
+	current     *Config
+	subscribers []func(*Config)
+}
+
+// NewReloader creates a Reloader starting from the configuration current, as returned by loader
+func NewReloader(loader *Loader, current *Config, logger *zap.Logger) *Reloader {
+	return &Reloader{loader: loader, current: current, logger: logger}
+}
+
+// Subscribe registers fn to be called with the configuration after every successful reload
+func (r *Reloader) Subscribe(fn func(*Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscribers = append(r.subscribers, fn)
+}
+
+// Current returns the configuration in effect
+func (r *Reloader) Current() *Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Reload loads the configuration again and applies the reloadable settings.
+// An invalid configuration is rejected as a whole and the current one is kept.
+// Changes to other settings are reported but only take effect after a restart.
+func (r *Reloader) Reload() ([]Change, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	loaded, err := r.loader.Load()
+	if err != nil {
+		r.logger.Error("Rejected configuration reload", zap.Error(err))
+		return nil, err
+	}
+
+	changes := diff(r.current, loaded)
+	for _, change := range changes {
+		fields := []zap.Field{
+			zap.String("path", change.Path),
+			zap.String("old", change.Old),
+			zap.String("new", change.New),
+		}
+		if change.Applied {
+			r.logger.Info("Configuration changed", fields...)
+		} else {
+			r.logger.Warn("Configuration change requires a restart", fields...)
+		}
+	}
+
+	// Only the reloadable settings are taken from the new configuration
+	next := *r.current
+	next.Logging.Level = loaded.Logging.Level
+	next.RateLimit = loaded.RateLimit
+	next.CORS = loaded.CORS
+	next.Features = loaded.Features
+	r.current = &next
+
+	for _, fn := range r.subscribers {
+		fn(r.current)
+	}
+	r.logger.Info("Configuration reloaded", zap.Int("changes", len(changes)))
+	return changes, nil
+}
+
+// diff lists the settings whose values differ between old and new, sorted by path
+func diff(old, new *Config) []Change {
+	oldValues, newValues := flatten(old), flatten(new)
+
+	paths := slices.Collect(maps.Keys(oldValues))
+	for path := range newValues {
+		if _, ok := oldValues[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	slices.Sort(paths)
+
+	var changes []Change
+	for _, path := range paths {
+		old, new := oldValues[path], newValues[path]
+		if old == new {
+			continue
+		}
+		changes = append(changes, Change{
+			Path:    path,
+			Old:     old.String(),
+			New:     new.String(),
+			Applied: isReloadable(path),
+		})
+	}
+	return changes
+}
+
+// isReloadable reports whether the setting at path takes effect without a restart
+func isReloadable(path string) bool {
+	for _, prefix := range reloadablePaths {
+		if path == prefix || strings.HasPrefix(path, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// setting is the printed value of a single setting
+type setting struct {
+	value  string
+	secret bool
+}
+
+// String returns the value, masked when secret and set
+func (s setting) String() string {
+	if s.secret && s.value != "" {
+		return secretMask
+	}
+	return s.value
+}
+
+// flatten maps the dotted path of every setting of cfg to its printed value
+func flatten(cfg *Config) map[string]setting {
+	values := make(map[string]setting)
+	var walk func(v reflect.Value, path string, secret bool)
+	walk = func(v reflect.Value, path string, secret bool) {
+		switch {
+		case v.Kind() == reflect.Struct && !isLeaf(v.Type()):
+			for i := range v.NumField() {
+				field := v.Type().Field(i)
+				if name := yamlName(field); name != "" {
+					walk(v.Field(i), joinPath(path, name), field.Tag.Get("secret") == "true")
+				}
+			}
+		case v.Kind() == reflect.Map:
+			for _, key := range v.MapKeys() {
+				walk(v.MapIndex(key), joinPath(path, key.String()), false)
+			}
+		case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Struct:
+			values[joinPath(path, "length")] = setting{value: fmt.Sprint(v.Len())}
+			for i := range v.Len() {
+				walk(v.Index(i), joinPath(path, fmt.Sprint(i)), false)
+			}
+		case v.Kind() == reflect.Pointer:
+			if v.IsNil() {
+				values[path] = setting{secret: secret}
+			} else {
+				walk(v.Elem(), path, secret)
+			}
+		default:
+			values[path] = setting{value: fmt.Sprintf("%v", v.Interface()), secret: secret}
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem(), "", false)
+	return values
+}
+
+// joinPath appends name to the dotted path prefix
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
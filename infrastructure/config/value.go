@@ -0,0 +1,176 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// yamlName returns the YAML key of a struct field, or "" when the field is not serialized
+func yamlName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if name == "-" || !field.IsExported() {
+		return ""
+	}
+	if name == "" {
+		return strings.ToLower(field.Name)
+	}
+	return name
+}
+
+// isLeaf reports whether a value of type t is set as a whole rather than field by field
+func isLeaf(t reflect.Type) bool {
+	return t.Kind() != reflect.Struct || t == reflect.TypeOf(RateLimitRule{})
+}
+
+// isSettable reports whether a value of type t can be parsed from a single string.
+// Lists of structs, such as per-route overrides, can only be set in the YAML file.
+func isSettable(t reflect.Type) bool {
+	return t.Kind() != reflect.Slice || t.Elem().Kind() != reflect.Struct
+}
+
+// settingPaths lists the dotted YAML paths of every setting of cfg
+func settingPaths(cfg *Config) []string {
+	var paths []string
+	var walk func(t reflect.Type, prefix string)
+	walk = func(t reflect.Type, prefix string) {
+		for i := range t.NumField() {
+			field := t.Field(i)
+			name := yamlName(field)
+			if name == "" {
+				continue
+			}
+			if !isSettable(field.Type) {
+				continue
+			}
+			if isLeaf(field.Type) {
+				paths = append(paths, prefix+name)
+			} else {
+				walk(field.Type, prefix+name+".")
+			}
+		}
+	}
+	walk(reflect.TypeOf(cfg).Elem(), "")
+	return paths
+}
+
+// setPath parses value into the setting at the dotted YAML path
+func setPath(cfg *Config, path string, value string) error {
+	v := reflect.ValueOf(cfg).Elem()
+	for _, name := range strings.Split(path, ".") {
+		found := false
+		for i := range v.NumField() {
+			if yamlName(v.Type().Field(i)) == name {
+				v = v.Field(i)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown setting %q", path)
+		}
+	}
+	return setValue(v, value)
+}
+
+// setValue parses raw into v. Lists are comma separated, maps are comma separated
+// key=value entries and rate limit rules are formatted as requests_per_second:burst.
+func setValue(v reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	if v.Type() == reflect.TypeOf(RateLimitRule{}) {
+		rule, err := parseRateLimitRule(raw)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(rule))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		items := splitList(raw)
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setValue(slice.Index(i), item); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Map:
+		// Format: tenant-a=100,tenant-b=500
+		m := reflect.MakeMap(v.Type())
+		for _, entry := range splitList(raw) {
+			key, value, found := strings.Cut(entry, "=")
+			if !found {
+				return fmt.Errorf("invalid entry %q, want key=value", entry)
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := setValue(elem, value); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(strings.TrimSpace(key)), elem)
+		}
+		v.Set(m)
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+// parseRateLimitRule parses a rule formatted as requests_per_second:burst
+func parseRateLimitRule(value string) (RateLimitRule, error) {
+	rps, burst, found := strings.Cut(value, ":")
+	r, err := strconv.ParseFloat(strings.TrimSpace(rps), 64)
+	if !found || err != nil {
+		return RateLimitRule{}, fmt.Errorf("invalid rule %q, want requests_per_second:burst", value)
+	}
+	b, err := strconv.Atoi(strings.TrimSpace(burst))
+	if err != nil {
+		return RateLimitRule{}, fmt.Errorf("invalid rule %q, want requests_per_second:burst", value)
+	}
+	return RateLimitRule{RequestsPerSecond: r, Burst: b}, nil
+}
+
+// splitList splits a comma separated value and drops empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,209 @@
+package dynamodb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/repository"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/tenant"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/config"
+)
+
+// IdempotencyRepository implements the repository.IdempotencyRepository interface for DynamoDB.
+// Items are keyed by tenant_id (partition key) and idempotency_key (sort key) and
+// expire through the DynamoDB TTL attribute expires_at.
+type IdempotencyRepository struct {
+	client  *dynamodb.Client
+	table   string
+	timeout time.Duration
+}
+
+// NewIdempotencyRepository creates a new IdempotencyRepository instance
+func NewIdempotencyRepository(client *dynamodb.Client, cfg *config.Config) repository.IdempotencyRepository {
+	return &IdempotencyRepository{
+		client:  client,
+		table:   cfg.Idempotency.TableName,
+		timeout: cfg.DynamoDB.Timeout,
+	}
+}
+
+// withTimeout creates a context with the configured timeout
+func (r *IdempotencyRepository) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, r.timeout)
+}
+
+// key builds the primary key of an idempotency record owned by the tenant bound to ctx
+func (r *IdempotencyRepository) key(ctx context.Context, key string) (map[string]types.AttributeValue, error) {
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]types.AttributeValue{
+		"tenant_id":       &types.AttributeValueMemberS{Value: string(tenantID)},
+		"idempotency_key": &types.AttributeValueMemberS{Value: key},
+	}, nil
+}
+
+// Reserve stores a new in-flight record unless an unexpired one already exists
+func (r *IdempotencyRepository) Reserve(ctx context.Context, record *entity.IdempotencyRecord) (*entity.IdempotencyRecord, error) {
+	item, err := r.marshalRecord(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	// DynamoDB deletes expired items lazily, so expired records are treated as absent
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(r.table),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(idempotency_key) OR expires_at < :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+		},
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return r.unmarshalRecord(conditionErr.Item)
+		}
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// Complete stores the response of a reserved record
+func (r *IdempotencyRepository) Complete(ctx context.Context, record *entity.IdempotencyRecord) error {
+	item, err := r.marshalRecord(ctx, record)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.table),
+		Item:      item,
+	})
+	return err
+}
+
+// Release removes a reservation so the request can be retried
+func (r *IdempotencyRepository) Release(ctx context.Context, key string) error {
+	itemKey, err := r.key(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	_, err = r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(r.table),
+		Key:       itemKey,
+	})
+	return err
+}
+
+// marshalRecord converts an IdempotencyRecord to a DynamoDB item owned by the tenant bound to ctx
+func (r *IdempotencyRepository) marshalRecord(ctx context.Context, record *entity.IdempotencyRecord) (map[string]types.AttributeValue, error) {
+	item, err := r.key(ctx, record.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := json.Marshal(record.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	item["fingerprint"] = &types.AttributeValueMemberS{Value: record.Fingerprint}
+	item["status_code"] = &types.AttributeValueMemberN{Value: strconv.Itoa(record.StatusCode)}
+	item["header"] = &types.AttributeValueMemberS{Value: string(header)}
+	item["created_at"] = &types.AttributeValueMemberS{Value: record.CreatedAt.UTC().Format(time.RFC3339)}
+	item["expires_at"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(record.ExpiresAt.Unix(), 10)}
+	if len(record.Body) > 0 {
+		item["body"] = &types.AttributeValueMemberB{Value: record.Body}
+	}
+	return item, nil
+}
+
+// unmarshalRecord converts a DynamoDB item to an IdempotencyRecord
+func (r *IdempotencyRepository) unmarshalRecord(item map[string]types.AttributeValue) (*entity.IdempotencyRecord, error) {
+	key, ok := item["idempotency_key"].(*types.AttributeValueMemberS)
+	if !ok {
+		return nil, errors.New("invalid idempotency_key type")
+	}
+
+	fingerprint, ok := item["fingerprint"].(*types.AttributeValueMemberS)
+	if !ok {
+		return nil, errors.New("invalid fingerprint type")
+	}
+
+	statusCodeNum, ok := item["status_code"].(*types.AttributeValueMemberN)
+	if !ok {
+		return nil, errors.New("invalid status_code type")
+	}
+
+	statusCode, err := strconv.Atoi(statusCodeNum.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	headerStr, ok := item["header"].(*types.AttributeValueMemberS)
+	if !ok {
+		return nil, errors.New("invalid header type")
+	}
+
+	var header map[string][]string
+	if err := json.Unmarshal([]byte(headerStr.Value), &header); err != nil {
+		return nil, err
+	}
+
+	createdAtStr, ok := item["created_at"].(*types.AttributeValueMemberS)
+	if !ok {
+		return nil, errors.New("invalid created_at type")
+	}
+
+	createdAt, err := time.Parse(time.RFC3339, createdAtStr.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAtNum, ok := item["expires_at"].(*types.AttributeValueMemberN)
+	if !ok {
+		return nil, errors.New("invalid expires_at type")
+	}
+
+	expiresAt, err := strconv.ParseInt(expiresAtNum.Value, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var body []byte
+	if b, ok := item["body"].(*types.AttributeValueMemberB); ok {
+		body = b.Value
+	}
+
+	return &entity.IdempotencyRecord{
+		Key:         key.Value,
+		Fingerprint: fingerprint.Value,
+		StatusCode:  statusCode,
+		Header:      header,
+		Body:        body,
+		CreatedAt:   createdAt,
+		ExpiresAt:   time.Unix(expiresAt, 0),
+	}, nil
+}
@@ -0,0 +1,287 @@
+package dynamodb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/repository"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/tenant"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/config"
+)
+
+// ImportJobRepository implements the repository.ImportJobRepository interface for DynamoDB.
+// Items are keyed by tenant_id (partition key) and import_id (sort key) and
+// expire through the DynamoDB TTL attribute expires_at.
+type ImportJobRepository struct {
+	client  *dynamodb.Client
+	table   string
+	timeout time.Duration
+}
+
+// NewImportJobRepository creates a new ImportJobRepository instance
+func NewImportJobRepository(client *dynamodb.Client, cfg *config.Config) repository.ImportJobRepository {
+	return &ImportJobRepository{
+		client:  client,
+		table:   cfg.Import.TableName,
+		timeout: cfg.DynamoDB.Timeout,
+	}
+}
+
+// importRow is the stored form of an entity.ImportRowResult
+type importRow struct {
+	Row        int           `json:"row"`
+	ExternalID string        `json:"external_id,omitempty"`
+	Status     string        `json:"status"`
+	TodoID     string        `json:"todo_id,omitempty"`
+	Errors     []importError `json:"errors,omitempty"`
+	Reason     string        `json:"reason,omitempty"`
+}
+
+// importError is the stored form of an entity.ImportFieldError
+type importError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// withTimeout creates a context with the configured timeout
+func (r *ImportJobRepository) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, r.timeout)
+}
+
+// key builds the primary key of an import job owned by the tenant bound to ctx
+func (r *ImportJobRepository) key(ctx context.Context, id uuid.UUID) (map[string]types.AttributeValue, error) {
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]types.AttributeValue{
+		"tenant_id": &types.AttributeValueMemberS{Value: string(tenantID)},
+		"import_id": &types.AttributeValueMemberS{Value: id.String()},
+	}, nil
+}
+
+// Save creates or overwrites a job
+func (r *ImportJobRepository) Save(ctx context.Context, job *entity.ImportJob) error {
+	item, err := r.marshalJob(ctx, job)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.table),
+		Item:      item,
+	})
+	return err
+}
+
+// FindByID retrieves a job by its ID, treating expired jobs as absent
+func (r *ImportJobRepository) FindByID(ctx context.Context, id uuid.UUID) (*entity.ImportJob, error) {
+	key, err := r.key(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.table),
+		Key:       key,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	job, err := r.unmarshalJob(result.Item)
+	if err != nil {
+		return nil, err
+	}
+	// DynamoDB deletes expired items lazily
+	if time.Now().After(job.ExpiresAt) {
+		return nil, nil
+	}
+	return job, nil
+}
+
+// marshalJob converts an ImportJob to a DynamoDB item owned by the tenant bound to ctx
+func (r *ImportJobRepository) marshalJob(ctx context.Context, job *entity.ImportJob) (map[string]types.AttributeValue, error) {
+	item, err := r.key(ctx, job.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]importRow, 0, len(job.Rows))
+	for _, row := range job.Rows {
+		stored := importRow{
+			Row:        row.Row,
+			ExternalID: row.ExternalID,
+			Status:     string(row.Status),
+			Reason:     row.Reason,
+		}
+		if row.TodoID != uuid.Nil {
+			stored.TodoID = row.TodoID.String()
+		}
+		for _, fe := range row.Errors {
+			stored.Errors = append(stored.Errors, importError(fe))
+		}
+		rows = append(rows, stored)
+	}
+	rowsJSON, err := json.Marshal(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	item["format"] = &types.AttributeValueMemberS{Value: job.Format}
+	item["dry_run"] = &types.AttributeValueMemberBOOL{Value: job.DryRun}
+	item["status"] = &types.AttributeValueMemberS{Value: string(job.Status)}
+	item["total"] = numberAttribute(job.Total)
+	item["processed"] = numberAttribute(job.Processed)
+	item["valid"] = numberAttribute(job.Valid)
+	item["created"] = numberAttribute(job.Created)
+	item["duplicates"] = numberAttribute(job.Duplicates)
+	item["invalid"] = numberAttribute(job.Invalid)
+	item["failed"] = numberAttribute(job.Failed)
+	item["rows"] = &types.AttributeValueMemberS{Value: string(rowsJSON)}
+	item["error"] = &types.AttributeValueMemberS{Value: job.Error}
+	item["created_at"] = &types.AttributeValueMemberS{Value: formatTimestamp(job.CreatedAt)}
+	item["updated_at"] = &types.AttributeValueMemberS{Value: formatTimestamp(job.UpdatedAt)}
+	item["expires_at"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(job.ExpiresAt.Unix(), 10)}
+	return item, nil
+}
+
+// unmarshalJob converts a DynamoDB item to an ImportJob
+func (r *ImportJobRepository) unmarshalJob(item map[string]types.AttributeValue) (*entity.ImportJob, error) {
+	idStr, ok := item["import_id"].(*types.AttributeValueMemberS)
+	if !ok {
+		return nil, errors.New("invalid import_id type")
+	}
+
+	id, err := uuid.Parse(idStr.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	format, ok := item["format"].(*types.AttributeValueMemberS)
+	if !ok {
+		return nil, errors.New("invalid format type")
+	}
+
+	dryRun, ok := item["dry_run"].(*types.AttributeValueMemberBOOL)
+	if !ok {
+		return nil, errors.New("invalid dry_run type")
+	}
+
+	status, ok := item["status"].(*types.AttributeValueMemberS)
+	if !ok {
+		return nil, errors.New("invalid status type")
+	}
+
+	job := &entity.ImportJob{
+		ID:     id,
+		Format: format.Value,
+		DryRun: dryRun.Value,
+		Status: entity.ImportStatus(status.Value),
+	}
+
+	counts := map[string]*int{
+		"total":      &job.Total,
+		"processed":  &job.Processed,
+		"valid":      &job.Valid,
+		"created":    &job.Created,
+		"duplicates": &job.Duplicates,
+		"invalid":    &job.Invalid,
+		"failed":     &job.Failed,
+	}
+	for name, count := range counts {
+		value, ok := item[name].(*types.AttributeValueMemberN)
+		if !ok {
+			return nil, errors.New("invalid " + name + " type")
+		}
+		if *count, err = strconv.Atoi(value.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	rowsJSON, ok := item["rows"].(*types.AttributeValueMemberS)
+	if !ok {
+		return nil, errors.New("invalid rows type")
+	}
+
+	var rows []importRow
+	if err := json.Unmarshal([]byte(rowsJSON.Value), &rows); err != nil {
+		return nil, err
+	}
+	for _, stored := range rows {
+		row := entity.ImportRowResult{
+			Row:        stored.Row,
+			ExternalID: stored.ExternalID,
+			Status:     entity.ImportRowStatus(stored.Status),
+			Reason:     stored.Reason,
+		}
+		if stored.TodoID != "" {
+			if row.TodoID, err = uuid.Parse(stored.TodoID); err != nil {
+				return nil, err
+			}
+		}
+		for _, fe := range stored.Errors {
+			row.Errors = append(row.Errors, entity.ImportFieldError(fe))
+		}
+		job.Rows = append(job.Rows, row)
+	}
+
+	if value, ok := item["error"].(*types.AttributeValueMemberS); ok {
+		job.Error = value.Value
+	}
+
+	createdAtStr, ok := item["created_at"].(*types.AttributeValueMemberS)
+	if !ok {
+		return nil, errors.New("invalid created_at type")
+	}
+
+	if job.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAtStr.Value); err != nil {
+		return nil, err
+	}
+
+	updatedAtStr, ok := item["updated_at"].(*types.AttributeValueMemberS)
+	if !ok {
+		return nil, errors.New("invalid updated_at type")
+	}
+
+	if job.UpdatedAt, err = time.Parse(time.RFC3339Nano, updatedAtStr.Value); err != nil {
+		return nil, err
+	}
+
+	expiresAtNum, ok := item["expires_at"].(*types.AttributeValueMemberN)
+	if !ok {
+		return nil, errors.New("invalid expires_at type")
+	}
+
+	expiresAt, err := strconv.ParseInt(expiresAtNum.Value, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	job.ExpiresAt = time.Unix(expiresAt, 0)
+
+	return job, nil
+}
+
+// numberAttribute converts n to a DynamoDB number
+func numberAttribute(n int) *types.AttributeValueMemberN {
+	return &types.AttributeValueMemberN{Value: strconv.Itoa(n)}
+}
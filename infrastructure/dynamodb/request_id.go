@@ -0,0 +1,44 @@
+package dynamodb
+
+import (
+	"context"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/logger"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/requestid"
+	"go.uber.org/zap"
+)
+
+// addRequestIDMiddleware registers a middleware on the DynamoDB client stack that forwards
+// the request ID bound to the context of each call and logs the call with the request-scoped logger
+func addRequestIDMiddleware(stack *middleware.Stack) error {
+	return stack.Build.Add(middleware.BuildMiddlewareFunc("RequestID", func(
+		ctx context.Context, in middleware.BuildInput, next middleware.BuildHandler,
+	) (middleware.BuildOutput, middleware.Metadata, error) {
+		if id := requestid.FromContext(ctx); id != "" {
+			if req, ok := in.Request.(*smithyhttp.Request); ok {
+				req.Header.Set(requestid.Header, id)
+				// The User-Agent is recorded by CloudTrail, which makes calls traceable to the request
+				req.Header.Set("User-Agent", req.Header.Get("User-Agent")+" request-id/"+id)
+			}
+		}
+
+		start := time.Now()
+		out, metadata, err := next.HandleBuild(ctx, in)
+
+		log := logger.FromContext(ctx)
+		fields := []zap.Field{
+			zap.String("operation", awsmiddleware.GetOperationName(ctx)),
+			zap.String("latency", time.Since(start).String()),
+		}
+		if err != nil {
+			log.Debug("DynamoDB call failed", append(fields, zap.Error(err))...)
+		} else {
+			log.Debug("DynamoDB call completed", fields...)
+		}
+		return out, metadata, err
+	}), middleware.After)
+}
@@ -0,0 +1,235 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/repository"
+)
+
+const (
+	// maxBatchGetKeys is the maximum number of keys of a BatchGetItem call
+	maxBatchGetKeys = 100
+	// maxGroupedWrites is the number of creates and deletes of a batch committed by a single transaction
+	maxGroupedWrites = 25
+	// maxBatchAttempts bounds the calls made to process the unprocessed items of a batch,
+	// to commit a throttled write of a batch, or to commit a transaction conflicting on the todo count
+	maxBatchAttempts = 5
+	// batchBackoff is the delay before the first retry of unprocessed items, doubled for each retry
+	batchBackoff = 50 * time.Millisecond
+)
+
+// errKeysUnprocessed is returned when keys were still throttled after retrying
+var errKeysUnprocessed = errors.New("keys were not processed")
+
+// FindByIDs retrieves todo items by their IDs with BatchGetItem, retrying unprocessed keys
+func (r *TodoRepository) FindByIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*entity.Todo, error) {
+	todos := make(map[uuid.UUID]*entity.Todo, len(ids))
+
+	for start := 0; start < len(ids); start += maxBatchGetKeys {
+		keys := make([]map[string]types.AttributeValue, 0, maxBatchGetKeys)
+		for _, id := range ids[start:min(start+maxBatchGetKeys, len(ids))] {
+			key, err := r.key(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+
+		request := map[string]types.KeysAndAttributes{r.table: {Keys: keys}}
+		for attempt := 0; len(request) > 0; attempt++ {
+			if attempt == maxBatchAttempts {
+				return nil, errKeysUnprocessed
+			}
+			if err := backoff(ctx, attempt); err != nil {
+				return nil, err
+			}
+
+			result, err := r.batchGetItem(ctx, request)
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range result.Responses[r.table] {
+				todo, err := r.unmarshalTodo(ctx, item)
+				if err != nil {
+					return nil, err
+				}
+				todos[todo.ID] = todo
+			}
+			request = result.UnprocessedKeys
+		}
+	}
+
+	return todos, nil
+}
+
+// batchGetItem makes a single BatchGetItem call within the configured timeout
+func (r *TodoRepository) batchGetItem(ctx context.Context, request map[string]types.KeysAndAttributes) (*dynamodb.BatchGetItemOutput, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return r.client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{RequestItems: request})
+}
+
+// BatchWrite applies creates and deletes with TransactWriteItems in groups of 25, together
+// with the todo count of the tenant. When a group is cancelled or throttled, its writes are
+// committed one at a time instead, so each gets its own outcome; writes still throttled after
+// retrying fail with repository.ErrWriteUnprocessed. Once the quota is reached the remaining
+// creates fail with repository.ErrQuotaExceeded without being attempted. Updates are
+// conditional puts of their own, which fail with repository.ErrConcurrentModification when
+// the todo changed or was deleted since it was read. When a call fails, the writes of its
+// group and of the later groups fail with its error, while the earlier writes stay applied.
+func (r *TodoRepository) BatchWrite(ctx context.Context, writes []repository.TodoWrite, quota int) ([]error, error) {
+	errs := make([]error, len(writes))
+
+	var grouped []int
+	for i, write := range writes {
+		if write.Kind == repository.WriteUpdate {
+			errs[i] = r.putUnchanged(ctx, write.Todo, write.ReadUpdatedAt)
+		} else {
+			grouped = append(grouped, i)
+		}
+	}
+
+	quotaReached := false
+	// pending drops the creates of group once the quota is reached
+	pending := func(group []int) []int {
+		if !quotaReached {
+			return group
+		}
+		var kept []int
+		for _, i := range group {
+			if writes[i].Kind == repository.WriteCreate {
+				errs[i] = repository.ErrQuotaExceeded
+			} else {
+				kept = append(kept, i)
+			}
+		}
+		return kept
+	}
+	// failFrom fails the writes from position start of grouped with err
+	failFrom := func(start int, err error) {
+		for _, i := range grouped[start:] {
+			errs[i] = err
+		}
+	}
+
+	for start := 0; start < len(grouped); start += maxGroupedWrites {
+		group := pending(grouped[start:min(start+maxGroupedWrites, len(grouped))])
+		err := r.commitWrites(ctx, writes, group, quota)
+		if !cancelled(err) && !throttled(err) {
+			if err != nil {
+				failFrom(start, err)
+				return errs, nil
+			}
+			continue
+		}
+
+		for j, i := range group {
+			if len(pending([]int{i})) == 0 {
+				continue
+			}
+			err := r.commitWrite(ctx, writes, i, quota)
+			if err != nil && !cancelled(err) && !errors.Is(err, repository.ErrWriteUnprocessed) {
+				failFrom(start+j, err)
+				return errs, nil
+			}
+			errs[i] = unwrapTransactionError(err)
+			quotaReached = quotaReached || errors.Is(err, repository.ErrQuotaExceeded)
+		}
+	}
+
+	return errs, nil
+}
+
+// commitWrites commits the creates and deletes at the given positions of writes as a single unit of work
+func (r *TodoRepository) commitWrites(ctx context.Context, writes []repository.TodoWrite, indexes []int, quota int) error {
+	uow := r.NewUnitOfWork()
+	uow.LimitTodos(quota)
+	for _, i := range indexes {
+		if writes[i].Kind == repository.WriteCreate {
+			uow.Create(writes[i].Todo)
+		} else {
+			uow.Delete(writes[i].ID)
+		}
+	}
+	return uow.Commit(ctx)
+}
+
+// commitWrite commits the create or delete at position i of writes on its own, retrying it
+// while it is throttled
+func (r *TodoRepository) commitWrite(ctx context.Context, writes []repository.TodoWrite, i int, quota int) error {
+	for attempt := 0; attempt < maxBatchAttempts; attempt++ {
+		if err := backoff(ctx, attempt); err != nil {
+			return err
+		}
+		if err := r.commitWrites(ctx, writes, []int{i}, quota); !throttled(err) {
+			return err
+		}
+	}
+	return repository.ErrWriteUnprocessed
+}
+
+// throttled reports whether a unit of work was rejected for exceeding the throughput of the
+// table, as a whole or by one of its writes, still after the retries of the SDK
+func throttled(err error) bool {
+	var throughputErr *types.ProvisionedThroughputExceededException
+	var requestLimitErr *types.RequestLimitExceeded
+	return errors.Is(err, repository.ErrWriteUnprocessed) ||
+		errors.As(err, &throughputErr) || errors.As(err, &requestLimitErr)
+}
+
+// cancelled reports whether a unit of work was cancelled by one of its writes or by the quota,
+// rather than failing to be committed
+func cancelled(err error) bool {
+	var transactionErr *repository.TransactionError
+	return errors.As(err, &transactionErr) || errors.Is(err, repository.ErrQuotaExceeded)
+}
+
+// putUnchanged replaces a todo that must be unchanged since it was updated at readUpdatedAt
+func (r *TodoRepository) putUnchanged(ctx context.Context, todo *entity.Todo, readUpdatedAt time.Time) error {
+	item, err := r.marshalTodo(ctx, todo)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(r.table),
+		Item:                item,
+		ConditionExpression: aws.String("updated_at = :read_updated_at"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":read_updated_at": &types.AttributeValueMemberS{Value: formatTimestamp(readUpdatedAt)},
+		},
+	})
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return repository.ErrConcurrentModification
+	}
+	return err
+}
+
+// backoff waits before the given retry attempt of a batch call; the first attempt is not delayed
+func backoff(ctx context.Context, attempt int) error {
+	if attempt == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(batchBackoff << (attempt - 1))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
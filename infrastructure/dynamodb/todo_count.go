@@ -0,0 +1,114 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/tenant"
+)
+
+// todoCountKey is the constraint key of the item counting the todos of a tenant
+const todoCountKey = "todo_count"
+
+// countKey builds the key of the todo count of the tenant bound to ctx in the constraint table
+func (r *TodoRepository) countKey(ctx context.Context) (map[string]types.AttributeValue, error) {
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]types.AttributeValue{
+		"tenant_id":      &types.AttributeValueMemberS{Value: string(tenantID)},
+		"constraint_key": &types.AttributeValueMemberS{Value: todoCountKey},
+	}, nil
+}
+
+// countUpdate builds the transaction item adding delta to the todo count of the tenant bound
+// to ctx. The count must exist, and stay within quota when todos are added and quota is set.
+func (r *TodoRepository) countUpdate(ctx context.Context, delta, quota int) (types.TransactWriteItem, error) {
+	key, err := r.countKey(ctx)
+	if err != nil {
+		return types.TransactWriteItem{}, err
+	}
+
+	values := map[string]types.AttributeValue{
+		":delta": &types.AttributeValueMemberN{Value: strconv.Itoa(delta)},
+	}
+	condition := "attribute_exists(todo_count)"
+	if delta > 0 && quota > 0 {
+		// A missing count fails the comparison as well
+		condition = "todo_count <= :max"
+		values[":max"] = &types.AttributeValueMemberN{Value: strconv.Itoa(quota - delta)}
+	}
+
+	return types.TransactWriteItem{Update: &types.Update{
+		TableName:                 aws.String(r.constraintTable),
+		Key:                       key,
+		UpdateExpression:          aws.String("ADD todo_count :delta"),
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeValues: values,
+	}}, nil
+}
+
+// initializeCount stores the todo count of the tenant bound to ctx, counted from its todos,
+// unless it exists already. It reports whether the count was missing. Every write adding or
+// removing todos requires the count to exist, so none is applied while the todos are counted.
+func (r *TodoRepository) initializeCount(ctx context.Context) (bool, error) {
+	key, err := r.countKey(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	existing, err := r.getConsistent(ctx, r.constraintTable, key)
+	if err != nil || existing != nil {
+		return false, err
+	}
+
+	count, err := r.count(ctx, true)
+	if err != nil {
+		return false, err
+	}
+
+	item := map[string]types.AttributeValue{
+		"todo_count": &types.AttributeValueMemberN{Value: strconv.Itoa(count)},
+	}
+	for name, value := range key {
+		item[name] = value
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(r.constraintTable),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(constraint_key)"),
+	})
+	// Another request initialized the count in the meantime
+	var conditionErr *types.ConditionalCheckFailedException
+	if err != nil && !errors.As(err, &conditionErr) {
+		return false, err
+	}
+	return true, nil
+}
+
+// getConsistent reads the item of table with the given key with a strongly consistent read,
+// or nil when it does not exist
+func (r *TodoRepository) getConsistent(ctx context.Context, table string, key map[string]types.AttributeValue) (map[string]types.AttributeValue, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(table),
+		Key:            key,
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.Item, nil
+}
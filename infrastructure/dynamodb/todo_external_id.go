@@ -0,0 +1,96 @@
+package dynamodb
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/tenant"
+)
+
+// externalIDKey returns the constraint key reserving an external ID. External IDs are
+// hashed, as they may be longer than a sort key can be.
+func externalIDKey(externalID string) string {
+	sum := sha256.Sum256([]byte(externalID))
+	return "external_id#" + hex.EncodeToString(sum[:])
+}
+
+// markerKey builds the key of the item reserving an external ID for the tenant bound to ctx
+func (r *TodoRepository) markerKey(ctx context.Context, externalID string) (map[string]types.AttributeValue, error) {
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]types.AttributeValue{
+		"tenant_id":      &types.AttributeValueMemberS{Value: string(tenantID)},
+		"constraint_key": &types.AttributeValueMemberS{Value: externalIDKey(externalID)},
+	}, nil
+}
+
+// markerPut builds the transaction item reserving the external ID of a created todo,
+// which fails when another todo of the tenant reserved it already
+func (r *TodoRepository) markerPut(ctx context.Context, todo *entity.Todo) (types.TransactWriteItem, error) {
+	item, err := r.markerKey(ctx, todo.ExternalID)
+	if err != nil {
+		return types.TransactWriteItem{}, err
+	}
+	item["external_id"] = &types.AttributeValueMemberS{Value: todo.ExternalID}
+	item["todo_id"] = &types.AttributeValueMemberS{Value: todo.ID.String()}
+
+	return types.TransactWriteItem{Put: &types.Put{
+		TableName:           aws.String(r.constraintTable),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(constraint_key)"),
+	}}, nil
+}
+
+// removeStaleMarker removes the reservation of an external ID when the todo holding it was
+// deleted, as deletes leave reservations behind. It reports whether the external ID is free.
+func (r *TodoRepository) removeStaleMarker(ctx context.Context, externalID string) (bool, error) {
+	key, err := r.markerKey(ctx, externalID)
+	if err != nil {
+		return false, err
+	}
+
+	marker, err := r.getConsistent(ctx, r.constraintTable, key)
+	if err != nil || marker == nil {
+		return marker == nil, err
+	}
+	todoID, _ := marker["todo_id"].(*types.AttributeValueMemberS)
+	if todoID == nil {
+		return false, errors.New("external ID reservation without a todo_id")
+	}
+
+	todoKey := map[string]types.AttributeValue{
+		"tenant_id": key["tenant_id"],
+		"id":        todoID,
+	}
+	todo, err := r.getConsistent(ctx, r.table, todoKey)
+	if err != nil || todo != nil {
+		return false, err
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	// The condition keeps a reservation made again in the meantime
+	_, err = r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(r.constraintTable),
+		Key:                 key,
+		ConditionExpression: aws.String("todo_id = :todo_id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":todo_id": todoID,
+		},
+	})
+	var conditionErr *types.ConditionalCheckFailedException
+	if err != nil && !errors.As(err, &conditionErr) {
+		return false, err
+	}
+	return err == nil, nil
+}
@@ -3,7 +3,8 @@ package dynamodb
 import (
 	"context"
 	"errors"
-	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -14,18 +15,30 @@ import (
 	"github.com/google/uuid"
 	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
 	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/repository"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/tenant"
 	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/config"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/metrics"
 )
 
-// TodoRepository implements the repository.TodoRepository interface for DynamoDB
+// parentIndex is the global secondary index of the todo table keyed by tenant_id and parent_id.
+// Top-level todos have no parent_id, so only subtasks are indexed.
+const parentIndex = "parent_id-index"
+
+// TodoRepository implements the repository.TodoRepository interface for DynamoDB.
+// Items are keyed by tenant_id (partition key) and id (sort key), so every
+// read and write is confined to the partition of the tenant bound to the context.
+// The number of todos of every tenant is kept in the constraint table, updated in
+// the same transaction as the todos created and deleted.
 type TodoRepository struct {
-	client  *dynamodb.Client
-	table   string
-	timeout time.Duration
+	client          *dynamodb.Client
+	table           string
+	constraintTable string
+	timeout         time.Duration
 }
 
-// NewTodoRepository creates a new TodoRepository instance
-func NewTodoRepository(cfg *config.Config) repository.TodoRepository {
+// NewTodoRepository creates a new TodoRepository instance.
+// Every call made by its client is recorded by m.
+func NewTodoRepository(cfg *config.Config, m *metrics.Metrics) repository.TodoRepository {
 	var awsCfg aws.Config
 	var err error
 
@@ -52,85 +65,166 @@ func NewTodoRepository(cfg *config.Config) repository.TodoRepository {
 		panic(err)
 	}
 
-	client := dynamodb.NewFromConfig(awsCfg)
-
-	// Parse DynamoDB timeout from string to time.Duration
-	timeout, err := time.ParseDuration(cfg.DynamoDB.Timeout)
-	if err != nil {
-		panic(fmt.Sprintf("Invalid DynamoDB timeout format: %v", err))
-	}
+	client := dynamodb.NewFromConfig(awsCfg, func(o *dynamodb.Options) {
+		o.APIOptions = append(o.APIOptions, addRequestIDMiddleware, addTracingMiddleware, m.InstrumentDynamoDB)
+	})
 
 	return &TodoRepository{
-		client:  client,
-		table:   cfg.DynamoDB.TableName,
-		timeout: timeout,
+		client:          client,
+		table:           cfg.DynamoDB.TableName,
+		constraintTable: cfg.DynamoDB.ConstraintTableName,
+		timeout:         cfg.DynamoDB.Timeout,
 	}
 }
 
 // withTimeout creates a context with the configured timeout
-func (r *TodoRepository) withTimeout() (context.Context, context.CancelFunc) {
-	return context.WithTimeout(context.Background(), r.timeout)
+func (r *TodoRepository) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, r.timeout)
 }
 
-// Create saves a new todo item to DynamoDB
-func (r *TodoRepository) Create(todo *entity.Todo) (*entity.Todo, error) {
-	ctx, cancel := r.withTimeout()
-	defer cancel()
-
-	item := map[string]types.AttributeValue{
-		"id":          &types.AttributeValueMemberS{Value: todo.ID.String()},
-		"title":       &types.AttributeValueMemberS{Value: todo.Title},
-		"description": &types.AttributeValueMemberS{Value: todo.Description},
-		"completed":   &types.AttributeValueMemberBOOL{Value: todo.Completed},
-		"created_at":  &types.AttributeValueMemberS{Value: todo.CreatedAt.Format("2006-01-02T15:04:05Z")},
-		"updated_at":  &types.AttributeValueMemberS{Value: todo.UpdatedAt.Format("2006-01-02T15:04:05Z")},
-	}
-
-	_, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(r.table),
-		Item:      item,
-	})
+// key builds the primary key of a todo owned by the tenant bound to ctx
+func (r *TodoRepository) key(ctx context.Context, id uuid.UUID) (map[string]types.AttributeValue, error) {
+	tenantID, err := tenant.FromContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	return map[string]types.AttributeValue{
+		"tenant_id": &types.AttributeValueMemberS{Value: string(tenantID)},
+		"id":        &types.AttributeValueMemberS{Value: id.String()},
+	}, nil
+}
+
+// Create saves a new todo item to DynamoDB together with the todo count of the tenant.
+// It fails with repository.ErrAlreadyExists when the todo exists.
+func (r *TodoRepository) Create(ctx context.Context, todo *entity.Todo, quota int) (*entity.Todo, error) {
+	uow := r.NewUnitOfWork()
+	uow.Create(todo)
+	uow.LimitTodos(quota)
+	if err := uow.Commit(ctx); err != nil {
+		return nil, unwrapTransactionError(err)
+	}
+
 	return todo, nil
 }
 
-// FindAll retrieves all todo items from DynamoDB
-func (r *TodoRepository) FindAll() ([]*entity.Todo, error) {
-	ctx, cancel := r.withTimeout()
+// FindAll retrieves the todo items of the tenant selected by filter from DynamoDB
+func (r *TodoRepository) FindAll(ctx context.Context, filter entity.TodoFilter) ([]*entity.Todo, error) {
+	ctx, cancel := r.withTimeout(ctx)
 	defer cancel()
 
-	result, err := r.client.Scan(ctx, &dynamodb.ScanInput{
-		TableName: aws.String(r.table),
+	todos := make([]*entity.Todo, 0)
+	err := r.forEachPage(ctx, filter, false, func(page []*entity.Todo) error {
+		todos = append(todos, page...)
+		return nil
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	todos := make([]*entity.Todo, 0, len(result.Items))
-	for _, item := range result.Items {
-		todo, err := r.unmarshalTodo(item)
+	return todos, nil
+}
+
+// ForEachPage reads the todo items of the tenant selected by filter one query page at a time.
+// The configured timeout applies to each page rather than to the whole read, so large
+// partitions can be streamed.
+func (r *TodoRepository) ForEachPage(ctx context.Context, filter entity.TodoFilter, fn func(todos []*entity.Todo) error) error {
+	return r.forEachPage(ctx, filter, true, fn)
+}
+
+// forEachPage queries the todo items selected by filter and calls fn with each page,
+// bounding each page request by the configured timeout when pageTimeout is set
+func (r *TodoRepository) forEachPage(ctx context.Context, filter entity.TodoFilter, pageTimeout bool, fn func(todos []*entity.Todo) error) error {
+	input, err := r.queryInput(ctx)
+	if err != nil {
+		return err
+	}
+	// The children of a todo are read from the parent index instead of filtering the partition
+	if filter.ParentID != nil && *filter.ParentID != uuid.Nil {
+		input.IndexName = aws.String(parentIndex)
+		input.KeyConditionExpression = aws.String("tenant_id = :tenant_id AND parent_id = :parent_id")
+		input.ExpressionAttributeValues[":parent_id"] = &types.AttributeValueMemberS{Value: filter.ParentID.String()}
+		filter.ParentID = nil
+	}
+	applyFilter(input, filter)
+
+	paginator := dynamodb.NewQueryPaginator(r.client, input)
+	for paginator.HasMorePages() {
+		pageCtx, cancel := ctx, context.CancelFunc(func() {})
+		if pageTimeout {
+			pageCtx, cancel = r.withTimeout(ctx)
+		}
+		result, err := paginator.NextPage(pageCtx)
+		cancel()
 		if err != nil {
-			return nil, err
+			return err
+		}
+
+		page := make([]*entity.Todo, 0, len(result.Items))
+		for _, item := range result.Items {
+			todo, err := r.unmarshalTodo(ctx, item)
+			if err != nil {
+				return err
+			}
+			page = append(page, todo)
+		}
+		// Filtered pages may be empty while more items remain
+		if len(page) == 0 {
+			continue
+		}
+		if err := fn(page); err != nil {
+			return err
 		}
-		todos = append(todos, todo)
 	}
 
-	return todos, nil
+	return nil
+}
+
+// applyFilter adds the filter expression selecting the todos matched by filter to input.
+// Items written before lists and parents were introduced have neither attribute, which
+// is the same as an empty value.
+func applyFilter(input *dynamodb.QueryInput, filter entity.TodoFilter) {
+	var conditions []string
+	if filter.Completed != nil {
+		conditions = append(conditions, "completed = :completed")
+		input.ExpressionAttributeValues[":completed"] = &types.AttributeValueMemberBOOL{Value: *filter.Completed}
+	}
+	if filter.ListID != nil {
+		conditions = append(conditions, optionalAttributeCondition("list_id", *filter.ListID))
+		input.ExpressionAttributeValues[":list_id"] = &types.AttributeValueMemberS{Value: *filter.ListID}
+	}
+	if filter.ParentID != nil {
+		parentID := formatParentID(*filter.ParentID)
+		conditions = append(conditions, optionalAttributeCondition("parent_id", parentID))
+		input.ExpressionAttributeValues[":parent_id"] = &types.AttributeValueMemberS{Value: parentID}
+	}
+	if len(conditions) > 0 {
+		input.FilterExpression = aws.String(strings.Join(conditions, " AND "))
+	}
+}
+
+// optionalAttributeCondition compares an attribute that may be missing with the value of
+// the placeholder named after it, a missing attribute matching an empty value
+func optionalAttributeCondition(name, value string) string {
+	if value == "" {
+		return "(attribute_not_exists(" + name + ") OR " + name + " = :" + name + ")"
+	}
+	return name + " = :" + name
 }
 
 // FindByID retrieves a todo item by its ID from DynamoDB
-func (r *TodoRepository) FindByID(id uuid.UUID) (*entity.Todo, error) {
-	ctx, cancel := r.withTimeout()
+func (r *TodoRepository) FindByID(ctx context.Context, id uuid.UUID) (*entity.Todo, error) {
+	key, err := r.key(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
 	defer cancel()
 
 	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
 		TableName: aws.String(r.table),
-		Key: map[string]types.AttributeValue{
-			"id": &types.AttributeValueMemberS{Value: id.String()},
-		},
+		Key:       key,
 	})
 	if err != nil {
 		return nil, err
@@ -140,48 +234,209 @@ func (r *TodoRepository) FindByID(id uuid.UUID) (*entity.Todo, error) {
 		return nil, nil
 	}
 
-	return r.unmarshalTodo(result.Item)
+	return r.unmarshalTodo(ctx, result.Item)
 }
 
-// Update saves changes to an existing todo item in DynamoDB
-func (r *TodoRepository) Update(todo *entity.Todo) (*entity.Todo, error) {
-	ctx, cancel := r.withTimeout()
+// Update saves changes to an existing todo item in DynamoDB.
+// It returns nil when the todo does not exist in the tenant's partition.
+func (r *TodoRepository) Update(ctx context.Context, todo *entity.Todo) (*entity.Todo, error) {
+	item, err := r.marshalTodo(ctx, todo)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
 	defer cancel()
 
-	_, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(r.table),
-		Item: map[string]types.AttributeValue{
-			"id":          &types.AttributeValueMemberS{Value: todo.ID.String()},
-			"title":       &types.AttributeValueMemberS{Value: todo.Title},
-			"description": &types.AttributeValueMemberS{Value: todo.Description},
-			"completed":   &types.AttributeValueMemberBOOL{Value: todo.Completed},
-			"created_at":  &types.AttributeValueMemberS{Value: todo.CreatedAt.Format("2006-01-02T15:04:05Z")},
-			"updated_at":  &types.AttributeValueMemberS{Value: todo.UpdatedAt.Format("2006-01-02T15:04:05Z")},
-		},
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(r.table),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_exists(id)"),
 	})
 	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return nil, nil
+		}
 		return nil, err
 	}
 
 	return todo, nil
 }
 
-// Delete removes a todo item from DynamoDB by its ID
-func (r *TodoRepository) Delete(id uuid.UUID) error {
-	ctx, cancel := r.withTimeout()
+// Replace creates a todo item in DynamoDB or replaces the existing one with the same ID.
+// Creation and replacement are told apart with condition expressions, so a concurrent
+// delete or create between the two attempts is retried instead of being overwritten.
+func (r *TodoRepository) Replace(ctx context.Context, todo *entity.Todo, quota int) (*entity.Todo, bool, error) {
+	const maxAttempts = 3
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		created, err := r.Create(ctx, todo, quota)
+		if err == nil {
+			return created, true, nil
+		}
+		if !errors.Is(err, repository.ErrAlreadyExists) {
+			return nil, false, err
+		}
+
+		replaced, err := r.replaceExisting(ctx, todo)
+		if err != nil {
+			return nil, false, err
+		}
+		if replaced != nil {
+			return replaced, false, nil
+		}
+	}
+
+	return nil, false, errors.New("todo was concurrently created and deleted")
+}
+
+// replaceExisting overwrites every attribute of an existing todo except its creation time.
+// It returns nil when the todo does not exist.
+func (r *TodoRepository) replaceExisting(ctx context.Context, todo *entity.Todo) (*entity.Todo, error) {
+	key, err := r.key(ctx, todo.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
 	defer cancel()
 
-	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
-		TableName: aws.String(r.table),
-		Key: map[string]types.AttributeValue{
-			"id": &types.AttributeValueMemberS{Value: id.String()},
-		},
+	values := map[string]types.AttributeValue{
+		":title":       &types.AttributeValueMemberS{Value: todo.Title},
+		":description": &types.AttributeValueMemberS{Value: todo.Description},
+		":completed":   &types.AttributeValueMemberBOOL{Value: todo.Completed},
+		":list_id":     &types.AttributeValueMemberS{Value: todo.ListID},
+		":due":         &types.AttributeValueMemberS{Value: formatDue(todo.Due)},
+		":priority":    &types.AttributeValueMemberN{Value: strconv.Itoa(todo.Priority)},
+		":updated_at":  &types.AttributeValueMemberS{Value: formatTimestamp(todo.UpdatedAt)},
+	}
+	update := "SET title = :title, description = :description, completed = :completed, " +
+		"list_id = :list_id, due = :due, priority = :priority, updated_at = :updated_at"
+	// Index keys cannot be empty, so top-level todos have no parent_id
+	if todo.ParentID == uuid.Nil {
+		update += " REMOVE parent_id"
+	} else {
+		update += ", parent_id = :parent_id"
+		values[":parent_id"] = &types.AttributeValueMemberS{Value: todo.ParentID.String()}
+	}
+
+	result, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(r.table),
+		Key:                       key,
+		ConditionExpression:       aws.String("attribute_exists(id)"),
+		UpdateExpression:          aws.String(update),
+		ExpressionAttributeValues: values,
+		ReturnValues:              types.ReturnValueAllNew,
 	})
-	return err
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return r.unmarshalTodo(ctx, result.Attributes)
+}
+
+// Delete removes a todo item from DynamoDB by its ID together with the todo count of the
+// tenant. It reports whether the todo existed.
+func (r *TodoRepository) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
+	uow := r.NewUnitOfWork()
+	uow.Delete(id)
+	err := unwrapTransactionError(uow.Commit(ctx))
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Count returns the number of todo items owned by the tenant
+func (r *TodoRepository) Count(ctx context.Context) (int, error) {
+	return r.count(ctx, false)
+}
+
+// count queries the number of todo items owned by the tenant, with a strongly
+// consistent read when consistent is set
+func (r *TodoRepository) count(ctx context.Context, consistent bool) (int, error) {
+	input, err := r.queryInput(ctx)
+	if err != nil {
+		return 0, err
+	}
+	input.Select = types.SelectCount
+	input.ConsistentRead = aws.Bool(consistent)
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	count := 0
+	paginator := dynamodb.NewQueryPaginator(r.client, input)
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return 0, err
+		}
+		count += int(result.Count)
+	}
+
+	return count, nil
+}
+
+// queryInput builds a query over the partition of the tenant bound to ctx
+func (r *TodoRepository) queryInput(ctx context.Context) (*dynamodb.QueryInput, error) {
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.QueryInput{
+		TableName:              aws.String(r.table),
+		KeyConditionExpression: aws.String("tenant_id = :tenant_id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":tenant_id": &types.AttributeValueMemberS{Value: string(tenantID)},
+		},
+	}, nil
+}
+
+// marshalTodo converts a Todo entity to a DynamoDB item owned by the tenant bound to ctx
+func (r *TodoRepository) marshalTodo(ctx context.Context, todo *entity.Todo) (map[string]types.AttributeValue, error) {
+	item, err := r.key(ctx, todo.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	item["title"] = &types.AttributeValueMemberS{Value: todo.Title}
+	item["description"] = &types.AttributeValueMemberS{Value: todo.Description}
+	item["completed"] = &types.AttributeValueMemberBOOL{Value: todo.Completed}
+	item["list_id"] = &types.AttributeValueMemberS{Value: todo.ListID}
+	// Index keys cannot be empty, so top-level todos have no parent_id
+	if todo.ParentID != uuid.Nil {
+		item["parent_id"] = &types.AttributeValueMemberS{Value: todo.ParentID.String()}
+	}
+	item["due"] = &types.AttributeValueMemberS{Value: formatDue(todo.Due)}
+	item["priority"] = &types.AttributeValueMemberN{Value: strconv.Itoa(todo.Priority)}
+	item["external_id"] = &types.AttributeValueMemberS{Value: todo.ExternalID}
+	item["created_at"] = &types.AttributeValueMemberS{Value: formatTimestamp(todo.CreatedAt)}
+	item["updated_at"] = &types.AttributeValueMemberS{Value: formatTimestamp(todo.UpdatedAt)}
+	return item, nil
 }
 
 // unmarshalTodo converts a DynamoDB item to a Todo entity
-func (r *TodoRepository) unmarshalTodo(item map[string]types.AttributeValue) (*entity.Todo, error) {
+func (r *TodoRepository) unmarshalTodo(ctx context.Context, item map[string]types.AttributeValue) (*entity.Todo, error) {
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	owner, ok := item["tenant_id"].(*types.AttributeValueMemberS)
+	if !ok || owner.Value != string(tenantID) {
+		return nil, errors.New("item does not belong to the tenant")
+	}
+
 	idStr, ok := item["id"].(*types.AttributeValueMemberS)
 	if !ok {
 		return nil, errors.New("invalid id type")
@@ -207,12 +462,46 @@ func (r *TodoRepository) unmarshalTodo(item map[string]types.AttributeValue) (*e
 		return nil, errors.New("invalid completed type")
 	}
 
+	// Items written before lists and parents were introduced have neither attribute
+	var listID string
+	if value, ok := item["list_id"].(*types.AttributeValueMemberS); ok {
+		listID = value.Value
+	}
+
+	var parentID uuid.UUID
+	if value, ok := item["parent_id"].(*types.AttributeValueMemberS); ok && value.Value != "" {
+		if parentID, err = uuid.Parse(value.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	// Items written before due dates and priorities were introduced have neither attribute
+	var due time.Time
+	if value, ok := item["due"].(*types.AttributeValueMemberS); ok && value.Value != "" {
+		if due, err = time.Parse(time.RFC3339Nano, value.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	var priority int
+	if value, ok := item["priority"].(*types.AttributeValueMemberN); ok {
+		if priority, err = strconv.Atoi(value.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	// Only imported todos have an external ID
+	var externalID string
+	if value, ok := item["external_id"].(*types.AttributeValueMemberS); ok {
+		externalID = value.Value
+	}
+
 	createdAtStr, ok := item["created_at"].(*types.AttributeValueMemberS)
 	if !ok {
 		return nil, errors.New("invalid created_at type")
 	}
 
-	createdAt, err := time.Parse("2006-01-02T15:04:05Z", createdAtStr.Value)
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtStr.Value)
 	if err != nil {
 		return nil, err
 	}
@@ -222,7 +511,7 @@ func (r *TodoRepository) unmarshalTodo(item map[string]types.AttributeValue) (*e
 		return nil, errors.New("invalid updated_at type")
 	}
 
-	updatedAt, err := time.Parse("2006-01-02T15:04:05Z", updatedAtStr.Value)
+	updatedAt, err := time.Parse(time.RFC3339Nano, updatedAtStr.Value)
 	if err != nil {
 		return nil, err
 	}
@@ -232,11 +521,38 @@ func (r *TodoRepository) unmarshalTodo(item map[string]types.AttributeValue) (*e
 		Title:       title.Value,
 		Description: description.Value,
 		Completed:   completed.Value,
+		ListID:      listID,
+		ParentID:    parentID,
+		Due:         due,
+		Priority:    priority,
+		ExternalID:  externalID,
 		CreatedAt:   createdAt,
 		UpdatedAt:   updatedAt,
 	}, nil
 }
 
+// formatTimestamp formats t for storage in UTC with nanosecond precision.
+// Items written with second precision are still parsed by time.RFC3339Nano.
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// formatDue formats the due date of a todo for storage, empty when it has none
+func formatDue(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return formatTimestamp(t)
+}
+
+// formatParentID formats the parent of a todo as stored, empty for top-level todos, which store none
+func formatParentID(id uuid.UUID) string {
+	if id == uuid.Nil {
+		return ""
+	}
+	return id.String()
+}
+
 // GetClient returns the DynamoDB client
 func (r *TodoRepository) GetClient() *dynamodb.Client {
 	return r.client
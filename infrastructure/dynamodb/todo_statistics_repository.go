@@ -0,0 +1,54 @@
+package dynamodb
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/repository"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/config"
+)
+
+// TodoStatisticsRepository implements the repository.TodoStatisticsRepository interface for DynamoDB.
+// It scans the todo table across every tenant, so it must never be exposed to tenant requests.
+type TodoStatisticsRepository struct {
+	client *dynamodb.Client
+	table  string
+}
+
+// NewTodoStatisticsRepository creates a new TodoStatisticsRepository instance
+func NewTodoStatisticsRepository(client *dynamodb.Client, cfg *config.Config) repository.TodoStatisticsRepository {
+	return &TodoStatisticsRepository{
+		client: client,
+		table:  cfg.DynamoDB.TableName,
+	}
+}
+
+// Count returns the number of open and completed todos across every tenant.
+// It reads the whole table, so it is meant to be called rarely.
+func (r *TodoStatisticsRepository) Count(ctx context.Context) (entity.TodoCounts, error) {
+	paginator := dynamodb.NewScanPaginator(r.client, &dynamodb.ScanInput{
+		TableName:            aws.String(r.table),
+		ProjectionExpression: aws.String("completed"),
+	})
+
+	var counts entity.TodoCounts
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return entity.TodoCounts{}, err
+		}
+
+		for _, item := range result.Items {
+			if completed, ok := item["completed"].(*types.AttributeValueMemberBOOL); ok && completed.Value {
+				counts.Completed++
+			} else {
+				counts.Open++
+			}
+		}
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,59 @@
+package dynamodb
+
+import (
+	"context"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of DynamoDB calls
+var tracer = otel.Tracer("github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/dynamodb")
+
+// addTracingMiddleware registers middlewares on the DynamoDB client stack that wrap each call
+// in a client span and propagate its trace context with the W3C traceparent header
+func addTracingMiddleware(stack *middleware.Stack) error {
+	err := stack.Initialize.Add(middleware.InitializeMiddlewareFunc("Tracing", func(
+		ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler,
+	) (middleware.InitializeOutput, middleware.Metadata, error) {
+		operation := awsmiddleware.GetOperationName(ctx)
+		ctx, span := tracer.Start(ctx, "DynamoDB."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.RPCSystemKey.String("aws-api"),
+				semconv.RPCService("DynamoDB"),
+				semconv.RPCMethod(operation),
+				semconv.DBSystemDynamoDB,
+			),
+		)
+		defer span.End()
+
+		out, metadata, err := next.HandleInitialize(ctx, in)
+		if requestID, ok := awsmiddleware.GetRequestIDMetadata(metadata); ok {
+			span.SetAttributes(semconv.AWSRequestID(requestID))
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return out, metadata, err
+	}), middleware.After)
+	if err != nil {
+		return err
+	}
+
+	return stack.Build.Add(middleware.BuildMiddlewareFunc("TraceContext", func(
+		ctx context.Context, in middleware.BuildInput, next middleware.BuildHandler,
+	) (middleware.BuildOutput, middleware.Metadata, error) {
+		if req, ok := in.Request.(*smithyhttp.Request); ok {
+			otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+		}
+		return next.HandleBuild(ctx, in)
+	}), middleware.After)
+}
@@ -0,0 +1,293 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/repository"
+)
+
+// unitOfWork implements repository.UnitOfWork with TransactWriteItems. Writes adding or
+// removing todos also update the todo count of the tenant, as the last transaction item,
+// and creates of todos with an external ID reserve it, right after the todo.
+type unitOfWork struct {
+	repo   *TodoRepository
+	writes []pendingWrite
+	quota  int
+}
+
+// transactItem is an item of the transaction of a unit of work
+type transactItem struct {
+	types.TransactWriteItem
+	// write is the position of the write the item belongs to, -1 for the todo count
+	write int
+	// marker is set for the item reserving the external ID of a created todo
+	marker bool
+}
+
+// maxTransactItems is the maximum number of items of a TransactWriteItems call
+const maxTransactItems = 100
+
+// errCountCheckFailed is returned when the todo count of the tenant is missing or would exceed the quota
+var errCountCheckFailed = errors.New("todo count condition failed")
+
+// pendingWrite is a write of a unit of work, turned into a transaction item on commit.
+// readUpdatedAt guards updates, and deletes when it is not the zero time.
+type pendingWrite struct {
+	kind          repository.WriteKind
+	todo          *entity.Todo
+	id            uuid.UUID
+	readUpdatedAt time.Time
+}
+
+// NewUnitOfWork starts collecting writes to commit atomically
+func (r *TodoRepository) NewUnitOfWork() repository.UnitOfWork {
+	return &unitOfWork{repo: r}
+}
+
+// Create adds a write storing a todo that must not exist yet
+func (u *unitOfWork) Create(todo *entity.Todo) {
+	u.writes = append(u.writes, pendingWrite{kind: repository.WriteCreate, todo: todo})
+}
+
+// Update adds a write storing a todo that must be unchanged since it was read
+func (u *unitOfWork) Update(todo *entity.Todo, readUpdatedAt time.Time) {
+	u.writes = append(u.writes, pendingWrite{kind: repository.WriteUpdate, todo: todo, readUpdatedAt: readUpdatedAt})
+}
+
+// Delete adds a write removing a todo that must exist
+func (u *unitOfWork) Delete(id uuid.UUID) {
+	u.writes = append(u.writes, pendingWrite{kind: repository.WriteDelete, id: id})
+}
+
+// DeleteUnchanged adds a write removing a todo that must be unchanged since it was read
+func (u *unitOfWork) DeleteUnchanged(id uuid.UUID, readUpdatedAt time.Time) {
+	u.writes = append(u.writes, pendingWrite{kind: repository.WriteDelete, id: id, readUpdatedAt: readUpdatedAt})
+}
+
+// LimitTodos fails the commit when the tenant would own more than quota todos afterwards
+func (u *unitOfWork) LimitTodos(quota int) {
+	u.quota = quota
+}
+
+// Len returns the number of writes added
+func (u *unitOfWork) Len() int {
+	return len(u.writes)
+}
+
+// Commit applies the writes with a single TransactWriteItems call. A missing todo count is
+// initialized before retrying, and so is a transaction conflicting on the todo count, which
+// every write adding or removing todos of the tenant updates. External IDs still reserved by
+// deleted todos are released before retrying as well.
+func (u *unitOfWork) Commit(ctx context.Context) error {
+	if len(u.writes) == 0 {
+		return nil
+	}
+	if len(u.writes) > repository.MaxUnitOfWorkWrites {
+		return repository.ErrTooManyWrites
+	}
+
+	items := make([]transactItem, 0, len(u.writes)+1)
+	for i, write := range u.writes {
+		item, err := u.transactWriteItem(ctx, write)
+		if err != nil {
+			return err
+		}
+		items = append(items, transactItem{TransactWriteItem: item, write: i})
+
+		if write.kind == repository.WriteCreate && write.todo.ExternalID != "" {
+			marker, err := u.repo.markerPut(ctx, write.todo)
+			if err != nil {
+				return err
+			}
+			items = append(items, transactItem{TransactWriteItem: marker, write: i, marker: true})
+		}
+	}
+	delta := u.countDelta()
+	if delta != 0 {
+		item, err := u.repo.countUpdate(ctx, delta, u.quota)
+		if err != nil {
+			return err
+		}
+		items = append(items, transactItem{TransactWriteItem: item, write: -1})
+	}
+	if len(items) > maxTransactItems {
+		return repository.ErrTooManyWrites
+	}
+
+	var err error
+	for attempt := 0; attempt < maxBatchAttempts; attempt++ {
+		if err := backoff(ctx, attempt); err != nil {
+			return err
+		}
+
+		err = u.transactWriteItems(ctx, items)
+		var cancelledErr *types.TransactionCanceledException
+		if !errors.As(err, &cancelledErr) {
+			return err
+		}
+
+		err = u.cancellationError(cancelledErr, items)
+		var transactionErr *repository.TransactionError
+		switch {
+		case errors.As(err, &transactionErr) && errors.Is(err, repository.ErrDuplicateExternalID):
+			free, removeErr := u.repo.removeStaleMarker(ctx, u.writes[transactionErr.Index].todo.ExternalID)
+			if removeErr != nil {
+				return removeErr
+			}
+			if !free {
+				return err
+			}
+		case errors.Is(err, errCountCheckFailed):
+			initialized, initErr := u.repo.initializeCount(ctx)
+			if initErr != nil {
+				return initErr
+			}
+			if !initialized && delta > 0 && u.quota > 0 {
+				return repository.ErrQuotaExceeded
+			}
+		case errors.Is(err, repository.ErrTransactionConflict) && transactionErr == nil:
+			// Conflicts on the todo count are retried, unlike those on the todos themselves
+		default:
+			return err
+		}
+	}
+	// The todo count kept disappearing while it was initialized
+	if errors.Is(err, errCountCheckFailed) {
+		return repository.ErrTransactionConflict
+	}
+	return err
+}
+
+// transactWriteItems makes a single TransactWriteItems call within the configured timeout
+func (u *unitOfWork) transactWriteItems(ctx context.Context, items []transactItem) error {
+	ctx, cancel := u.repo.withTimeout(ctx)
+	defer cancel()
+
+	transactItems := make([]types.TransactWriteItem, len(items))
+	for k, item := range items {
+		transactItems[k] = item.TransactWriteItem
+	}
+	_, err := u.repo.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: transactItems})
+	return err
+}
+
+// countDelta returns the number of todos the writes add, negative when they remove more than they add
+func (u *unitOfWork) countDelta() int {
+	delta := 0
+	for _, write := range u.writes {
+		switch write.kind {
+		case repository.WriteCreate:
+			delta++
+		case repository.WriteDelete:
+			delta--
+		}
+	}
+	return delta
+}
+
+// transactWriteItem converts a write to a transaction item guarded by its condition
+func (u *unitOfWork) transactWriteItem(ctx context.Context, write pendingWrite) (types.TransactWriteItem, error) {
+	r := u.repo
+	switch write.kind {
+	case repository.WriteCreate:
+		item, err := r.marshalTodo(ctx, write.todo)
+		if err != nil {
+			return types.TransactWriteItem{}, err
+		}
+		return types.TransactWriteItem{Put: &types.Put{
+			TableName:           aws.String(r.table),
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(id)"),
+		}}, nil
+	case repository.WriteUpdate:
+		item, err := r.marshalTodo(ctx, write.todo)
+		if err != nil {
+			return types.TransactWriteItem{}, err
+		}
+		return types.TransactWriteItem{Put: &types.Put{
+			TableName:           aws.String(r.table),
+			Item:                item,
+			ConditionExpression: aws.String("updated_at = :read_updated_at"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":read_updated_at": &types.AttributeValueMemberS{Value: formatTimestamp(write.readUpdatedAt)},
+			},
+		}}, nil
+	default:
+		key, err := r.key(ctx, write.id)
+		if err != nil {
+			return types.TransactWriteItem{}, err
+		}
+		item := &types.Delete{
+			TableName:           aws.String(r.table),
+			Key:                 key,
+			ConditionExpression: aws.String("attribute_exists(id)"),
+		}
+		if !write.readUpdatedAt.IsZero() {
+			item.ConditionExpression = aws.String("updated_at = :read_updated_at")
+			item.ExpressionAttributeValues = map[string]types.AttributeValue{
+				":read_updated_at": &types.AttributeValueMemberS{Value: formatTimestamp(write.readUpdatedAt)},
+			}
+		}
+		return types.TransactWriteItem{Delete: item}, nil
+	}
+}
+
+// cancellationError translates the reason of the first item that cancelled the transaction
+// to a domain error. Reasons are listed in the order of the transaction items, so the reason
+// of the todo count comes last and is returned without a write index.
+func (u *unitOfWork) cancellationError(err *types.TransactionCanceledException, items []transactItem) error {
+	for k, reason := range err.CancellationReasons {
+		if k >= len(items) {
+			break
+		}
+		i := items[k].write
+
+		var reasonErr error
+		switch code := aws.ToString(reason.Code); code {
+		case "", "None":
+			continue
+		case "ConditionalCheckFailed":
+			switch {
+			case i < 0:
+				reasonErr = errCountCheckFailed
+			case items[k].marker:
+				reasonErr = repository.ErrDuplicateExternalID
+			case u.writes[i].kind == repository.WriteCreate:
+				reasonErr = repository.ErrAlreadyExists
+			case u.writes[i].kind == repository.WriteDelete && u.writes[i].readUpdatedAt.IsZero():
+				reasonErr = repository.ErrNotFound
+			default:
+				reasonErr = repository.ErrConcurrentModification
+			}
+		case "TransactionConflict":
+			reasonErr = repository.ErrTransactionConflict
+		case "ThrottlingError", "ProvisionedThroughputExceeded", "RequestLimitExceeded":
+			reasonErr = repository.ErrWriteUnprocessed
+		default:
+			reasonErr = fmt.Errorf("%s: %s", code, aws.ToString(reason.Message))
+		}
+		if i < 0 {
+			return reasonErr
+		}
+		return &repository.TransactionError{Index: i, Err: reasonErr}
+	}
+	return err
+}
+
+// unwrapTransactionError returns the domain error wrapped by a *repository.TransactionError,
+// for units of work made of a single write
+func unwrapTransactionError(err error) error {
+	var transactionErr *repository.TransactionError
+	if errors.As(err, &transactionErr) {
+		return transactionErr.Err
+	}
+	return err
+}
@@ -0,0 +1,215 @@
+package eventbus
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/tenant"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+// subscriberBuffer is the number of events a subscriber may fall behind before it is dropped
+const subscriberBuffer = 64
+
+// Bus delivers the todo events of each tenant to its subscribers in memory. The last events
+// of a tenant are kept in a bounded replay buffer, so that clients reconnecting with the ID
+// of the last event they received miss nothing in between. Tenants without subscribers are
+// forgotten ttl after their last event.
+type Bus struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	firstID uint64
+	nextID  uint64
+	// evictedID is the ID of the last event of the streams evicted so far
+	evictedID uint64
+	streams   map[tenant.ID]*stream
+	lastSweep time.Time
+	closed    bool
+}
+
+// stream holds the replay buffer and subscribers of a tenant
+type stream struct {
+	// events is a ring buffer of the last events, the oldest at start
+	events []entity.TodoEvent
+	start  int
+	// since is the ID after which every event is buffered
+	since       uint64
+	subscribers map[*Subscription]struct{}
+	// active is when the last event was published or the last subscriber left
+	active time.Time
+}
+
+// Subscription receives the events published after it was created
+type Subscription struct {
+	bus      *Bus
+	tenantID tenant.ID
+	events   chan entity.TodoEvent
+}
+
+// NewBus creates a new Bus instance keeping the last size events of each tenant for ttl
+func NewBus(size int, ttl time.Duration) *Bus {
+	// Event IDs start from the current time so that they keep increasing across restarts,
+	// and IDs given by clients from before a restart are reported as out of the buffer
+	now := time.Now()
+	firstID := uint64(now.UnixMicro())
+	return &Bus{
+		size:      size,
+		ttl:       ttl,
+		firstID:   firstID,
+		nextID:    firstID,
+		evictedID: firstID,
+		streams:   make(map[tenant.ID]*stream),
+		lastSweep: now,
+	}
+}
+
+// Publish assigns event the next ID and sends it to the subscribers of the tenant bound to ctx.
+// It never blocks; subscribers too slow to keep up are closed, so that they resume from the
+// replay buffer.
+func (b *Bus) Publish(ctx context.Context, event entity.TodoEvent) {
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		logger.FromContext(ctx).Warn("Dropped todo event without tenant", zap.Error(err))
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+
+	now := time.Now()
+	b.sweep(now)
+
+	b.nextID++
+	event.ID = b.nextID
+	s := b.stream(tenantID)
+	s.active = now
+	if len(s.events) < b.size {
+		s.events = append(s.events, event)
+	} else {
+		s.since = s.events[s.start].ID
+		s.events[s.start] = event
+		s.start = (s.start + 1) % b.size
+	}
+
+	for sub := range s.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			logger.FromContext(ctx).Warn("Dropped slow todo event subscriber", zap.String("tenant_id", string(tenantID)))
+			b.remove(sub)
+		}
+	}
+}
+
+// Subscribe subscribes to the events of a tenant. When resume is set, it also returns the
+// buffered events after the event with ID after, and reports whether they are complete:
+// false, with no events, when some of them were evicted from the buffer or published before
+// a restart. The subscription is nil once the bus is closed.
+func (b *Bus) Subscribe(tenantID tenant.ID, after uint64, resume bool) ([]entity.TodoEvent, *Subscription, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, nil, true
+	}
+
+	b.sweep(time.Now())
+	s := b.stream(tenantID)
+	var replay []entity.TodoEvent
+	complete := true
+	if resume {
+		complete = after >= s.since && after <= b.nextID
+		if complete {
+			for _, event := range s.ordered() {
+				if event.ID > after {
+					replay = append(replay, event)
+				}
+			}
+		}
+	}
+
+	sub := &Subscription{bus: b, tenantID: tenantID, events: make(chan entity.TodoEvent, subscriberBuffer)}
+	s.subscribers[sub] = struct{}{}
+	return replay, sub, complete
+}
+
+// Close closes every subscription, so that their clients disconnect before the server shuts down
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for _, s := range b.streams {
+		for sub := range s.subscribers {
+			b.remove(sub)
+		}
+	}
+}
+
+// Events returns the channel of the events published to the subscription. It is closed when
+// the subscription ends, after which the events published since should be replayed.
+func (s *Subscription) Events() <-chan entity.TodoEvent {
+	return s.events
+}
+
+// Unsubscribe ends the subscription
+func (s *Subscription) Unsubscribe() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if stream, ok := s.bus.streams[s.tenantID]; ok {
+		if _, ok := stream.subscribers[s]; ok {
+			s.bus.remove(s)
+		}
+	}
+}
+
+// stream returns the stream of a tenant, creating it when needed. b.mu must be held.
+func (b *Bus) stream(tenantID tenant.ID) *stream {
+	s, ok := b.streams[tenantID]
+	if !ok {
+		// The tenant may have had a stream evicted, whose events are no longer buffered
+		s = &stream{since: b.evictedID, subscribers: make(map[*Subscription]struct{})}
+		b.streams[tenantID] = s
+	}
+	return s
+}
+
+// remove ends a subscription that has not ended yet. b.mu must be held.
+func (b *Bus) remove(sub *Subscription) {
+	s := b.streams[sub.tenantID]
+	delete(s.subscribers, sub)
+	close(sub.events)
+	if len(s.subscribers) == 0 {
+		s.active = time.Now()
+	}
+}
+
+// sweep evicts the streams without subscribers that were inactive for ttl, so memory does
+// not grow with the number of tenants seen. b.mu must be held.
+func (b *Bus) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < b.ttl {
+		return
+	}
+	for tenantID, s := range b.streams {
+		if len(s.subscribers) > 0 || now.Sub(s.active) <= b.ttl {
+			continue
+		}
+		if len(s.events) > 0 {
+			b.evictedID = max(b.evictedID, s.events[(s.start+len(s.events)-1)%len(s.events)].ID)
+		}
+		delete(b.streams, tenantID)
+	}
+	b.lastSweep = now
+}
+
+// ordered returns the buffered events, oldest first
+func (s *stream) ordered() []entity.TodoEvent {
+	events := make([]entity.TodoEvent, 0, len(s.events))
+	events = append(events, s.events[s.start:]...)
+	return append(events, s.events[:s.start]...)
+}
@@ -0,0 +1,29 @@
+package feature
+
+import (
+	"maps"
+	"sync/atomic"
+)
+
+// Flags holds the feature flags, replaced as a whole when the configuration is reloaded
+type Flags struct {
+	flags atomic.Pointer[map[string]bool]
+}
+
+// NewFlags creates Flags with the given values
+func NewFlags(flags map[string]bool) *Flags {
+	f := &Flags{}
+	f.Set(flags)
+	return f
+}
+
+// Set replaces every flag
+func (f *Flags) Set(flags map[string]bool) {
+	flags = maps.Clone(flags)
+	f.flags.Store(&flags)
+}
+
+// Enabled reports whether the named feature is enabled. Unknown features are disabled.
+func (f *Flags) Enabled(name string) bool {
+	return (*f.flags.Load())[name]
+}
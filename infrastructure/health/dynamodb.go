@@ -0,0 +1,18 @@
+package health
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// DynamoDBTableCheck returns a check that succeeds when the table can be described
+func DynamoDBTableCheck(client *dynamodb.Client, tableName string) CheckFunc {
+	return func(ctx context.Context) error {
+		_, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+			TableName: aws.String(tableName),
+		})
+		return err
+	}
+}
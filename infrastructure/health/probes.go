@@ -0,0 +1,106 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// StatusStarting is reported until the application finished starting
+	StatusStarting HealthStatus = "starting"
+	// StatusDraining is reported once the application stopped accepting new traffic
+	StatusDraining HealthStatus = "draining"
+)
+
+// Probes implements the liveness, readiness and startup probes of the application.
+// Readiness is derived from the dependency checks of a HealthChecker, whose result is
+// cached and which must fail several times in a row before the application is taken out
+// of rotation, so brief dependency blips do not cause restarts or traffic shifts.
+type Probes struct {
+	checker          HealthChecker
+	cacheTTL         time.Duration
+	failureThreshold int
+	logger           *zap.Logger
+
+	started  atomic.Bool
+	draining atomic.Bool
+
+	mu        sync.Mutex
+	last      HealthResponse
+	checkedAt time.Time
+	failures  int
+}
+
+// NewProbes creates probes backed by checker
+func NewProbes(checker HealthChecker, cacheTTL time.Duration, failureThreshold int, logger *zap.Logger) *Probes {
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
+	return &Probes{
+		checker:          checker,
+		cacheTTL:         cacheTTL,
+		failureThreshold: failureThreshold,
+		logger:           logger,
+	}
+}
+
+// MarkStarted records that the application finished starting
+func (p *Probes) MarkStarted() {
+	p.started.Store(true)
+}
+
+// Drain makes the readiness probe fail so no new traffic is routed to the application
+func (p *Probes) Drain() {
+	p.draining.Store(true)
+}
+
+// Live reports whether the process is able to serve requests. It never checks dependencies.
+func (p *Probes) Live() HealthResponse {
+	return HealthResponse{Status: string(StatusOK)}
+}
+
+// Started reports whether the application finished starting
+func (p *Probes) Started() (HealthResponse, bool) {
+	if !p.started.Load() {
+		return HealthResponse{Status: string(StatusStarting)}, false
+	}
+	return HealthResponse{Status: string(StatusOK)}, true
+}
+
+// Ready reports whether the application should receive traffic, along with the
+// latest dependency check result
+func (p *Probes) Ready(ctx context.Context) (HealthResponse, bool) {
+	if p.draining.Load() {
+		return HealthResponse{Status: string(StatusDraining)}, false
+	}
+	if !p.started.Load() {
+		return HealthResponse{Status: string(StatusStarting)}, false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Concurrent probes wait for the running check and share its result
+	if p.checkedAt.IsZero() || time.Since(p.checkedAt) >= p.cacheTTL {
+		p.last = p.checker.Check(ctx)
+		p.checkedAt = time.Now()
+
+		if p.last.Status == string(StatusFail) {
+			p.failures++
+			if p.failures == p.failureThreshold {
+				p.logger.Warn("Readiness check failure threshold reached", zap.Int("failures", p.failures))
+			}
+		} else {
+			if p.failures >= p.failureThreshold {
+				p.logger.Info("Readiness restored", zap.Int("failures", p.failures))
+			}
+			p.failures = 0
+		}
+	}
+
+	return p.last, p.failures < p.failureThreshold
+}
@@ -0,0 +1,134 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// CheckFunc checks a single component. It returns nil when the component is healthy.
+type CheckFunc func(ctx context.Context) error
+
+// Criticality tells how the failure of a component affects the overall status
+type Criticality int
+
+const (
+	// Critical components make the service fail
+	Critical Criticality = iota
+	// NonCritical components only degrade the service
+	NonCritical
+)
+
+// component is a registered health check
+type component struct {
+	name        string
+	criticality Criticality
+	timeout     time.Duration
+	check       CheckFunc
+}
+
+// Registry implements HealthChecker over the registered components.
+// Checks run concurrently, each bounded by its own timeout, and roll up into
+// ok, degraded (a non-critical component fails) or fail (a critical component fails).
+type Registry struct {
+	mu         sync.RWMutex
+	components []component
+	logger     *zap.Logger
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry(logger *zap.Logger) *Registry {
+	return &Registry{logger: logger}
+}
+
+// Register adds a component check. A component registered twice replaces the previous check.
+func (r *Registry) Register(name string, criticality Criticality, timeout time.Duration, check CheckFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := component{name: name, criticality: criticality, timeout: timeout, check: check}
+	for i := range r.components {
+		if r.components[i].name == name {
+			r.components[i] = c
+			return
+		}
+	}
+	r.components = append(r.components, c)
+}
+
+// Check runs every registered check and returns the rolled up status
+func (r *Registry) Check(ctx context.Context) HealthResponse {
+	r.mu.RLock()
+	components := append([]component(nil), r.components...)
+	r.mu.RUnlock()
+
+	results := make([]ServiceHealth, len(components))
+	var wg sync.WaitGroup
+	for i, c := range components {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = r.run(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	response := HealthResponse{
+		Status:     string(StatusOK),
+		Components: make(map[string]ServiceHealth, len(components)),
+	}
+	for i, c := range components {
+		result := results[i]
+		response.Components[c.name] = result
+		if result.Status != StatusFail {
+			continue
+		}
+		if c.criticality == Critical {
+			response.Status = string(StatusFail)
+		} else if response.Status == string(StatusOK) {
+			response.Status = string(StatusDegraded)
+		}
+	}
+	return response
+}
+
+// run executes a single check within its timeout
+func (r *Registry) run(ctx context.Context, c component) (result ServiceHealth) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	result.Critical = c.criticality == Critical
+
+	err := func() (err error) {
+		// A panicking check must not take the probe endpoint down with it
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				err = fmt.Errorf("check panicked: %v", recovered)
+			}
+		}()
+		return c.check(ctx)
+	}()
+	result.Latency = time.Since(start).String()
+
+	if err != nil {
+		r.logger.Error("Health check failed",
+			zap.Error(err),
+			zap.String("component", c.name),
+			zap.Bool("critical", result.Critical),
+		)
+		result.Status = StatusFail
+		result.Message = "Health check failed"
+		if errors.Is(err, context.DeadlineExceeded) {
+			result.Message = fmt.Sprintf("Health check timed out after %s", c.timeout)
+		}
+		return result
+	}
+
+	result.Status = StatusOK
+	return result
+}
@@ -1,35 +1,30 @@
 package logger
 
 import (
+	"context"
 	"os"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
-// NewLogger creates a new zap logger instance
-func NewLogger() *zap.Logger {
-	// Get the log level from the environment variable
-	level := os.Getenv("LOG_LEVEL")
-	if level == "" {
-		level = "info"
-	}
-
-	// Set the log level
-	var zapLevel zapcore.Level
+// ParseLevel converts a configured log level to a zap level, defaulting to info
+func ParseLevel(level string) zapcore.Level {
 	switch level {
 	case "debug":
-		zapLevel = zapcore.DebugLevel
-	case "info":
-		zapLevel = zapcore.InfoLevel
+		return zapcore.DebugLevel
 	case "warn":
-		zapLevel = zapcore.WarnLevel
+		return zapcore.WarnLevel
 	case "error":
-		zapLevel = zapcore.ErrorLevel
+		return zapcore.ErrorLevel
 	default:
-		zapLevel = zapcore.InfoLevel
+		return zapcore.InfoLevel
 	}
+}
 
+// NewLogger creates a new zap logger instance. Changing level takes effect immediately,
+// which lets the level be reloaded without a restart.
+func NewLogger(level zap.AtomicLevel) *zap.Logger {
 	// Configure the encoder
 	encoderConfig := zapcore.EncoderConfig{
 		TimeKey:        "timestamp",
@@ -50,10 +45,25 @@ func NewLogger() *zap.Logger {
 	core := zapcore.NewCore(
 		zapcore.NewJSONEncoder(encoderConfig),
 		zapcore.AddSync(os.Stdout),
-		zapLevel,
+		level,
 	)
 
 	// Create the logger
 	logger := zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
 	return logger
 }
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the request-scoped logger l
+func NewContext(ctx context.Context, l *zap.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the request-scoped logger carried by ctx, or the global logger
+func FromContext(ctx context.Context) *zap.Logger {
+	if l, ok := ctx.Value(contextKey{}).(*zap.Logger); ok {
+		return l
+	}
+	return zap.L()
+}
@@ -0,0 +1,107 @@
+package logger
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// redactedValue replaces sensitive values in logs
+const redactedValue = "[REDACTED]"
+
+// Redactor masks sensitive headers, query parameters and JSON body fields before they are logged.
+// Names are matched case-insensitively.
+type Redactor struct {
+	headers     map[string]bool
+	queryParams map[string]bool
+	bodyFields  map[string]bool
+}
+
+// NewRedactor creates a new Redactor instance
+func NewRedactor(headers, queryParams, bodyFields []string) *Redactor {
+	return &Redactor{
+		headers:     nameSet(headers),
+		queryParams: nameSet(queryParams),
+		bodyFields:  nameSet(bodyFields),
+	}
+}
+
+// Header returns the headers as a flat map with sensitive values masked
+func (r *Redactor) Header(header http.Header) map[string]string {
+	redacted := make(map[string]string, len(header))
+	for name, values := range header {
+		if r.headers[strings.ToLower(name)] {
+			redacted[name] = redactedValue
+			continue
+		}
+		redacted[name] = strings.Join(values, ", ")
+	}
+	return redacted
+}
+
+// Query returns the raw query string with sensitive parameter values masked
+func (r *Redactor) Query(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// An unparsable query may still carry secrets, so it is not logged
+		return redactedValue
+	}
+	for name := range values {
+		if r.queryParams[strings.ToLower(name)] {
+			values[name] = []string{redactedValue}
+		}
+	}
+	return values.Encode()
+}
+
+// Body returns a JSON body with sensitive fields masked at any depth.
+// Bodies that are not JSON are not logged.
+func (r *Redactor) Body(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	var document any
+	if err := json.Unmarshal(body, &document); err != nil {
+		return "[non-JSON body omitted]"
+	}
+
+	redacted, err := json.Marshal(r.redactJSON(document))
+	if err != nil {
+		return "[unencodable body omitted]"
+	}
+	return string(redacted)
+}
+
+// redactJSON masks sensitive fields of a decoded JSON document
+func (r *Redactor) redactJSON(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for name, field := range v {
+			if r.bodyFields[strings.ToLower(name)] {
+				v[name] = redactedValue
+			} else {
+				v[name] = r.redactJSON(field)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = r.redactJSON(item)
+		}
+	}
+	return value
+}
+
+// nameSet builds a case-insensitive set of names
+func nameSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[strings.ToLower(name)] = true
+	}
+	return set
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// throttleErrorCodes are the DynamoDB error codes reported when a request exceeds
+// the provisioned capacity or the account limits
+var throttleErrorCodes = map[string]bool{
+	"ProvisionedThroughputExceededException": true,
+	"ThrottlingException":                    true,
+	"RequestLimitExceeded":                   true,
+}
+
+// InstrumentDynamoDB registers middlewares on a DynamoDB client stack that record
+// the latency and errors of each operation and every throttled attempt.
+// It is meant to be appended to the APIOptions of the client.
+func (m *Metrics) InstrumentDynamoDB(stack *middleware.Stack) error {
+	// The initialize step wraps the whole operation, retries included
+	err := stack.Initialize.Add(middleware.InitializeMiddlewareFunc("Metrics", func(
+		ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler,
+	) (middleware.InitializeOutput, middleware.Metadata, error) {
+		start := time.Now()
+		out, metadata, err := next.HandleInitialize(ctx, in)
+
+		operation := awsmiddleware.GetOperationName(ctx)
+		outcome := "success"
+		if err != nil {
+			outcome = "error"
+			m.DynamoDBErrors.WithLabelValues(operation, errorCode(err)).Inc()
+		}
+		m.DynamoDBDuration.WithLabelValues(operation, outcome).Observe(time.Since(start).Seconds())
+		return out, metadata, err
+	}), middleware.After)
+	if err != nil {
+		return err
+	}
+
+	// The deserialize step runs once per attempt, so throttles absorbed by retries are counted too
+	return stack.Deserialize.Add(middleware.DeserializeMiddlewareFunc("MetricsThrottles", func(
+		ctx context.Context, in middleware.DeserializeInput, next middleware.DeserializeHandler,
+	) (middleware.DeserializeOutput, middleware.Metadata, error) {
+		out, metadata, err := next.HandleDeserialize(ctx, in)
+		if err != nil && throttleErrorCodes[errorCode(err)] {
+			m.DynamoDBThrottles.WithLabelValues(awsmiddleware.GetOperationName(ctx)).Inc()
+		}
+		return out, metadata, err
+	}), middleware.Before)
+}
+
+// errorCode returns the API error code of err, or a coarse category for client side failures
+func errorCode(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "Timeout"
+	}
+	if errors.Is(err, context.Canceled) {
+		return "Canceled"
+	}
+	return "Unknown"
+}
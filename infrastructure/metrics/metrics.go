@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes every application metric
+const namespace = "todo"
+
+// Metrics holds the Prometheus collectors of the application
+type Metrics struct {
+	registry *prometheus.Registry
+
+	HTTPRequests        *prometheus.CounterVec
+	HTTPRequestDuration *prometheus.HistogramVec
+	HTTPInFlight        prometheus.Gauge
+
+	DynamoDBDuration  *prometheus.HistogramVec
+	DynamoDBErrors    *prometheus.CounterVec
+	DynamoDBThrottles *prometheus.CounterVec
+
+	Todos *prometheus.GaugeVec
+}
+
+// NewMetrics creates the collectors and registers them, together with the Go runtime
+// and process collectors, on a dedicated registry
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		HTTPRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests by method, route template and status code.",
+		}, []string{"method", "route", "status"}),
+		HTTPRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP requests by method, route template and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		HTTPInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "Number of HTTP requests being served.",
+		}),
+		DynamoDBDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "dynamodb_operation_duration_seconds",
+			Help:      "Latency of DynamoDB operations by operation and outcome.",
+			Buckets:   []float64{.002, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"operation", "outcome"}),
+		DynamoDBErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "dynamodb_operation_errors_total",
+			Help:      "Number of failed DynamoDB operations by operation and error code.",
+		}, []string{"operation", "error_code"}),
+		DynamoDBThrottles: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "dynamodb_throttles_total",
+			Help:      "Number of DynamoDB operations rejected for exceeding provisioned or account throughput.",
+		}, []string{"operation"}),
+		Todos: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "todos",
+			Help:      "Number of todos across every tenant by state (open or completed).",
+		}, []string{"state"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.HTTPRequests,
+		m.HTTPRequestDuration,
+		m.HTTPInFlight,
+		m.DynamoDBDuration,
+		m.DynamoDBErrors,
+		m.DynamoDBThrottles,
+		m.Todos,
+	)
+	return m
+}
+
+// Handler returns the HTTP handler exposing the metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
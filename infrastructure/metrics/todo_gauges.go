@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/repository"
+	"go.uber.org/zap"
+)
+
+// RunTodoGauges refreshes the todo gauges from repo every interval until ctx is done.
+// Counting requires reading the whole table, so it is done in the background rather
+// than on every scrape, and not at all when interval is zero.
+func (m *Metrics) RunTodoGauges(ctx context.Context, repo repository.TodoStatisticsRepository, interval time.Duration, logger *zap.Logger) {
+	if interval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		m.refreshTodoGauges(ctx, repo, interval, logger)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshTodoGauges replaces the todo gauges with the current counts.
+// A refresh may not outlast the interval, so a slow scan never overlaps the next one.
+func (m *Metrics) refreshTodoGauges(ctx context.Context, repo repository.TodoStatisticsRepository, interval time.Duration, logger *zap.Logger) {
+	ctx, cancel := context.WithTimeout(ctx, interval)
+	defer cancel()
+
+	counts, err := repo.Count(ctx)
+	if err != nil {
+		logger.Warn("Failed to refresh todo gauges", zap.Error(err))
+		return
+	}
+
+	m.Todos.WithLabelValues("open").Set(float64(counts.Open))
+	m.Todos.WithLabelValues("completed").Set(float64(counts.Completed))
+}
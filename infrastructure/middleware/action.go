@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/problem"
+)
+
+// ActionMiddleware returns a gin middleware for routes registered as /:action, letting
+// through only the given custom methods, such as todos:batch. A custom method shares the
+// path segment of its collection, which gin cannot route, so it is matched as a whole.
+func ActionMiddleware(actions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !slices.Contains(actions, c.Param("action")) {
+			problem.Abort(c, problem.New(http.StatusNotFound, problem.CodeRouteNotFound, "No route matches the request"))
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/problem"
+)
+
+// AdminAuthMiddleware returns a gin middleware that only lets through requests
+// carrying the admin token as a bearer token
+func AdminAuthMiddleware(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		supplied, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(supplied), []byte(token)) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+			problem.Abort(c, problem.New(http.StatusUnauthorized, problem.CodeUnauthorized,
+				"A valid admin token is required"))
+			return
+		}
+		c.Next()
+	}
+}
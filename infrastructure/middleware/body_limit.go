@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/problem"
+)
+
+// BodyLimitMiddleware returns a gin middleware that rejects request bodies larger than maxBytes.
+// Declared lengths are rejected upfront with 413; chunked bodies fail when read past the limit.
+func BodyLimitMiddleware(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > maxBytes {
+			problem.Abort(c, problem.PayloadTooLarge(maxBytes))
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		c.Next()
+	}
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/config"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/logger"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/problem"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/subscription"
+	"go.uber.org/zap"
+)
+
+// CalDAVMiddleware returns a gin middleware that resolves the tenant of CalDAV requests. Calendar
+// clients sign in with HTTP Basic credentials, the tenant ID as user name and a CalDAV token as
+// password; the read-only tokens of subscription URLs are rejected. Requests without them are
+// challenged, so that clients prompt for credentials, unless a trusted gateway authenticated
+// them; those are resolved as by TenantMiddleware when they identify their tenant.
+func CalDAVMiddleware(tokens *subscription.Tokens, cfg config.TenantConfig) gin.HandlerFunc {
+	resolveTenant := TenantMiddleware(cfg)
+	return func(c *gin.Context) {
+		username, password, ok := c.Request.BasicAuth()
+		if !ok {
+			if !cfg.TrustedGateway || (c.GetHeader("Authorization") == "" && !identifiesTenant(c.Request, cfg)) {
+				abortCalDAVChallenge(c, "The request does not identify a tenant, sign in with a CalDAV account")
+				return
+			}
+			resolveTenant(c)
+			return
+		}
+
+		tenantID, err := tokens.Verify(password, subscription.ScopeCalDAV, time.Now())
+		if err != nil || string(tenantID) != username {
+			logger.FromContext(c.Request.Context()).Warn("Invalid CalDAV credentials", zap.Error(err))
+			abortCalDAVChallenge(c, "The user name must be the tenant ID and the password a valid CalDAV password")
+			return
+		}
+
+		bindTenant(c, tenantID)
+		c.Next()
+	}
+}
+
+// identifiesTenant reports whether any source of the request names a tenant
+func identifiesTenant(r *http.Request, cfg config.TenantConfig) bool {
+	for _, resolver := range cfg.Resolvers {
+		if resolveTenant(r, resolver, cfg) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// abortCalDAVChallenge rejects the request with a challenge for HTTP Basic credentials
+func abortCalDAVChallenge(c *gin.Context, detail string) {
+	c.Header("WWW-Authenticate", `Basic realm="todos", charset="UTF-8"`)
+	problem.Abort(c, problem.New(http.StatusUnauthorized, problem.CodeUnauthorized, detail))
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// bearerClaims decodes the claims of the JWT carried in the Authorization header.
+// The signature is not verified here: tokens are expected to be validated by the
+// gateway in front of the service before requests reach it.
+func bearerClaims(r *http.Request) (map[string]any, bool) {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return nil, false
+	}
+
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return nil, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, false
+	}
+
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, false
+	}
+	return claims, true
+}
+
+// stringClaim returns the named claim of the bearer token when it is a string
+func stringClaim(r *http.Request, name string) string {
+	claims, ok := bearerClaims(r)
+	if !ok {
+		return ""
+	}
+	value, _ := claims[name].(string)
+	return value
+}
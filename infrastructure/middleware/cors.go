@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"path"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/config"
+)
+
+// corsRoute is a per-route CORS handler
+type corsRoute struct {
+	pattern string
+	handler gin.HandlerFunc
+}
+
+// CORS applies a CORS policy that can be replaced at runtime
+type CORS struct {
+	extraHeaders []string
+	handler      atomic.Pointer[gin.HandlerFunc]
+}
+
+// NewCORS creates a CORS policy from cfg. The extra headers, such as the tenant and
+// API key headers whose names are configurable, are always allowed.
+func NewCORS(cfg config.CORSConfig, extraHeaders ...string) *CORS {
+	m := &CORS{extraHeaders: extraHeaders}
+	m.Update(cfg)
+	return m
+}
+
+// Update replaces the policy applied to subsequent requests
+func (m *CORS) Update(cfg config.CORSConfig) {
+	handler := newCORSPolicyHandler(cfg, m.extraHeaders)
+	m.handler.Store(&handler)
+}
+
+// Middleware returns a gin middleware applying the current policy.
+// Requests are handled before routing, so preflight requests are answered for every route.
+func (m *CORS) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		(*m.handler.Load())(c)
+	}
+}
+
+// newCORSPolicyHandler returns a handler applying the policy of cfg, or the override of
+// the first route whose pattern matches the request path
+func newCORSPolicyHandler(cfg config.CORSConfig, extraHeaders []string) gin.HandlerFunc {
+	defaultHandler := newCORSHandler(cfg, extraHeaders)
+
+	routes := make([]corsRoute, 0, len(cfg.Routes))
+	for _, route := range cfg.Routes {
+		routes = append(routes, corsRoute{
+			pattern: route.Path,
+			handler: newCORSHandler(cfg.PolicyFor(route), extraHeaders),
+		})
+	}
+
+	return func(c *gin.Context) {
+		for _, route := range routes {
+			if matched, _ := path.Match(route.pattern, c.Request.URL.Path); matched {
+				route.handler(c)
+				return
+			}
+		}
+		defaultHandler(c)
+	}
+}
+
+// newCORSHandler creates the handler of a single policy. A policy without origins
+// emits no CORS headers, so browsers block cross-origin requests.
+func newCORSHandler(policy config.CORSConfig, extraHeaders []string) gin.HandlerFunc {
+	if len(policy.AllowOrigins) == 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	allowHeaders := slices.Clone(policy.AllowHeaders)
+	for _, header := range extraHeaders {
+		if header != "" && !slices.ContainsFunc(allowHeaders, func(h string) bool { return strings.EqualFold(h, header) }) {
+			allowHeaders = append(allowHeaders, header)
+		}
+	}
+
+	corsConfig := cors.Config{
+		AllowMethods:     policy.AllowMethods,
+		AllowHeaders:     allowHeaders,
+		ExposeHeaders:    policy.ExposeHeaders,
+		AllowCredentials: policy.AllowCredentials,
+		MaxAge:           policy.MaxAge,
+		AllowWildcard:    true,
+	}
+	if slices.Contains(policy.AllowOrigins, "*") {
+		corsConfig.AllowAllOrigins = true
+	} else {
+		corsConfig.AllowOrigins = policy.AllowOrigins
+	}
+	return cors.New(corsConfig)
+}
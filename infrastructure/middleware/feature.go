@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/feature"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/problem"
+)
+
+// FeatureMiddleware returns a gin middleware that hides the route, answering 404 as for
+// an unknown route, while the named feature is disabled. On routes registered as /:action,
+// only the given custom methods are hidden when any are given.
+func FeatureMiddleware(flags *feature.Flags, name string, actions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		gated := len(actions) == 0 || slices.Contains(actions, c.Param("action"))
+		if gated && !flags.Enabled(name) {
+			problem.Abort(c, problem.New(http.StatusNotFound, problem.CodeRouteNotFound, "No route matches the request"))
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,218 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/repository"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/config"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/logger"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/problem"
+	"go.uber.org/zap"
+)
+
+// maxIdempotencyKeyLength bounds the size of client supplied keys
+const maxIdempotencyKeyLength = 255
+
+// maxStoredBodySize bounds the response bodies stored for replay, leaving room for the
+// headers of the record within the 400 KB DynamoDB item limit
+const maxStoredBodySize = 256 << 10
+
+// unreplayedHeaders lists response headers describing the current exchange rather than the stored response
+var unreplayedHeaders = []string{
+	"Date",
+	"Content-Length",
+	"Retry-After",
+	"RateLimit-Limit",
+	"RateLimit-Remaining",
+	"RateLimit-Reset",
+}
+
+// bodyCaptureWriter records the response body while writing it to the client, up to
+// maxStoredBodySize bytes
+type bodyCaptureWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (w *bodyCaptureWriter) Write(b []byte) (int, error) {
+	w.capture(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyCaptureWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// capture records b unless the body outgrows maxStoredBodySize
+func (w *bodyCaptureWriter) capture(b []byte) {
+	if w.truncated || w.body.Len()+len(b) > maxStoredBodySize {
+		w.truncated = true
+		w.body.Reset()
+		return
+	}
+	w.body.Write(b)
+}
+
+// IdempotencyMiddleware returns a gin middleware that stores the first response of a
+// request sent with an Idempotency-Key header and replays it for retries with the same key.
+// Reusing a key with a different payload is rejected with 422, and retries arriving while
+// the original request is still in flight are rejected with 409.
+func IdempotencyMiddleware(repo repository.IdempotencyRepository, cfg config.IdempotencyConfig) gin.HandlerFunc {
+	ttl, lockTimeout := cfg.TTL, cfg.LockTimeout
+
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			problem.Abort(c, problem.New(http.StatusBadRequest, problem.CodeIdempotencyKeyInvalid,
+				"The Idempotency-Key header must not exceed 255 characters"))
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			problem.Abort(c, problem.PayloadTooLarge(maxBytesErr.Limit))
+			return
+		case err != nil:
+			problem.Abort(c, problem.New(http.StatusBadRequest, problem.CodeInvalidRequest,
+				"The request body could not be read"))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		now := time.Now()
+		record := &entity.IdempotencyRecord{
+			Key:         key,
+			Fingerprint: requestFingerprint(c.Request, body),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(lockTimeout),
+		}
+
+		ctx := c.Request.Context()
+		existing, err := repo.Reserve(ctx, record)
+		if err != nil {
+			logger.FromContext(ctx).Error("Failed to reserve idempotency key", zap.Error(err))
+			problem.Abort(c, problem.New(http.StatusInternalServerError, problem.CodeInternal,
+				"An unexpected error occurred"))
+			return
+		}
+
+		if existing != nil {
+			switch {
+			case existing.Fingerprint != record.Fingerprint:
+				problem.Abort(c, problem.New(http.StatusUnprocessableEntity, problem.CodeIdempotencyKeyReused,
+					"The Idempotency-Key was already used with a different request"))
+			case !existing.Completed():
+				c.Header("Retry-After", "1")
+				problem.Abort(c, problem.New(http.StatusConflict, problem.CodeIdempotencyKeyInProgress,
+					"A request with the same Idempotency-Key is still in progress"))
+			default:
+				replayResponse(c, existing)
+			}
+			return
+		}
+
+		// The request may have been cancelled by the client, but the outcome must still be recorded
+		storeCtx := context.WithoutCancel(ctx)
+
+		// Unless its response is stored, the key is released so the client can retry, including
+		// when the handler panics
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			if err := repo.Release(storeCtx, key); err != nil {
+				logger.FromContext(ctx).Error("Failed to release idempotency key", zap.Error(err))
+			}
+		}()
+
+		writer := &bodyCaptureWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+
+		// Server errors are not final
+		status := writer.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		if writer.truncated {
+			logger.FromContext(ctx).Warn("Response too large to be replayed, releasing idempotency key",
+				zap.Int("limit", maxStoredBodySize))
+			return
+		}
+
+		record.StatusCode = status
+		record.Header = replayableHeader(writer.Header())
+		record.Body = writer.body.Bytes()
+		record.ExpiresAt = time.Now().Add(ttl)
+		if err := repo.Complete(storeCtx, record); err != nil {
+			logger.FromContext(ctx).Error("Failed to store idempotent response", zap.Error(err))
+			return
+		}
+		completed = true
+	}
+}
+
+// requestFingerprint hashes the parts of a request that must match for a key to be reused.
+// Query parameters and the Content-Type change how a request is handled, such as the format
+// and dry run of an import, so they are part of it. The query is canonicalised, so the order
+// of its parameters does not matter.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method))
+	h.Write([]byte{0})
+	h.Write([]byte(r.URL.Path))
+	h.Write([]byte{0})
+	h.Write([]byte(r.URL.Query().Encode()))
+	h.Write([]byte{0})
+	h.Write([]byte(r.Header.Get("Content-Type")))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// replayableHeader copies the response headers worth replaying
+func replayableHeader(header http.Header) map[string][]string {
+	replayable := header.Clone()
+	for _, name := range unreplayedHeaders {
+		replayable.Del(name)
+	}
+	return replayable
+}
+
+// replayResponse writes a stored response to the client
+func replayResponse(c *gin.Context, record *entity.IdempotencyRecord) {
+	for name, values := range record.Header {
+		for i, value := range values {
+			if i == 0 {
+				c.Writer.Header().Set(name, value)
+			} else {
+				c.Writer.Header().Add(name, value)
+			}
+		}
+	}
+	c.Header("Idempotent-Replayed", "true")
+	c.Status(record.StatusCode)
+	if len(record.Body) > 0 {
+		_, _ = c.Writer.Write(record.Body)
+	}
+	c.Abort()
+}
@@ -1,33 +1,40 @@
 package middleware
 
 import (
-	"strings"
+	"bytes"
+	"io"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/logger"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
-// LoggingMiddleware returns a gin middleware for request logging
-func LoggingMiddleware(logger *zap.Logger) gin.HandlerFunc {
+// maxLoggedBodyBytes bounds the size of request bodies logged at debug level
+const maxLoggedBodyBytes = 4 << 10
+
+// LoggingMiddleware returns a gin middleware for request logging.
+// Sensitive query parameters are masked by redactor; at debug level the request
+// headers and body are logged as well, with sensitive values masked.
+func LoggingMiddleware(redactor *logger.Redactor) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Request start time
 		start := time.Now()
 
 		// Get request information
 		path := c.Request.URL.Path
-		query := c.Request.URL.RawQuery
-
-		// Extract client IP
+		query := redactor.Query(c.Request.URL.RawQuery)
 		clientIP := c.ClientIP()
-		if forwardedFor := c.Request.Header.Get("X-Forwarded-For"); forwardedFor != "" {
-			// Use the first IP in the X-Forwarded-For header
-			clientIP = strings.Split(forwardedFor, ",")[0]
-		}
-
-		method := c.Request.Method
 		userAgent := c.Request.UserAgent()
 
+		debug := logger.FromContext(c.Request.Context()).Core().Enabled(zapcore.DebugLevel)
+		var body []byte
+		if debug && c.Request.Body != nil {
+			body, _ = io.ReadAll(io.LimitReader(c.Request.Body, maxLoggedBodyBytes))
+			c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(body), c.Request.Body), c.Request.Body}
+		}
+
 		// Process request
 		c.Next()
 
@@ -36,16 +43,31 @@ func LoggingMiddleware(logger *zap.Logger) gin.HandlerFunc {
 		statusCode := c.Writer.Status()
 		errorMessage := c.Errors.ByType(gin.ErrorTypePrivate).String()
 
-		// Log output
-		logger.Info("Request completed",
+		// The request-scoped logger carries the request ID, route, user and tenant
+		log := logger.FromContext(c.Request.Context())
+		fields := []zap.Field{
 			zap.String("path", path),
 			zap.String("query", query),
 			zap.Int("status", statusCode),
 			zap.String("latency", latency.String()),
 			zap.String("client_ip", clientIP),
-			zap.String("method", method),
 			zap.String("user_agent", userAgent),
 			zap.String("error", errorMessage),
-		)
+		}
+		if debug {
+			fields = append(fields,
+				zap.Any("request_headers", redactor.Header(c.Request.Header)),
+				zap.String("request_body", redactor.Body(body)),
+			)
+		}
+
+		// Log output
+		log.Info("Request completed", fields...)
 	}
 }
+
+// readCloser combines a reader with the closer of the original body
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,176 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/config"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/logger"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/problem"
+	"go.uber.org/zap"
+)
+
+// bucketIdleTTL is how long an untouched bucket is kept before it is evicted
+const bucketIdleTTL = 10 * time.Minute
+
+// tokenBucket holds the state of a single client on a single route
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter implements per-client token buckets for each route.
+// Its configuration can be replaced at runtime; buckets are kept across changes.
+type RateLimiter struct {
+	cfg       atomic.Pointer[config.RateLimitConfig]
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// NewRateLimiter creates a new RateLimiter instance
+func NewRateLimiter(cfg config.RateLimitConfig) *RateLimiter {
+	l := &RateLimiter{
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+	l.cfg.Store(&cfg)
+	return l
+}
+
+// SetConfig replaces the configuration applied to subsequent requests
+func (l *RateLimiter) SetConfig(cfg config.RateLimitConfig) {
+	l.cfg.Store(&cfg)
+}
+
+// rateLimitDecision is the outcome of taking a token from a bucket
+type rateLimitDecision struct {
+	allowed    bool
+	limit      int
+	remaining  int
+	reset      time.Duration
+	retryAfter time.Duration
+}
+
+// take removes one token from the bucket of client on route, refilling it first. Once
+// maxClients buckets are kept, new clients share the bucket of the route until idle buckets
+// are evicted.
+func (l *RateLimiter) take(route, client string, rule config.RateLimitRule, maxClients int, now time.Time) rateLimitDecision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	capacity := float64(rule.Burst)
+	key := route + "|" + client
+	b, ok := l.buckets[key]
+	if !ok && len(l.buckets) >= maxClients {
+		key = route + "|*"
+		b, ok = l.buckets[key]
+	}
+	if !ok {
+		b = &tokenBucket{tokens: capacity, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*rule.RequestsPerSecond)
+	b.last = now
+
+	decision := rateLimitDecision{limit: rule.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		decision.allowed = true
+	} else {
+		decision.retryAfter = secondsToDuration((1 - b.tokens) / rule.RequestsPerSecond)
+	}
+	decision.remaining = int(b.tokens)
+	decision.reset = secondsToDuration((capacity - b.tokens) / rule.RequestsPerSecond)
+	return decision
+}
+
+// sweep evicts idle buckets so memory does not grow with the number of clients seen
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < bucketIdleTTL {
+		return
+	}
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > bucketIdleTTL {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// clientKey identifies the caller using the first identity available in KeyBy. The API key
+// and the user are authenticated by the gateway in front of the service, which configuration
+// validation requires for them.
+func clientKey(c *gin.Context, cfg *config.RateLimitConfig) string {
+	for _, keyBy := range cfg.KeyBy {
+		switch keyBy {
+		case "api_key":
+			if apiKey := c.GetHeader(cfg.APIKeyHeader); apiKey != "" {
+				return "api_key:" + apiKey
+			}
+		case "user":
+			if user := stringClaim(c.Request, cfg.UserClaim); user != "" {
+				return "user:" + user
+			}
+		case "ip":
+			return "ip:" + c.ClientIP()
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// RateLimitMiddleware returns a gin middleware that rejects clients exceeding their
+// token bucket with 429 and reports the bucket state in RateLimit-* headers
+func RateLimitMiddleware(limiter *RateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := limiter.cfg.Load()
+		if !cfg.Enabled {
+			c.Next()
+			return
+		}
+
+		route := c.Request.Method + " " + c.FullPath()
+		rule := cfg.RuleFor(route)
+		if rule.RequestsPerSecond <= 0 || rule.Burst <= 0 {
+			// A zero rule disables rate limiting for the route
+			c.Next()
+			return
+		}
+
+		decision := limiter.take(route, clientKey(c, cfg), rule, cfg.MaxClients, time.Now())
+
+		c.Header("RateLimit-Limit", strconv.Itoa(decision.limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(decision.remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.reset)))
+
+		if !decision.allowed {
+			logger.FromContext(c.Request.Context()).Warn("Rate limit exceeded",
+				zap.String("client_ip", c.ClientIP()),
+			)
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(decision.retryAfter)))
+			problem.Abort(c, problem.New(http.StatusTooManyRequests, problem.CodeRateLimited,
+				"The client exceeded the rate limit of the route"))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// secondsToDuration converts fractional seconds to a time.Duration
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// ceilSeconds rounds d up to whole seconds, as used by the Retry-After and RateLimit-Reset headers
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/config"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/logger"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/requestid"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// RequestLoggerMiddleware returns a gin middleware that binds a request-scoped logger to the
+// request context, annotated once with the request ID, method, route template, trace and user.
+// TenantMiddleware adds the tenant when it resolves one.
+func RequestLoggerMiddleware(base *zap.Logger, cfg config.LoggingConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		fields := []zap.Field{
+			zap.String("request_id", requestid.FromContext(c.Request.Context())),
+			zap.String("method", c.Request.Method),
+			zap.String("route", c.FullPath()),
+		}
+		if span := trace.SpanContextFromContext(c.Request.Context()); span.IsValid() {
+			fields = append(fields,
+				zap.String("trace_id", span.TraceID().String()),
+				zap.String("span_id", span.SpanID().String()),
+			)
+		}
+		if user := stringClaim(c.Request, cfg.UserClaim); user != "" {
+			fields = append(fields, zap.String("user", user))
+		}
+
+		ctx := logger.NewContext(c.Request.Context(), base.With(fields...))
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
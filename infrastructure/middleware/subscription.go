@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/config"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/logger"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/problem"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/subscription"
+	"go.uber.org/zap"
+)
+
+// SubscriptionMiddleware returns a gin middleware that resolves the tenant of calendar
+// feed requests from the token query parameter of their subscription URL, as calendar
+// clients cannot send headers. Requests without a token are rejected, unless a trusted
+// gateway authenticated them; those are resolved as by TenantMiddleware.
+func SubscriptionMiddleware(tokens *subscription.Tokens, cfg config.TenantConfig) gin.HandlerFunc {
+	resolveTenant := TenantMiddleware(cfg)
+	return func(c *gin.Context) {
+		token, ok := c.GetQuery("token")
+		if !ok && cfg.TrustedGateway {
+			resolveTenant(c)
+			return
+		}
+		if !ok {
+			problem.Abort(c, problem.New(http.StatusUnauthorized, problem.CodeUnauthorized,
+				"The calendar feed requires the token of a subscription URL"))
+			return
+		}
+
+		tenantID, err := tokens.Verify(token, subscription.ScopeFeed, time.Now())
+		if err != nil {
+			logger.FromContext(c.Request.Context()).Warn("Invalid subscription token", zap.Error(err))
+			problem.Abort(c, problem.New(http.StatusUnauthorized, problem.CodeUnauthorized,
+				"The subscription URL is invalid or has expired"))
+			return
+		}
+
+		bindTenant(c, tenantID)
+		c.Next()
+	}
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/tenant"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/config"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/logger"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/problem"
+	"go.uber.org/zap"
+)
+
+// TenantMiddleware returns a gin middleware that resolves the tenant of the request
+// and binds it to the request context and the request-scoped logger. Requests without a tenant, or whose
+// sources disagree on the tenant, are rejected before reaching the handlers.
+func TenantMiddleware(cfg config.TenantConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var resolved string
+		for _, resolver := range cfg.Resolvers {
+			candidate := resolveTenant(c.Request, resolver, cfg)
+			if candidate == "" {
+				continue
+			}
+			if resolved != "" && resolved != candidate {
+				logger.FromContext(c.Request.Context()).Warn("Conflicting tenant identifiers",
+					zap.String("resolver", resolver),
+				)
+				problem.Abort(c, problem.New(http.StatusBadRequest, problem.CodeTenantConflict,
+					"The tenant identifiers of the request do not match"))
+				return
+			}
+			resolved = candidate
+		}
+
+		if resolved == "" {
+			problem.Abort(c, problem.New(http.StatusBadRequest, problem.CodeTenantMissing,
+				"The request does not identify a tenant"))
+			return
+		}
+
+		tenantID, err := tenant.Parse(resolved)
+		if err != nil {
+			logger.FromContext(c.Request.Context()).Warn("Invalid tenant identifier",
+				zap.Error(err),
+			)
+			problem.Abort(c, problem.New(http.StatusBadRequest, problem.CodeTenantInvalid,
+				"Tenant identifiers consist of up to 64 letters, digits, hyphens and underscores"))
+			return
+		}
+
+		bindTenant(c, tenantID)
+		c.Next()
+	}
+}
+
+// bindTenant binds the tenant to the request context and the request-scoped logger
+func bindTenant(c *gin.Context, tenantID tenant.ID) {
+	ctx := tenant.NewContext(c.Request.Context(), tenantID)
+	ctx = logger.NewContext(ctx, logger.FromContext(ctx).With(zap.String("tenant_id", string(tenantID))))
+	c.Request = c.Request.WithContext(ctx)
+}
+
+// resolveTenant reads the tenant identifier from a single source
+func resolveTenant(r *http.Request, resolver string, cfg config.TenantConfig) string {
+	switch resolver {
+	case "header":
+		return strings.TrimSpace(r.Header.Get(cfg.Header))
+	case "subdomain":
+		return tenantFromHost(r.Host, cfg.BaseDomain)
+	case "claim":
+		return stringClaim(r, cfg.Claim)
+	default:
+		return ""
+	}
+}
+
+// tenantFromHost extracts the left-most label of host when it is a direct subdomain of baseDomain
+func tenantFromHost(host, baseDomain string) string {
+	if baseDomain == "" {
+		return ""
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	sub, found := strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(baseDomain))
+	if !found || sub == "" || strings.Contains(sub, ".") {
+		return ""
+	}
+	return sub
+}
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// TracingMiddleware returns a gin middleware that extracts the W3C trace context of the request
+// and wraps it in a server span named after the route template.
+// Requests to the untraced paths, such as probes and metric scrapes, get no span.
+func TracingMiddleware(service string, untraced ...string) gin.HandlerFunc {
+	skip := make(map[string]bool, len(untraced))
+	for _, path := range untraced {
+		skip[path] = true
+	}
+
+	return otelgin.Middleware(service, otelgin.WithGinFilter(func(c *gin.Context) bool {
+		return !skip[c.FullPath()]
+	}))
+}
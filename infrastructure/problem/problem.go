@@ -0,0 +1,150 @@
+package problem
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/requestid"
+)
+
+// ContentType is the media type of problem details (RFC 9457)
+const ContentType = "application/problem+json"
+
+// typePrefix prefixes the code of a problem to build its type URI
+const typePrefix = "urn:todo-api:problem:"
+
+// Code is a stable, machine readable identifier of a problem type
+type Code string
+
+const (
+	CodeInvalidRequest           Code = "invalid-request"
+	CodeValidationFailed         Code = "validation-failed"
+	CodeInvalidQuery             Code = "invalid-query"
+	CodePayloadTooLarge          Code = "payload-too-large"
+	CodeInvalidTodoID            Code = "invalid-todo-id"
+	CodeTodoNotFound             Code = "todo-not-found"
+	CodeRouteNotFound            Code = "route-not-found"
+	CodeQuotaExceeded            Code = "quota-exceeded"
+	CodeTenantMissing            Code = "tenant-missing"
+	CodeTenantInvalid            Code = "tenant-invalid"
+	CodeTenantConflict           Code = "tenant-conflict"
+	CodeRateLimited              Code = "rate-limited"
+	CodeIdempotencyKeyInvalid    Code = "idempotency-key-invalid"
+	CodeIdempotencyKeyReused     Code = "idempotency-key-reused"
+	CodeIdempotencyKeyInProgress Code = "idempotency-key-in-progress"
+	CodeParentNotFound           Code = "parent-not-found"
+	CodeParentCycle              Code = "parent-cycle"
+	CodeConcurrentModification   Code = "concurrent-modification"
+	CodeTransactionConflict      Code = "transaction-conflict"
+	CodeTodoExists               Code = "todo-exists"
+	CodeTransactionTooLarge      Code = "transaction-too-large"
+	CodeDuplicateTarget          Code = "duplicate-target"
+	CodeBatchAborted             Code = "batch-aborted"
+	CodeWriteUnprocessed         Code = "write-unprocessed"
+	CodeInvalidImportFile        Code = "invalid-import-file"
+	CodeInvalidImportID          Code = "invalid-import-id"
+	CodeImportNotFound           Code = "import-not-found"
+	CodeUnauthorized             Code = "unauthorized"
+	CodePreconditionFailed       Code = "precondition-failed"
+	CodeInvalidCalendarData      Code = "invalid-calendar-data"
+	CodeConfigInvalid            Code = "config-invalid"
+	CodeShuttingDown             Code = "shutting-down"
+	CodeInternal                 Code = "internal-error"
+)
+
+// titles holds the short summary of each problem type, which does not change between occurrences
+var titles = map[Code]string{
+	CodeInvalidRequest:           "Invalid request",
+	CodeValidationFailed:         "Validation failed",
+	CodeInvalidQuery:             "Invalid query parameters",
+	CodePayloadTooLarge:          "Request body too large",
+	CodeInvalidTodoID:            "Invalid todo ID",
+	CodeTodoNotFound:             "Todo not found",
+	CodeRouteNotFound:            "Route not found",
+	CodeQuotaExceeded:            "Todo quota exceeded",
+	CodeTenantMissing:            "Tenant not specified",
+	CodeTenantInvalid:            "Invalid tenant identifier",
+	CodeTenantConflict:           "Conflicting tenant identifiers",
+	CodeRateLimited:              "Too many requests",
+	CodeIdempotencyKeyInvalid:    "Invalid Idempotency-Key header",
+	CodeIdempotencyKeyReused:     "Idempotency-Key reused with a different request",
+	CodeIdempotencyKeyInProgress: "Idempotency-Key request in progress",
+	CodeParentNotFound:           "Parent todo not found",
+	CodeParentCycle:              "Invalid parent todo",
+	CodeConcurrentModification:   "Concurrent modification",
+	CodeTransactionConflict:      "Transaction conflict",
+	CodeTodoExists:               "Todo already exists",
+	CodeTransactionTooLarge:      "Too many todos",
+	CodeDuplicateTarget:          "Todo targeted twice",
+	CodeBatchAborted:             "Batch aborted",
+	CodeWriteUnprocessed:         "Operation not processed",
+	CodeInvalidImportFile:        "Invalid import file",
+	CodeInvalidImportID:          "Invalid import ID",
+	CodeImportNotFound:           "Import not found",
+	CodeUnauthorized:             "Authentication required",
+	CodePreconditionFailed:       "Precondition failed",
+	CodeInvalidCalendarData:      "Invalid calendar data",
+	CodeConfigInvalid:            "Invalid configuration",
+	CodeShuttingDown:             "Server shutting down",
+	CodeInternal:                 "Internal server error",
+}
+
+// Problem represents an RFC 9457 problem details object
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      Code   `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+	// Errors lists the individual violations of a validation problem
+	Errors any `json:"errors,omitempty"`
+}
+
+// New creates a Problem of the given status and code
+func New(status int, code Code, detail string) *Problem {
+	title, ok := titles[code]
+	if !ok {
+		title = http.StatusText(status)
+	}
+
+	return &Problem{
+		Type:   typePrefix + string(code),
+		Title:  title,
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// PayloadTooLarge creates the Problem of a request body exceeding limit bytes
+func PayloadTooLarge(limit int64) *Problem {
+	return New(http.StatusRequestEntityTooLarge, CodePayloadTooLarge,
+		fmt.Sprintf("The request body must not exceed %d bytes", limit))
+}
+
+// Abort writes p as the response of the request and stops the handler chain
+func Abort(c *gin.Context, p *Problem) {
+	p.Instance = c.Request.URL.Path
+	p.RequestID = requestid.FromContext(c.Request.Context())
+
+	c.Render(p.Status, problemRender{p})
+	c.Abort()
+}
+
+// problemRender renders a Problem with the problem+json content type, which gin.JSON would overwrite
+type problemRender struct {
+	problem *Problem
+}
+
+func (r problemRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return json.NewEncoder(w).Encode(r.problem)
+}
+
+func (r problemRender) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", ContentType)
+}
@@ -0,0 +1,35 @@
+package requestid
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/google/uuid"
+)
+
+// Header is the HTTP header carrying the request ID
+const Header = "X-Request-ID"
+
+// pattern restricts client supplied IDs to safe characters so they can be logged and echoed verbatim
+var pattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type contextKey struct{}
+
+// Resolve returns the client supplied ID when it is well-formed, or a new one otherwise
+func Resolve(supplied string) string {
+	if pattern.MatchString(supplied) {
+		return supplied
+	}
+	return uuid.NewString()
+}
+
+// NewContext returns a copy of ctx carrying the request ID
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID carried by ctx, or an empty string
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
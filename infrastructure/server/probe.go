@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/config"
+)
+
+// Probe requests path from the server described by cfg on the local host and
+// fails unless it answers 200. It backs the healthcheck command of the container image.
+func Probe(cfg config.ServerConfig, path string) error {
+	transport := &http.Transport{}
+	scheme := "http"
+	host := net.JoinHostPort("127.0.0.1", strconv.Itoa(cfg.Port))
+
+	if cfg.UnixSocket != "" {
+		host = "localhost"
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", cfg.UnixSocket)
+		}
+	}
+
+	if cfg.TLS.Enabled() {
+		scheme = "https"
+		// The server is probing itself, so its certificate need not match the loopback address
+		tlsConfig := &tls.Config{InsecureSkipVerify: true}
+		if cfg.TLS.ClientAuth != "none" {
+			// Present the server certificate when client certificates are expected
+			cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+			if err != nil {
+				return err
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	client := &http.Client{Transport: transport, Timeout: 3 * time.Second}
+	resp, err := client.Get(scheme + "://" + host + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered %s", path, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/config"
+	"go.uber.org/zap"
+)
+
+// Server serves the application over TCP or a Unix domain socket, optionally with TLS
+type Server struct {
+	cfg    config.ServerConfig
+	srv    *http.Server
+	certs  *certReloader
+	stop   chan struct{}
+	logger *zap.Logger
+}
+
+// New creates a Server for handler. It fails when the TLS files cannot be loaded.
+func New(cfg config.ServerConfig, handler http.Handler, logger *zap.Logger) (*Server, error) {
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(cfg.HTTP2 && cfg.TLS.Enabled())
+	protocols.SetUnencryptedHTTP2(cfg.H2C)
+
+	s := &Server{
+		cfg:  cfg,
+		stop: make(chan struct{}),
+		srv: &http.Server{
+			Addr:              net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+			Handler:           handler,
+			ReadTimeout:       cfg.ReadTimeout,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+			MaxHeaderBytes:    cfg.MaxHeaderBytes,
+			Protocols:         &protocols,
+			ErrorLog:          zap.NewStdLog(logger),
+		},
+		logger: logger,
+	}
+
+	if cfg.TLS.Enabled() {
+		tlsConfig, certs, err := newTLSConfig(cfg.TLS, logger)
+		if err != nil {
+			return nil, err
+		}
+		s.srv.TLSConfig = tlsConfig
+		s.certs = certs
+	}
+	return s, nil
+}
+
+// Listen binds the configured Unix domain socket or TCP address
+func (s *Server) Listen() (net.Listener, error) {
+	if s.cfg.UnixSocket == "" {
+		return net.Listen("tcp", s.srv.Addr)
+	}
+
+	// A socket file left behind by a previous process would make the bind fail
+	if err := os.Remove(s.cfg.UnixSocket); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+	return net.Listen("unix", s.cfg.UnixSocket)
+}
+
+// Serve accepts connections on l until the server is shut down
+func (s *Server) Serve(l net.Listener) error {
+	var err error
+	if s.certs != nil {
+		go s.certs.watch(s.cfg.TLS.ReloadInterval, s.stop)
+		err = s.srv.ServeTLS(l, "", "")
+	} else {
+		err = s.srv.Serve(l)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown gracefully stops the server, waiting for in-flight requests until ctx is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	close(s.stop)
+	err := s.srv.Shutdown(ctx)
+	if s.cfg.UnixSocket != "" {
+		os.Remove(s.cfg.UnixSocket)
+	}
+	return err
+}
+
+// Address describes where the server listens, for logging
+func (s *Server) Address() string {
+	if s.cfg.UnixSocket != "" {
+		return "unix:" + s.cfg.UnixSocket
+	}
+	return s.srv.Addr
+}
+
+// newTLSConfig builds the TLS configuration, serving the certificate through a reloader
+func newTLSConfig(cfg config.TLSConfig, logger *zap.Logger) (*tls.Config, *certReloader, error) {
+	certs, err := newCertReloader(cfg.CertFile, cfg.KeyFile, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: certs.GetCertificate,
+	}
+	if cfg.MinVersion == "1.3" {
+		tlsConfig.MinVersion = tls.VersionTLS13
+	}
+
+	switch cfg.ClientAuth {
+	case "request":
+		tlsConfig.ClientAuth = tls.RequestClientCert
+	case "verify_if_given":
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	case "require":
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, nil, fmt.Errorf("no certificate found in client CA file %s", cfg.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+	}
+
+	return tlsConfig, certs, nil
+}
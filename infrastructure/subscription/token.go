@@ -0,0 +1,103 @@
+package subscription
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/tenant"
+)
+
+// ErrInvalidToken is returned for tokens that are malformed, forged, expired or of another scope
+var ErrInvalidToken = errors.New("invalid subscription token")
+
+// Scope is what a token grants access to
+type Scope string
+
+const (
+	// ScopeFeed grants reading the calendar feed, as the token of a subscription URL
+	ScopeFeed Scope = "feed"
+	// ScopeCalDAV grants reading and writing the todos over CalDAV, as the password of a CalDAV account
+	ScopeCalDAV Scope = "caldav"
+)
+
+// Tokens issues and verifies the tokens of calendar subscription URLs and CalDAV accounts.
+// A token carries its tenant, scope and expiry signed with HMAC-SHA256, so it is verified
+// without any storage; changing the secret revokes every token issued before.
+type Tokens struct {
+	secret []byte
+	ttls   map[Scope]time.Duration
+}
+
+// NewTokens creates a new Tokens instance issuing tokens of each scope valid for its TTL in
+// ttls. Tokens of a scope without a TTL never expire, and none is valid when secret is empty.
+func NewTokens(secret string, ttls map[Scope]time.Duration) *Tokens {
+	return &Tokens{secret: []byte(secret), ttls: ttls}
+}
+
+// Enabled reports whether tokens can be issued
+func (t *Tokens) Enabled() bool {
+	return len(t.secret) > 0
+}
+
+// Issue returns a token of the given tenant and scope and when it expires, the zero time
+// when it never does
+func (t *Tokens) Issue(tenantID tenant.ID, scope Scope, now time.Time) (string, time.Time) {
+	var expiresAt time.Time
+	expires := int64(0)
+	if ttl := t.ttls[scope]; ttl > 0 {
+		expiresAt = now.Add(ttl).Truncate(time.Second)
+		expires = expiresAt.Unix()
+	}
+
+	// Tenant IDs and scopes have no dots, so the token splits back into its parts
+	payload := string(tenantID) + "." + string(scope) + "." + strconv.FormatInt(expires, 10)
+	return payload + "." + t.sign(payload), expiresAt
+}
+
+// Verify returns the tenant of token, which must be of the given scope
+func (t *Tokens) Verify(token string, scope Scope, now time.Time) (tenant.ID, error) {
+	if !t.Enabled() {
+		return "", ErrInvalidToken
+	}
+
+	payload, signature, ok := cutLast(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(t.sign(payload))) {
+		return "", ErrInvalidToken
+	}
+
+	parts := strings.Split(payload, ".")
+	if len(parts) != 3 || Scope(parts[1]) != scope {
+		return "", ErrInvalidToken
+	}
+	expires, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || (expires != 0 && !now.Before(time.Unix(expires, 0))) {
+		return "", ErrInvalidToken
+	}
+
+	tenantID, err := tenant.Parse(parts[0])
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	return tenantID, nil
+}
+
+// sign returns the URL-safe signature of payload
+func (t *Tokens) sign(payload string) string {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// cutLast slices s around the last instance of sep
+func cutLast(s, sep string) (string, string, bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
@@ -0,0 +1,136 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// ServiceName is the service name reported unless OTEL_SERVICE_NAME overrides it
+const ServiceName = "todo-api"
+
+// defaultTraceFile is where the file exporter writes when OTEL_EXPORTER_FILE_PATH is not set
+const defaultTraceFile = "traces.jsonl"
+
+// NewTracerProvider creates the tracer provider from the standard OTEL_* environment variables
+// and installs it, together with the W3C trace context and baggage propagators, as the global one.
+//
+// OTEL_TRACES_EXPORTER selects the exporter: otlp, console, file or none. It defaults to otlp
+// when an OTLP endpoint is configured and to none otherwise, so local runs do not
+// try to reach a collector that does not exist.
+func NewTracerProvider(ctx context.Context) (*sdktrace.TracerProvider, error) {
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(ServiceName)),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	options := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+
+	exporter, err := newExporter(ctx, exporterName())
+	if err != nil {
+		return nil, err
+	}
+	if exporter != nil {
+		options = append(options, sdktrace.WithBatcher(exporter))
+	}
+
+	provider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	return provider, nil
+}
+
+// exporterName returns the exporter selected by the environment
+func exporterName() string {
+	if name := os.Getenv("OTEL_TRACES_EXPORTER"); name != "" {
+		return name
+	}
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+		return "otlp"
+	}
+	return "none"
+}
+
+// newExporter creates the named span exporter. A nil exporter means spans are not exported.
+func newExporter(ctx context.Context, name string) (sdktrace.SpanExporter, error) {
+	switch name {
+	case "otlp":
+		return newOTLPExporter(ctx)
+	case "console":
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "file":
+		return newFileExporter()
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported OTEL_TRACES_EXPORTER: %q", name)
+	}
+}
+
+// newOTLPExporter creates an OTLP exporter for the protocol selected by the environment.
+// Endpoint, headers, TLS and timeouts are read by the exporter from the OTEL_EXPORTER_OTLP_* variables.
+func newOTLPExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	protocol := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
+	if protocol == "" {
+		protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+
+	switch protocol {
+	case "", "http/protobuf":
+		return otlptracehttp.New(ctx)
+	case "grpc":
+		return otlptracegrpc.New(ctx)
+	default:
+		return nil, fmt.Errorf("unsupported OTEL_EXPORTER_OTLP_PROTOCOL: %q", protocol)
+	}
+}
+
+// fileExporter writes spans as JSON lines to a file and closes it on shutdown
+type fileExporter struct {
+	*stdouttrace.Exporter
+	file *os.File
+}
+
+// newFileExporter creates an exporter appending to OTEL_EXPORTER_FILE_PATH
+func newFileExporter() (sdktrace.SpanExporter, error) {
+	path := os.Getenv("OTEL_EXPORTER_FILE_PATH")
+	if path == "" {
+		path = defaultTraceFile
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trace file: %w", err)
+	}
+
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &fileExporter{Exporter: exporter, file: file}, nil
+}
+
+// Shutdown flushes the exporter and closes the file
+func (e *fileExporter) Shutdown(ctx context.Context) error {
+	if err := e.Exporter.Shutdown(ctx); err != nil {
+		return err
+	}
+	return e.file.Close()
+}
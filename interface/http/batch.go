@@ -0,0 +1,166 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/problem"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/usecase/todo"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxBatchOperations bounds the operations of a batch, the item limit of a DynamoDB transaction
+	maxBatchOperations = 100
+	// maxBatchBodyBytes bounds the size of batch request bodies
+	maxBatchBodyBytes = 1 << 20
+)
+
+// BatchRequest represents the request body of POST /todos:batch
+type BatchRequest struct {
+	// Atomic applies either every operation or none of them
+	Atomic     bool              `json:"atomic"`
+	Operations []json.RawMessage `json:"operations"`
+}
+
+// BatchOperationRequest represents a single operation of a batch
+type BatchOperationRequest struct {
+	Op   string          `json:"op" binding:"required,oneof=create update delete"`
+	ID   string          `json:"id"`
+	Todo json.RawMessage `json:"todo"`
+}
+
+// BatchResponse represents the response body of POST /todos:batch
+type BatchResponse struct {
+	Results []BatchResultResponse `json:"results"`
+}
+
+// BatchResultResponse represents the outcome of a single operation, in the order of the request
+type BatchResultResponse struct {
+	Status int              `json:"status"`
+	Todo   *TodoResponse    `json:"todo,omitempty"`
+	Error  *problem.Problem `json:"error,omitempty"`
+}
+
+// BatchTodos handles creating, updating and deleting todos in a single request.
+// Each operation is reported with its own status in a 207 Multi-Status response.
+func (h *TodoHandler) BatchTodos(c *gin.Context) {
+	body, err := readBody(c, maxBatchBodyBytes)
+	if err != nil {
+		h.respondError(c, "Invalid request body", err)
+		return
+	}
+
+	var request BatchRequest
+	if err := decodeJSON(body, &request); err != nil {
+		h.respondError(c, "Invalid request body", err)
+		return
+	}
+	if len(request.Operations) == 0 || len(request.Operations) > maxBatchOperations {
+		h.respondError(c, "Invalid request body", &ValidationError{Fields: []FieldError{{
+			Field:   "operations",
+			Rule:    "len",
+			Message: "must contain between 1 and 100 operations",
+		}}})
+		return
+	}
+
+	ops := make([]todo.BatchOperation, len(request.Operations))
+	for i, raw := range request.Operations {
+		ops[i] = parseBatchOperation(raw)
+	}
+
+	results, err := h.useCase.ExecuteBatch(c.Request.Context(), ops, request.Atomic)
+	if err != nil {
+		h.respondError(c, "Failed to execute batch", err, zap.Int("operations", len(ops)))
+		return
+	}
+
+	response := BatchResponse{Results: make([]BatchResultResponse, len(results))}
+	for i, result := range results {
+		response.Results[i] = newBatchResultResponse(c, ops[i].Op, result)
+	}
+	c.JSON(http.StatusMultiStatus, response)
+}
+
+// parseBatchOperation decodes and validates an operation. An invalid operation is
+// returned with its error set, so it is reported without failing the whole batch.
+func parseBatchOperation(raw json.RawMessage) todo.BatchOperation {
+	var request BatchOperationRequest
+	if err := decodeJSON(raw, &request); err != nil {
+		return todo.BatchOperation{Err: err}
+	}
+
+	op := todo.BatchOperation{Op: todo.BatchOp(request.Op)}
+	var fieldErrors []FieldError
+
+	if op.Op == todo.BatchCreate {
+		if request.ID != "" {
+			fieldErrors = append(fieldErrors, FieldError{Field: "id", Rule: "excluded", Message: "must not be set when creating"})
+		}
+	} else if id, err := uuid.Parse(request.ID); err != nil {
+		fieldErrors = append(fieldErrors, FieldError{Field: "id", Rule: "uuid", Message: "must be a UUID"})
+	} else {
+		op.ID = id
+	}
+
+	switch {
+	case op.Op == todo.BatchDelete && request.Todo != nil:
+		fieldErrors = append(fieldErrors, FieldError{Field: "todo", Rule: "excluded", Message: "must not be set when deleting"})
+	case op.Op != todo.BatchDelete && request.Todo == nil:
+		fieldErrors = append(fieldErrors, FieldError{Field: "todo", Rule: "required", Message: "is required"})
+	case op.Op == todo.BatchCreate:
+		var todoRequest CreateTodoRequest
+		fieldErrors = append(fieldErrors, todoFieldErrors(decodeJSON(request.Todo, &todoRequest))...)
+		op.Create = todoRequest.toEntity()
+	case op.Op == todo.BatchUpdate:
+		var todoRequest UpdateTodoRequest
+		fieldErrors = append(fieldErrors, todoFieldErrors(decodeJSON(request.Todo, &todoRequest))...)
+		op.Update = todoRequest.toEntity()
+	}
+
+	if len(fieldErrors) > 0 {
+		op.Err = &ValidationError{Fields: fieldErrors}
+	}
+	return op
+}
+
+// todoFieldErrors returns the violations of a todo nested in an operation, named after their path
+func todoFieldErrors(err error) []FieldError {
+	if err == nil {
+		return nil
+	}
+	validationErr, ok := err.(*ValidationError)
+	if !ok {
+		return []FieldError{{Field: "todo", Rule: "type", Message: "must be an object"}}
+	}
+
+	fieldErrors := make([]FieldError, 0, len(validationErr.Fields))
+	for _, fe := range validationErr.Fields {
+		fe.Field = "todo." + fe.Field
+		fieldErrors = append(fieldErrors, fe)
+	}
+	return fieldErrors
+}
+
+// newBatchResultResponse converts the outcome of an operation to its wire format
+func newBatchResultResponse(c *gin.Context, op todo.BatchOp, result todo.BatchResult) BatchResultResponse {
+	if result.Err != nil {
+		p := problemFor(result.Err)
+		p.Instance = c.Request.URL.Path
+		return BatchResultResponse{Status: p.Status, Error: p}
+	}
+
+	switch op {
+	case todo.BatchCreate:
+		response := newTodoResponse(result.Todo)
+		return BatchResultResponse{Status: http.StatusCreated, Todo: &response}
+	case todo.BatchUpdate:
+		response := newTodoResponse(result.Todo)
+		return BatchResultResponse{Status: http.StatusOK, Todo: &response}
+	default:
+		return BatchResultResponse{Status: http.StatusNoContent}
+	}
+}
@@ -0,0 +1,599 @@
+package http
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/tenant"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/usecase/todo"
+	"go.uber.org/zap"
+)
+
+const (
+	// calDAVRootPath is the principal and calendar home of the tenant
+	calDAVRootPath = "/caldav/"
+	// calDAVCollectionPath is the calendar collection holding a <todo ID>.ics resource per todo
+	calDAVCollectionPath = calDAVRootPath + "todos/"
+	// calDAVResourceSuffix ends the names of todo resources
+	calDAVResourceSuffix = ".ics"
+	// calDAVSyncTokenPrefix starts the sync tokens, which must be URIs (RFC 6578, section 3.2)
+	calDAVSyncTokenPrefix = "urn:todos:sync:"
+	// calDAVTodoContentType is the content type of todo resources
+	calDAVTodoContentType = "text/calendar; charset=utf-8; component=VTODO"
+)
+
+// CalendarDataError is returned when the body of a CalDAV PUT is not a single valid VTODO
+type CalendarDataError struct {
+	Reason string
+}
+
+func (e *CalendarDataError) Error() string {
+	return "invalid calendar data: " + e.Reason
+}
+
+// CalDAVHandler handles the CalDAV requests of calendar clients syncing todos both ways
+// (RFC 4791). Every todo is a VTODO resource of a single calendar collection, whose ETag
+// changes with each update so that conflicting writes fail with 412 Precondition Failed.
+type CalDAVHandler struct {
+	useCase *todo.TodoUseCase
+}
+
+// NewCalDAVHandler creates a new CalDAVHandler instance
+func NewCalDAVHandler(useCase *todo.TodoUseCase) *CalDAVHandler {
+	return &CalDAVHandler{useCase: useCase}
+}
+
+// WellKnown handles the discovery of the CalDAV root by clients given only the host (RFC 6764)
+func (h *CalDAVHandler) WellKnown(c *gin.Context) {
+	c.Redirect(http.StatusMovedPermanently, calDAVRootPath)
+}
+
+// Options handles advertising the WebDAV classes and methods supported by the server
+func (h *CalDAVHandler) Options(c *gin.Context) {
+	c.Header("DAV", "1, 3, calendar-access")
+	c.Header("Allow", "OPTIONS, GET, PUT, DELETE, PROPFIND, PROPPATCH, REPORT")
+	c.Status(http.StatusOK)
+}
+
+// PropfindRoot handles reading the properties of the principal of the tenant, which is also
+// its calendar home, and with a Depth of 1 those of the todo collection
+func (h *CalDAVHandler) PropfindRoot(c *gin.Context) {
+	find, err := parsePropfind(c)
+	if err != nil {
+		respondError(c, "Invalid PROPFIND body", err)
+		return
+	}
+	tenantID, err := tenant.FromContext(c.Request.Context())
+	if err != nil {
+		respondError(c, "Failed to read CalDAV properties", err)
+		return
+	}
+
+	m := newDAVMultistatus()
+	m.add(rootResource(tenantID), find)
+	if c.GetHeader("Depth") != "0" {
+		_, state, err := h.useCase.SyncTodos(c.Request.Context(), nil)
+		if err != nil {
+			respondError(c, "Failed to read CalDAV properties", err)
+			return
+		}
+		m.add(collectionResource(state), find)
+	}
+	m.write(c, "")
+}
+
+// PropfindCollection handles reading the properties of the todo collection, and with a
+// Depth of 1 those of every todo resource
+func (h *CalDAVHandler) PropfindCollection(c *gin.Context) {
+	find, err := parsePropfind(c)
+	if err != nil {
+		respondError(c, "Invalid PROPFIND body", err)
+		return
+	}
+
+	todos, state, err := h.useCase.SyncTodos(c.Request.Context(), nil)
+	if err != nil {
+		respondError(c, "Failed to read CalDAV properties", err)
+		return
+	}
+
+	m := newDAVMultistatus()
+	m.add(collectionResource(state), find)
+	if c.GetHeader("Depth") != "0" {
+		for _, todo := range todos {
+			m.add(todoResource(todo), find)
+		}
+	}
+	m.write(c, "")
+}
+
+// ProppatchCollection handles changing the properties of the todo collection, such as the
+// color clients pick for it. They are all protected, so every change is refused.
+func (h *CalDAVHandler) ProppatchCollection(c *gin.Context) {
+	var update struct {
+		Set    []davPropfind `xml:"DAV: set"`
+		Remove []davPropfind `xml:"DAV: remove"`
+	}
+	if _, err := readDAVBody(c, &update); err != nil {
+		respondError(c, "Invalid PROPPATCH body", err)
+		return
+	}
+
+	var names []xml.Name
+	for _, instruction := range append(update.Set, update.Remove...) {
+		for _, prop := range instruction.Prop.Names {
+			names = append(names, prop.XMLName)
+		}
+	}
+	m := newDAVMultistatus()
+	m.addPropstat(calDAVCollectionPath, names, http.StatusForbidden)
+	m.write(c, "")
+}
+
+// PropfindTodo handles reading the properties of a todo resource
+func (h *CalDAVHandler) PropfindTodo(c *gin.Context) {
+	id, err := parseCalDAVTodoID(c)
+	if err != nil {
+		respondError(c, "Invalid todo resource", err)
+		return
+	}
+	find, err := parsePropfind(c)
+	if err != nil {
+		respondError(c, "Invalid PROPFIND body", err)
+		return
+	}
+
+	todo, err := h.useCase.GetTodo(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, "Failed to get todo", err, zap.String("id", id.String()))
+		return
+	}
+	if todo == nil {
+		respondError(c, "Todo not found", errTodoNotFound, zap.String("id", id.String()))
+		return
+	}
+
+	m := newDAVMultistatus()
+	m.add(todoResource(todo), find)
+	m.write(c, "")
+}
+
+// davReport is the body of a REPORT request, whichever report it is
+type davReport struct {
+	XMLName xml.Name
+	davPropfind
+	// Hrefs are the resources of a calendar-multiget
+	Hrefs []string `xml:"DAV: href"`
+	// Filter selects the todos of a calendar-query
+	Filter *calDAVFilter `xml:"urn:ietf:params:xml:ns:caldav filter"`
+	// SyncToken is where a sync-collection resumes, empty for the initial sync
+	SyncToken string `xml:"DAV: sync-token"`
+}
+
+// Report handles the calendar-query, calendar-multiget and sync-collection reports of the todo collection
+func (h *CalDAVHandler) Report(c *gin.Context) {
+	var report davReport
+	if ok, err := readDAVBody(c, &report); err != nil || !ok {
+		if err == nil {
+			err = errMalformedDAVBody
+		}
+		respondError(c, "Invalid REPORT body", err)
+		return
+	}
+
+	switch report.XMLName {
+	case calDAVName("calendar-query"):
+		h.calendarQuery(c, report)
+	case calDAVName("calendar-multiget"):
+		h.calendarMultiget(c, report)
+	case davName("sync-collection"):
+		h.syncCollection(c, report)
+	default:
+		abortDAVError(c, http.StatusForbidden, davName("supported-report"))
+	}
+}
+
+// calendarQuery responds with the todos matching the filter of the report (RFC 4791, section 7.8)
+func (h *CalDAVHandler) calendarQuery(c *gin.Context, report davReport) {
+	todos, err := h.useCase.GetTodos(c.Request.Context(), entity.TodoFilter{})
+	if err != nil {
+		respondError(c, "Failed to query todos", err)
+		return
+	}
+
+	m := newDAVMultistatus()
+	for _, todo := range todos {
+		if report.Filter == nil || report.Filter.matches(todo) {
+			m.add(todoResource(todo), report.davPropfind)
+		}
+	}
+	m.write(c, "")
+}
+
+// calendarMultiget responds with the todos of the hrefs of the report, in their order, and
+// a 404 Not Found response for each href that is not a todo (RFC 4791, section 7.9)
+func (h *CalDAVHandler) calendarMultiget(c *gin.Context, report davReport) {
+	ids := make([]uuid.UUID, len(report.Hrefs))
+	var unique []uuid.UUID
+	seen := make(map[uuid.UUID]bool)
+	for i, href := range report.Hrefs {
+		if id, ok := parseCalDAVHref(href); ok {
+			ids[i] = id
+			if !seen[id] {
+				seen[id] = true
+				unique = append(unique, id)
+			}
+		}
+	}
+
+	todos, err := h.useCase.GetTodosByIDs(c.Request.Context(), unique)
+	if err != nil {
+		respondError(c, "Failed to get todos", err)
+		return
+	}
+
+	m := newDAVMultistatus()
+	for i, href := range report.Hrefs {
+		if todo, ok := todos[ids[i]]; ok {
+			m.add(todoResource(todo), report.davPropfind)
+		} else {
+			m.addStatus(href, http.StatusNotFound)
+		}
+	}
+	m.write(c, "")
+}
+
+// syncCollection responds with the todos changed since the sync token of the report, or every
+// todo for the initial sync, and the token to resume from (RFC 6578). Deleted todos are not
+// recorded, so a token predating a deletion is refused and the client syncs again from scratch.
+func (h *CalDAVHandler) syncCollection(c *gin.Context, report davReport) {
+	var since *todo.SyncState
+	if report.SyncToken != "" {
+		state, ok := parseSyncToken(report.SyncToken)
+		if !ok {
+			abortDAVError(c, http.StatusForbidden, davName("valid-sync-token"))
+			return
+		}
+		since = &state
+	}
+
+	todos, state, err := h.useCase.SyncTodos(c.Request.Context(), since)
+	if errors.Is(err, todo.ErrSyncStateExpired) {
+		abortDAVError(c, http.StatusForbidden, davName("valid-sync-token"))
+		return
+	}
+	if err != nil {
+		respondError(c, "Failed to sync todos", err)
+		return
+	}
+
+	m := newDAVMultistatus()
+	for _, todo := range todos {
+		m.add(todoResource(todo), report.davPropfind)
+	}
+	m.write(c, formatSyncToken(state))
+}
+
+// GetTodo handles reading a todo resource. A matching If-None-Match yields 304 Not Modified.
+func (h *CalDAVHandler) GetTodo(c *gin.Context) {
+	id, err := parseCalDAVTodoID(c)
+	if err != nil {
+		respondError(c, "Invalid todo resource", err)
+		return
+	}
+
+	todo, err := h.useCase.GetTodo(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, "Failed to get todo", err, zap.String("id", id.String()))
+		return
+	}
+	if todo == nil {
+		respondError(c, "Todo not found", errTodoNotFound, zap.String("id", id.String()))
+		return
+	}
+
+	etag := todoETag(todo)
+	c.Header("ETag", etag)
+	c.Header("Last-Modified", todo.UpdatedAt.UTC().Format(http.TimeFormat))
+	if matchesETag(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, calDAVTodoContentType, encodeICSTodo(todo))
+}
+
+// PutTodo handles creating or replacing a todo from a calendar holding a single VTODO, whose
+// UID must be the todo ID. If-Match requires the todo to be unchanged since the client read
+// that ETag, and If-None-Match: * requires it not to exist yet.
+func (h *CalDAVHandler) PutTodo(c *gin.Context) {
+	id, err := parseCalDAVTodoID(c)
+	if err != nil {
+		respondError(c, "Invalid todo resource", err)
+		return
+	}
+	pre, err := parsePrecondition(c)
+	if err != nil {
+		respondError(c, "Invalid precondition", err, zap.String("id", id.String()))
+		return
+	}
+	body, err := readBody(c, maxRequestBodyBytes)
+	if err != nil {
+		respondError(c, "Failed to read calendar data", err)
+		return
+	}
+	input, err := parseCalDAVTodo(body, id)
+	if err != nil {
+		respondError(c, "Invalid calendar data", err, zap.String("id", id.String()))
+		return
+	}
+
+	_, created, err := h.useCase.SaveTodo(c.Request.Context(), id, input, pre)
+	if err != nil {
+		respondError(c, "Failed to save todo", err, zap.String("id", id.String()))
+		return
+	}
+
+	// The stored todo keeps only the properties it models, so no ETag is returned and
+	// clients read the resource back (RFC 4791, section 5.3.4)
+	if created {
+		c.Status(http.StatusCreated)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteTodo handles deleting a todo resource, conditionally on If-Match
+func (h *CalDAVHandler) DeleteTodo(c *gin.Context) {
+	id, err := parseCalDAVTodoID(c)
+	if err != nil {
+		respondError(c, "Invalid todo resource", err)
+		return
+	}
+	pre, err := parsePrecondition(c)
+	if err != nil {
+		respondError(c, "Invalid precondition", err, zap.String("id", id.String()))
+		return
+	}
+
+	if err := h.useCase.DeleteTodoIf(c.Request.Context(), id, pre); err != nil {
+		respondError(c, "Failed to delete todo", err, zap.String("id", id.String()))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// rootResource returns the principal of the tenant, which is also its calendar home
+func rootResource(tenantID tenant.ID) davResource {
+	return davResource{
+		href: calDAVRootPath,
+		props: []davProperty{
+			{davName("resourcetype"), "<D:collection/><D:principal/>"},
+			{davName("displayname"), escapeXML(string(tenantID))},
+			{davName("current-user-principal"), davHref(calDAVRootPath)},
+			{davName("principal-URL"), davHref(calDAVRootPath)},
+			{davName("owner"), davHref(calDAVRootPath)},
+			{calDAVName("calendar-home-set"), davHref(calDAVRootPath)},
+			{davName("current-user-privilege-set"), "<D:privilege><D:read/></D:privilege>"},
+		},
+		requestedOnly: map[xml.Name]bool{davName("current-user-privilege-set"): true},
+	}
+}
+
+// collectionResource returns the todo collection, whose sync token and CTag identify state
+func collectionResource(state todo.SyncState) davResource {
+	syncToken := escapeXML(formatSyncToken(state))
+	return davResource{
+		href: calDAVCollectionPath,
+		props: []davProperty{
+			{davName("resourcetype"), "<D:collection/><C:calendar/>"},
+			{davName("displayname"), "Todos"},
+			{davName("current-user-principal"), davHref(calDAVRootPath)},
+			{davName("owner"), davHref(calDAVRootPath)},
+			{calDAVName("supported-calendar-component-set"), `<C:comp name="VTODO"/>`},
+			{calDAVName("supported-calendar-data"), `<C:calendar-data content-type="text/calendar" version="2.0"/>`},
+			{davName("supported-report-set"), "<D:supported-report><D:report><C:calendar-query/></D:report></D:supported-report>" +
+				"<D:supported-report><D:report><C:calendar-multiget/></D:report></D:supported-report>" +
+				"<D:supported-report><D:report><D:sync-collection/></D:report></D:supported-report>"},
+			{davName("current-user-privilege-set"), "<D:privilege><D:read/></D:privilege>" +
+				"<D:privilege><D:write-content/></D:privilege>" +
+				"<D:privilege><D:bind/></D:privilege>" +
+				"<D:privilege><D:unbind/></D:privilege>"},
+			{davName("sync-token"), syncToken},
+			{xml.Name{Space: calendarServerNamespace, Local: "getctag"}, syncToken},
+		},
+		requestedOnly: map[xml.Name]bool{
+			davName("supported-report-set"):       true,
+			davName("current-user-privilege-set"): true,
+		},
+	}
+}
+
+// todoResource returns the resource of todo
+func todoResource(todo *entity.Todo) davResource {
+	return davResource{
+		href: todoHref(todo.ID),
+		props: []davProperty{
+			{davName("resourcetype"), ""},
+			{davName("getetag"), escapeXML(todoETag(todo))},
+			{davName("getcontenttype"), calDAVTodoContentType},
+			{davName("getlastmodified"), todo.UpdatedAt.UTC().Format(http.TimeFormat)},
+			{calDAVName("calendar-data"), escapeXML(string(encodeICSTodo(todo)))},
+		},
+		requestedOnly: map[xml.Name]bool{calDAVName("calendar-data"): true},
+	}
+}
+
+// todoHref returns the path of the resource of the todo id
+func todoHref(id uuid.UUID) string {
+	return calDAVCollectionPath + id.String() + calDAVResourceSuffix
+}
+
+// parseCalDAVHref returns the todo of a resource href, either a path or an absolute URL
+func parseCalDAVHref(href string) (uuid.UUID, bool) {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return uuid.Nil, false
+	}
+	name, ok := strings.CutPrefix(u.Path, calDAVCollectionPath)
+	if !ok {
+		return uuid.Nil, false
+	}
+	id, err := parseCalDAVResourceName(name)
+	return id, err == nil
+}
+
+// parseCalDAVTodoID parses the todo ID of the resource name in the path
+func parseCalDAVTodoID(c *gin.Context) (uuid.UUID, error) {
+	return parseCalDAVResourceName(c.Param("name"))
+}
+
+// parseCalDAVResourceName parses the todo ID of a resource name, <todo ID>.ics
+func parseCalDAVResourceName(name string) (uuid.UUID, error) {
+	raw, ok := strings.CutSuffix(name, calDAVResourceSuffix)
+	if !ok {
+		return uuid.Nil, fmt.Errorf("%w: resource names end with %s", errInvalidTodoID, calDAVResourceSuffix)
+	}
+	return parseID(raw)
+}
+
+// parsePropfind reads the body of a PROPFIND request. An empty body requests all properties.
+func parsePropfind(c *gin.Context) (davPropfind, error) {
+	var find struct {
+		XMLName xml.Name `xml:"DAV: propfind"`
+		davPropfind
+	}
+	if _, err := readDAVBody(c, &find); err != nil {
+		return davPropfind{}, err
+	}
+	return find.davPropfind, nil
+}
+
+// parseCalDAVTodo reads the body of a PUT into the todo id. It must be a calendar holding
+// a single VTODO whose UID is the todo ID; RELATED-TO may name the UID of the parent todo.
+func parseCalDAVTodo(body []byte, id uuid.UUID) (entity.TodoReplace, error) {
+	rows, err := parseImport("ics", body)
+	var fileErr *ImportFileError
+	if errors.As(err, &fileErr) {
+		return entity.TodoReplace{}, &CalendarDataError{Reason: fileErr.Reason}
+	}
+	if err != nil {
+		return entity.TodoReplace{}, err
+	}
+	if len(rows) != 1 {
+		return entity.TodoReplace{}, &CalendarDataError{Reason: "must hold exactly one VTODO"}
+	}
+
+	row := rows[0]
+	if uid, err := uuid.Parse(row.ExternalID); err != nil || uid != id {
+		return entity.TodoReplace{}, &CalendarDataError{Reason: "the UID must be the todo ID of the resource name"}
+	}
+	var fieldErrors []FieldError
+	for _, fe := range row.Errors {
+		fieldErrors = append(fieldErrors, FieldError(fe))
+	}
+	if row.ParentExternalID != "" {
+		parentID, err := uuid.Parse(row.ParentExternalID)
+		if err != nil {
+			fieldErrors = append(fieldErrors, FieldError{Field: "parent_id", Rule: "uuid", Message: "must be the UID of another todo"})
+		}
+		row.Todo.ParentID = parentID
+	}
+	if len(fieldErrors) > 0 {
+		return entity.TodoReplace{}, &ValidationError{Fields: fieldErrors}
+	}
+	return entity.TodoReplace(row.Todo), nil
+}
+
+// parsePrecondition reads the If-Match and If-None-Match headers of a write. If-Match takes
+// * or a single ETag; one that this server did not issue can match no todo, so it fails
+// with todo.ErrPreconditionFailed. If-None-Match only takes *.
+func parsePrecondition(c *gin.Context) (todo.Precondition, error) {
+	var pre todo.Precondition
+	if match := strings.TrimSpace(c.GetHeader("If-Match")); match == "*" {
+		exists := true
+		pre.Exists = &exists
+	} else if match != "" {
+		updatedAt, ok := parseETag(match)
+		if !ok {
+			return pre, todo.ErrPreconditionFailed
+		}
+		pre.UpdatedAt = &updatedAt
+	}
+	if strings.TrimSpace(c.GetHeader("If-None-Match")) == "*" {
+		exists := false
+		pre.Exists = &exists
+	}
+	return pre, nil
+}
+
+// todoETag returns the strong ETag of todo, which changes with every update
+func todoETag(todo *entity.Todo) string {
+	return `"` + strconv.FormatInt(todo.UpdatedAt.UnixNano(), 36) + `"`
+}
+
+// parseETag returns the update time of the todo version an ETag of todoETag identifies
+func parseETag(etag string) (time.Time, bool) {
+	if len(etag) < 2 || etag[0] != '"' || etag[len(etag)-1] != '"' {
+		return time.Time{}, false
+	}
+	nanos, err := strconv.ParseInt(etag[1:len(etag)-1], 36, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, nanos).UTC(), true
+}
+
+// matchesETag reports whether an If-None-Match header lists etag, weakly compared
+func matchesETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// formatSyncToken returns the sync token, also used as CTag, of a sync state
+func formatSyncToken(state todo.SyncState) string {
+	updatedAt := int64(0)
+	if !state.UpdatedAt.IsZero() {
+		updatedAt = state.UpdatedAt.UnixNano()
+	}
+	return calDAVSyncTokenPrefix + strconv.FormatInt(updatedAt, 36) + "." + strconv.Itoa(state.Count)
+}
+
+// parseSyncToken returns the sync state of a token of formatSyncToken
+func parseSyncToken(token string) (todo.SyncState, bool) {
+	raw, ok := strings.CutPrefix(token, calDAVSyncTokenPrefix)
+	if !ok {
+		return todo.SyncState{}, false
+	}
+	updatedAtStr, countStr, ok := strings.Cut(raw, ".")
+	if !ok {
+		return todo.SyncState{}, false
+	}
+	updatedAt, err := strconv.ParseInt(updatedAtStr, 36, 64)
+	if err != nil {
+		return todo.SyncState{}, false
+	}
+	count, err := strconv.Atoi(countStr)
+	if err != nil || count < 0 {
+		return todo.SyncState{}, false
+	}
+
+	state := todo.SyncState{Count: count}
+	if updatedAt != 0 {
+		state.UpdatedAt = time.Unix(0, updatedAt).UTC()
+	}
+	return state, true
+}
@@ -0,0 +1,139 @@
+package http
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+)
+
+// calDAVFilter is the CALDAV:filter of a calendar-query REPORT (RFC 4791, section 9.7).
+// Parameter filters and collations other than i;ascii-casemap are not supported.
+type calDAVFilter struct {
+	CompFilter calDAVCompFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+// calDAVCompFilter selects calendar components
+type calDAVCompFilter struct {
+	Name         string             `xml:"name,attr"`
+	IsNotDefined *struct{}          `xml:"urn:ietf:params:xml:ns:caldav is-not-defined"`
+	TimeRange    *calDAVTimeRange   `xml:"urn:ietf:params:xml:ns:caldav time-range"`
+	PropFilters  []calDAVPropFilter `xml:"urn:ietf:params:xml:ns:caldav prop-filter"`
+	CompFilters  []calDAVCompFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+// calDAVPropFilter selects components by the value of one of their properties
+type calDAVPropFilter struct {
+	Name         string           `xml:"name,attr"`
+	IsNotDefined *struct{}        `xml:"urn:ietf:params:xml:ns:caldav is-not-defined"`
+	TimeRange    *calDAVTimeRange `xml:"urn:ietf:params:xml:ns:caldav time-range"`
+	TextMatch    *calDAVTextMatch `xml:"urn:ietf:params:xml:ns:caldav text-match"`
+}
+
+// calDAVTextMatch matches property values containing Text, case-insensitively
+type calDAVTextMatch struct {
+	Text            string `xml:",chardata"`
+	NegateCondition string `xml:"negate-condition,attr"`
+}
+
+// calDAVTimeRange is a range of UTC date-times, either end being open when missing
+type calDAVTimeRange struct {
+	Start string `xml:"start,attr"`
+	End   string `xml:"end,attr"`
+}
+
+// matches reports whether the calendar of todo matches the filter
+func (f *calDAVFilter) matches(todo *entity.Todo) bool {
+	root := f.CompFilter
+	if !strings.EqualFold(root.Name, "VCALENDAR") || root.IsNotDefined != nil {
+		return false
+	}
+	for _, filter := range root.CompFilters {
+		if !filter.matchesTodo(todo) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesTodo reports whether the VTODO component of todo matches a filter of the calendar components
+func (f calDAVCompFilter) matchesTodo(todo *entity.Todo) bool {
+	if !strings.EqualFold(f.Name, "VTODO") {
+		// The calendar of a todo holds no other component
+		return f.IsNotDefined != nil
+	}
+	if f.IsNotDefined != nil {
+		return false
+	}
+	if f.TimeRange != nil && !f.TimeRange.overlapsTodo(todo) {
+		return false
+	}
+
+	properties := todoICSProperties(todo)
+	for _, filter := range f.PropFilters {
+		if !filter.matches(properties) {
+			return false
+		}
+	}
+	// Todos have no nested components, such as VALARM
+	for _, filter := range f.CompFilters {
+		if filter.IsNotDefined == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// matches reports whether one of the properties named by the filter matches it
+func (f calDAVPropFilter) matches(properties []icsProperty) bool {
+	defined := false
+	for _, property := range properties {
+		if !strings.EqualFold(property.name, f.Name) {
+			continue
+		}
+		defined = true
+		if f.IsNotDefined != nil {
+			return false
+		}
+		if f.TimeRange != nil {
+			t, err := parseICSDateTime(property)
+			if err != nil || !f.TimeRange.contains(t) {
+				continue
+			}
+		}
+		if f.TextMatch != nil && !f.TextMatch.matches(unescapeICSText(property.value)) {
+			continue
+		}
+		return true
+	}
+	return !defined && f.IsNotDefined != nil
+}
+
+// matches reports whether value matches, taking the negation into account
+func (m calDAVTextMatch) matches(value string) bool {
+	contains := strings.Contains(strings.ToLower(value), strings.ToLower(m.Text))
+	return contains != strings.EqualFold(m.NegateCondition, "yes")
+}
+
+// overlapsTodo applies the rules of a VTODO without DTSTART (RFC 4791, section 9.9): a todo
+// overlaps when it is due within the range, or was created before its end if it has no due date
+func (r *calDAVTimeRange) overlapsTodo(todo *entity.Todo) bool {
+	start, end := r.bounds()
+	if !todo.Due.IsZero() {
+		return (start.IsZero() || start.Before(todo.Due)) && (end.IsZero() || !end.Before(todo.Due))
+	}
+	return end.IsZero() || end.After(todo.CreatedAt)
+}
+
+// contains reports whether t is within the range, its end excluded
+func (r *calDAVTimeRange) contains(t time.Time) bool {
+	start, end := r.bounds()
+	return (start.IsZero() || !t.Before(start)) && (end.IsZero() || t.Before(end))
+}
+
+// bounds returns the ends of the range, the zero time for ends that are missing or invalid
+func (r *calDAVTimeRange) bounds() (time.Time, time.Time) {
+	start, _ := time.Parse(icsDateTimeLayout, r.Start)
+	end, _ := time.Parse(icsDateTimeLayout, r.End)
+	return start, end
+}
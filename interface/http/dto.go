@@ -0,0 +1,192 @@
+package http
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+)
+
+// timestampLayout is the RFC 3339 layout of timestamps on the wire, always in UTC with millisecond precision
+const timestampLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// TodoResponse represents a todo item in response bodies
+type TodoResponse struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Completed   bool   `json:"completed"`
+	// ListID is null when the todo is not in a list
+	ListID *string `json:"list_id"`
+	// ParentID is null for top-level todos
+	ParentID *string `json:"parent_id"`
+	// Due is null when the todo has no due date
+	Due *string `json:"due"`
+	// Priority is from 1 (highest) to 9 (lowest), 0 when undefined
+	Priority int `json:"priority"`
+	// ExternalID is the ID of an imported todo in the tool it was imported from, null otherwise
+	ExternalID *string `json:"external_id"`
+	CreatedAt  string  `json:"created_at"`
+	UpdatedAt  string  `json:"updated_at"`
+}
+
+// CreateTodoRequest represents the request body of POST /todos
+type CreateTodoRequest struct {
+	Title       string `json:"title" binding:"required,notblank,max=200"`
+	Description string `json:"description" binding:"max=2000"`
+	Completed   bool   `json:"completed"`
+	ListID      string `json:"list_id" binding:"max=64"`
+	ParentID    string `json:"parent_id" binding:"omitempty,uuid"`
+	Due         string `json:"due" binding:"omitempty,timestamp"`
+	Priority    int    `json:"priority" binding:"min=0,max=9"`
+}
+
+// UpdateTodoRequest represents the request body of PATCH /todos/:id.
+// Omitted fields are left unchanged; an empty list_id, parent_id or due clears it.
+type UpdateTodoRequest struct {
+	Title       *string `json:"title" binding:"omitempty,notblank,max=200"`
+	Description *string `json:"description" binding:"omitempty,max=2000"`
+	Completed   *bool   `json:"completed"`
+	ListID      *string `json:"list_id" binding:"omitempty,max=64"`
+	ParentID    *string `json:"parent_id" binding:"omitempty,len=0|uuid"`
+	Due         *string `json:"due" binding:"omitempty,len=0|timestamp"`
+	Priority    *int    `json:"priority" binding:"omitempty,min=0,max=9"`
+}
+
+// ReplaceTodoRequest represents the request body of PUT /todos/:id
+type ReplaceTodoRequest struct {
+	Title       string `json:"title" binding:"required,notblank,max=200"`
+	Description string `json:"description" binding:"max=2000"`
+	Completed   bool   `json:"completed"`
+	ListID      string `json:"list_id" binding:"max=64"`
+	ParentID    string `json:"parent_id" binding:"omitempty,uuid"`
+	Due         string `json:"due" binding:"omitempty,timestamp"`
+	Priority    int    `json:"priority" binding:"min=0,max=9"`
+}
+
+// MoveTodosRequest represents the request body of POST /todos:move.
+// An empty list ID stands for the todos that are not in a list.
+type MoveTodosRequest struct {
+	FromListID string `json:"from_list_id" binding:"max=64"`
+	ToListID   string `json:"to_list_id" binding:"max=64"`
+}
+
+// newTodoResponse converts a Todo entity to its wire format
+func newTodoResponse(todo *entity.Todo) TodoResponse {
+	return TodoResponse{
+		ID:          todo.ID.String(),
+		Title:       todo.Title,
+		Description: todo.Description,
+		Completed:   todo.Completed,
+		ListID:      optionalString(todo.ListID),
+		ParentID:    optionalString(formatParentID(todo.ParentID)),
+		Due:         optionalString(formatDue(todo.Due)),
+		Priority:    todo.Priority,
+		ExternalID:  optionalString(todo.ExternalID),
+		CreatedAt:   formatTimestamp(todo.CreatedAt),
+		UpdatedAt:   formatTimestamp(todo.UpdatedAt),
+	}
+}
+
+// newTodoListResponse converts Todo entities to their wire format
+func newTodoListResponse(todos []*entity.Todo) []TodoResponse {
+	response := make([]TodoResponse, 0, len(todos))
+	for _, todo := range todos {
+		response = append(response, newTodoResponse(todo))
+	}
+	return response
+}
+
+// formatTimestamp formats t for response bodies
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format(timestampLayout)
+}
+
+// optionalString returns nil for an empty string, serialized as null
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// formatParentID formats the parent of a todo, empty for top-level todos
+func formatParentID(id uuid.UUID) string {
+	if id == uuid.Nil {
+		return ""
+	}
+	return id.String()
+}
+
+// formatDue formats the due date of a todo, empty when it has none
+func formatDue(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return formatTimestamp(t)
+}
+
+// parseDue parses a validated due date, the zero time when empty
+func parseDue(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// parseParentID parses a validated parent ID, uuid.Nil when empty
+func parseParentID(s string) uuid.UUID {
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return uuid.Nil
+	}
+	return id
+}
+
+// toEntity converts the request to the domain input
+func (r CreateTodoRequest) toEntity() entity.TodoCreate {
+	return entity.TodoCreate{
+		Title:       r.Title,
+		Description: r.Description,
+		Completed:   r.Completed,
+		ListID:      r.ListID,
+		ParentID:    parseParentID(r.ParentID),
+		Due:         parseDue(r.Due),
+		Priority:    r.Priority,
+	}
+}
+
+// toEntity converts the request to the domain input
+func (r UpdateTodoRequest) toEntity() entity.TodoUpdate {
+	update := entity.TodoUpdate{
+		Title:       r.Title,
+		Description: r.Description,
+		Completed:   r.Completed,
+		ListID:      r.ListID,
+		Priority:    r.Priority,
+	}
+	if r.ParentID != nil {
+		parentID := parseParentID(*r.ParentID)
+		update.ParentID = &parentID
+	}
+	if r.Due != nil {
+		due := parseDue(*r.Due)
+		update.Due = &due
+	}
+	return update
+}
+
+// toEntity converts the request to the domain input
+func (r ReplaceTodoRequest) toEntity() entity.TodoReplace {
+	return entity.TodoReplace{
+		Title:       r.Title,
+		Description: r.Description,
+		Completed:   r.Completed,
+		ListID:      r.ListID,
+		ParentID:    parseParentID(r.ParentID),
+		Due:         parseDue(r.Due),
+		Priority:    r.Priority,
+	}
+}
@@ -0,0 +1,123 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/repository"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/tenant"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/logger"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/problem"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/usecase/todo"
+	"go.uber.org/zap"
+)
+
+var (
+	// errInvalidTodoID is returned when the todo ID in the path is not a UUID
+	errInvalidTodoID = errors.New("invalid todo ID")
+	// errTodoNotFound is returned when the todo does not exist in the tenant
+	errTodoNotFound = errors.New("todo not found")
+	// errMalformedBody is returned when the request body is not a JSON object
+	errMalformedBody = errors.New("malformed request body")
+	// errInvalidImportID is returned when the import ID in the path is not a UUID
+	errInvalidImportID = errors.New("invalid import ID")
+	// errImportNotFound is returned when the import job does not exist in the tenant
+	errImportNotFound = errors.New("import not found")
+)
+
+// respondError logs err with message and responds with the matching problem details
+func (h *TodoHandler) respondError(c *gin.Context, message string, err error, fields ...zap.Field) {
+	respondError(c, message, err, fields...)
+}
+
+// respondError logs err with message and responds with the matching problem details
+func respondError(c *gin.Context, message string, err error, fields ...zap.Field) {
+	p := problemFor(err)
+
+	fields = append([]zap.Field{
+		zap.Error(err),
+		zap.String("code", string(p.Code)),
+	}, fields...)
+	log := logger.FromContext(c.Request.Context())
+	if p.Status >= http.StatusInternalServerError {
+		log.Error(message, fields...)
+	} else {
+		log.Warn(message, fields...)
+	}
+
+	problem.Abort(c, p)
+}
+
+// problemFor maps an error returned by request binding or the use case to problem details
+func problemFor(err error) *problem.Problem {
+	var validationErr *ValidationError
+	var maxBytesErr *http.MaxBytesError
+	var queryErr *QueryError
+	var importFileErr *ImportFileError
+	var calendarDataErr *CalendarDataError
+	switch {
+	case errors.As(err, &validationErr):
+		p := problem.New(http.StatusUnprocessableEntity, problem.CodeValidationFailed,
+			fmt.Sprintf("The request body violates %d validation rule(s)", len(validationErr.Fields)))
+		p.Errors = validationErr.Fields
+		return p
+	case errors.As(err, &queryErr):
+		p := problem.New(http.StatusBadRequest, problem.CodeInvalidQuery,
+			fmt.Sprintf("The query string violates %d rule(s)", len(queryErr.Fields)))
+		p.Errors = queryErr.Fields
+		return p
+	case errors.As(err, &importFileErr):
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidImportFile,
+			fmt.Sprintf("The file is not a valid %s export: %s", importFileErr.Format, importFileErr.Reason))
+	case errors.As(err, &calendarDataErr):
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidCalendarData,
+			"The body is not a valid iCalendar object: "+calendarDataErr.Reason)
+	case errors.Is(err, errMalformedDAVBody):
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "The request body must be a valid WebDAV XML document")
+	case errors.Is(err, errMalformedBody):
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "The request body must be a JSON object")
+	case errors.As(err, &maxBytesErr):
+		return problem.PayloadTooLarge(maxBytesErr.Limit)
+	case errors.Is(err, errInvalidTodoID):
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidTodoID, "The todo ID must be a UUID")
+	case errors.Is(err, errTodoNotFound), errors.Is(err, todo.ErrNotFound):
+		return problem.New(http.StatusNotFound, problem.CodeTodoNotFound, "The todo does not exist")
+	case errors.Is(err, errInvalidImportID):
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidImportID, "The import ID must be a UUID")
+	case errors.Is(err, errImportNotFound):
+		return problem.New(http.StatusNotFound, problem.CodeImportNotFound, "The import does not exist or has expired")
+	case errors.Is(err, todo.ErrPreconditionFailed):
+		return problem.New(http.StatusPreconditionFailed, problem.CodePreconditionFailed,
+			"The todo does not match the If-Match or If-None-Match condition of the request")
+	case errors.Is(err, todo.ErrQuotaExceeded):
+		return problem.New(http.StatusForbidden, problem.CodeQuotaExceeded, "The tenant already owns its maximum number of todos")
+	case errors.Is(err, todo.ErrParentNotFound):
+		return problem.New(http.StatusUnprocessableEntity, problem.CodeParentNotFound, "The parent todo does not exist")
+	case errors.Is(err, todo.ErrParentCycle):
+		return problem.New(http.StatusUnprocessableEntity, problem.CodeParentCycle,
+			"The parent would make the todo its own ancestor or nest todos too deeply")
+	case errors.Is(err, repository.ErrConcurrentModification):
+		return problem.New(http.StatusConflict, problem.CodeConcurrentModification,
+			"A todo was modified or deleted by another request, retry the operation")
+	case errors.Is(err, repository.ErrTransactionConflict):
+		return problem.New(http.StatusConflict, problem.CodeTransactionConflict,
+			"Another request wrote the same todos at the same time, retry the operation")
+	case errors.Is(err, repository.ErrAlreadyExists):
+		return problem.New(http.StatusConflict, problem.CodeTodoExists, "The todo already exists")
+	case errors.Is(err, repository.ErrTooManyWrites):
+		return problem.New(http.StatusUnprocessableEntity, problem.CodeTransactionTooLarge,
+			fmt.Sprintf("The operation would change more than %d todos at once", repository.MaxUnitOfWorkWrites))
+	case errors.Is(err, todo.ErrDuplicateTarget):
+		return problem.New(http.StatusConflict, problem.CodeDuplicateTarget, "The todo is already targeted by another operation of the batch")
+	case errors.Is(err, todo.ErrBatchAborted):
+		return problem.New(http.StatusFailedDependency, problem.CodeBatchAborted, "The atomic batch was aborted by another operation")
+	case errors.Is(err, repository.ErrWriteUnprocessed):
+		return problem.New(http.StatusServiceUnavailable, problem.CodeWriteUnprocessed, "The operation was throttled, retry it later")
+	case errors.Is(err, tenant.ErrMissing):
+		return problem.New(http.StatusBadRequest, problem.CodeTenantMissing, "The request does not identify a tenant")
+	default:
+		return problem.New(http.StatusInternalServerError, problem.CodeInternal, "An unexpected error occurred")
+	}
+}
@@ -0,0 +1,131 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/tenant"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/eventbus"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/logger"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/problem"
+	"go.uber.org/zap"
+)
+
+// resetEvent tells clients that events were missed, so they should reload the todos
+const resetEvent = "reset"
+
+// TodoEventResponse represents a todo event in the event stream
+type TodoEventResponse struct {
+	ID     string `json:"id"`
+	Type   string `json:"type"`
+	TodoID string `json:"todo_id"`
+	// Todo is the todo as written, null for deletions
+	Todo       *TodoResponse `json:"todo"`
+	OccurredAt string        `json:"occurred_at"`
+}
+
+// EventHandler handles HTTP requests streaming todo events
+type EventHandler struct {
+	bus       *eventbus.Bus
+	heartbeat time.Duration
+}
+
+// NewEventHandler creates a new EventHandler instance sending a heartbeat every heartbeat
+func NewEventHandler(bus *eventbus.Bus, heartbeat time.Duration) *EventHandler {
+	return &EventHandler{bus: bus, heartbeat: heartbeat}
+}
+
+// StreamEvents handles streaming the todo events of the tenant as server-sent events. Clients
+// reconnecting with Last-Event-ID get the events they missed from the replay buffer first, or a
+// reset event when they are no longer buffered.
+func (h *EventHandler) StreamEvents(c *gin.Context) {
+	// Browsers send the ID of the last event on reconnection; the query parameter serves
+	// clients that open a new EventSource themselves
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var after uint64
+	if lastEventID != "" {
+		var err error
+		if after, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+			problem.Abort(c, problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Last-Event-ID must be the ID of an event"))
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		respondError(c, "Failed to stream events", err)
+		return
+	}
+	replay, sub, complete := h.bus.Subscribe(tenantID, after, lastEventID != "")
+	if sub == nil {
+		problem.Abort(c, problem.New(http.StatusServiceUnavailable, problem.CodeShuttingDown, "The server is shutting down, reconnect later"))
+		return
+	}
+	defer sub.Unsubscribe()
+
+	// The stream outlives the write timeout of the server
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		logger.FromContext(ctx).Warn("Failed to clear the write deadline of the event stream", zap.Error(err))
+	}
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	// Proxies such as nginx would otherwise buffer the stream
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	if !complete {
+		fmt.Fprintf(c.Writer, "event: %s\ndata: {}\n\n", resetEvent)
+	}
+	for _, event := range replay {
+		writeTodoEvent(c, event)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				// Slow clients are dropped and resume from the replay buffer when reconnecting
+				return
+			}
+			writeTodoEvent(c, event)
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": heartbeat\n\n")
+		}
+		c.Writer.Flush()
+	}
+}
+
+// writeTodoEvent writes event in the server-sent events format
+func writeTodoEvent(c *gin.Context, event entity.TodoEvent) {
+	response := TodoEventResponse{
+		ID:         strconv.FormatUint(event.ID, 10),
+		Type:       string(event.Type),
+		TodoID:     event.TodoID.String(),
+		OccurredAt: formatTimestamp(event.OccurredAt),
+	}
+	if event.Todo != nil {
+		todo := newTodoResponse(event.Todo)
+		response.Todo = &todo
+	}
+	// JSON has no newlines, so the data fits on one line
+	data, err := json.Marshal(response)
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to encode todo event", zap.Error(err))
+		return
+	}
+	fmt.Fprintf(c.Writer, "id: %s\nevent: %s\ndata: %s\n\n", response.ID, response.Type, data)
+}
@@ -0,0 +1,229 @@
+package http
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+// exportColumns are the columns of tabular exports, in order
+var exportColumns = []string{
+	"id", "title", "description", "completed", "list_id", "parent_id", "due", "priority", "external_id",
+	"created_at", "updated_at",
+}
+
+// exportPageTimeout bounds the time to write a page of an export. Exports as a whole may run
+// longer than the write timeout of the server, which is extended page by page.
+const exportPageTimeout = 30 * time.Second
+
+// exportFormat describes a file format todos can be exported to
+type exportFormat struct {
+	contentType string
+	extension   string
+	newEncoder  func(w io.Writer) exportEncoder
+}
+
+// exportFormats lists the formats of GET /todos/export by the value of its format parameter
+var exportFormats = map[string]exportFormat{
+	"csv":   {contentType: "text/csv; charset=utf-8", extension: "csv", newEncoder: newCSVEncoder},
+	"ics":   {contentType: "text/calendar; charset=utf-8", extension: "ics", newEncoder: newICSEncoder},
+	"jsonl": {contentType: "application/x-ndjson", extension: "jsonl", newEncoder: newJSONLEncoder},
+	"xlsx": {
+		contentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		extension:   "xlsx",
+		newEncoder:  newXLSXEncoder,
+	},
+}
+
+// exportEncoder writes todos out page by page
+type exportEncoder interface {
+	// Encode writes a page of todos
+	Encode(todos []*entity.Todo) error
+	// Close writes whatever follows the last todo
+	Close() error
+}
+
+// ExportTodos handles downloading the todo items selected by the query filters as a
+// CSV, iCalendar, JSON Lines or XLSX file. Todos are written out page by page as they are read.
+func (h *TodoHandler) ExportTodos(c *gin.Context) {
+	name := c.DefaultQuery("format", "csv")
+	format, ok := exportFormats[name]
+	if !ok {
+		h.respondError(c, "Invalid query", &QueryError{Fields: []FieldError{{
+			Field:   "format",
+			Rule:    "oneof",
+			Message: "must be one of csv, ics, jsonl, xlsx",
+		}}})
+		return
+	}
+	h.export(c, format, "attachment")
+}
+
+// GetCalendar handles the calendar feed of the todo items selected by the query filters,
+// served inline for calendar clients subscribing to it
+func (h *TodoHandler) GetCalendar(c *gin.Context) {
+	h.export(c, exportFormats["ics"], "inline")
+}
+
+// export writes the todo items selected by the query filters in format, with the given
+// Content-Disposition type
+func (h *TodoHandler) export(c *gin.Context, format exportFormat, disposition string) {
+	filter, err := parseTodoFilter(c)
+	if err != nil {
+		h.respondError(c, "Invalid query", err)
+		return
+	}
+
+	// The response is committed once the first page is written, so failures before
+	// that are still reported as problems
+	encoder := format.newEncoder(c.Writer)
+	started := false
+	err = h.useCase.ExportTodos(c.Request.Context(), filter, func(todos []*entity.Todo) error {
+		extendWriteDeadline(c)
+		if !started {
+			startExport(c, format, disposition)
+			started = true
+		}
+		if err := encoder.Encode(todos); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err != nil && !started {
+		h.respondError(c, "Failed to export todos", err)
+		return
+	}
+	if err != nil {
+		// The status line is gone, so the truncated download is all the client gets
+		logger.FromContext(c.Request.Context()).Error("Failed to export todos", zap.Error(err))
+		return
+	}
+
+	extendWriteDeadline(c)
+	if !started {
+		startExport(c, format, disposition)
+	}
+	if err := encoder.Close(); err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to export todos", zap.Error(err))
+	}
+}
+
+// startExport writes the headers of an export
+func startExport(c *gin.Context, format exportFormat, disposition string) {
+	filename := "todos-" + time.Now().UTC().Format(time.DateOnly) + "." + format.extension
+	c.Header("Content-Type", format.contentType)
+	c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": filename}))
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+	c.Writer.WriteHeaderNow()
+}
+
+// extendWriteDeadline gives the export exportPageTimeout to write its next page
+func extendWriteDeadline(c *gin.Context) {
+	err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(exportPageTimeout))
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Warn("Failed to extend the write deadline of the export", zap.Error(err))
+	}
+}
+
+// exportRow returns the cells of a todo in the order of exportColumns
+func exportRow(todo *entity.Todo) []string {
+	return []string{
+		todo.ID.String(),
+		todo.Title,
+		todo.Description,
+		strconv.FormatBool(todo.Completed),
+		todo.ListID,
+		formatParentID(todo.ParentID),
+		formatDue(todo.Due),
+		strconv.Itoa(todo.Priority),
+		todo.ExternalID,
+		formatTimestamp(todo.CreatedAt),
+		formatTimestamp(todo.UpdatedAt),
+	}
+}
+
+// csvEncoder writes todos as CSV with a header row
+type csvEncoder struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func newCSVEncoder(w io.Writer) exportEncoder {
+	return &csvEncoder{w: csv.NewWriter(w)}
+}
+
+func (e *csvEncoder) Encode(todos []*entity.Todo) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	for _, todo := range todos {
+		row := exportRow(todo)
+		for i, cell := range row {
+			row[i] = escapeFormula(cell)
+		}
+		if err := e.w.Write(row); err != nil {
+			return err
+		}
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) Close() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// writeHeader writes the header row unless already written
+func (e *csvEncoder) writeHeader() error {
+	if e.wroteHeader {
+		return nil
+	}
+	e.wroteHeader = true
+	return e.w.Write(exportColumns)
+}
+
+// escapeFormula prefixes cells that spreadsheet applications would evaluate as formulas
+func escapeFormula(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// jsonlEncoder writes todos as JSON Lines, one todo per line in its API representation
+type jsonlEncoder struct {
+	enc *json.Encoder
+}
+
+func newJSONLEncoder(w io.Writer) exportEncoder {
+	return &jsonlEncoder{enc: json.NewEncoder(w)}
+}
+
+func (e *jsonlEncoder) Encode(todos []*entity.Todo) error {
+	for _, todo := range todos {
+		if err := e.enc.Encode(newTodoResponse(todo)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *jsonlEncoder) Close() error {
+	return nil
+}
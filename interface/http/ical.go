@@ -0,0 +1,351 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/usecase/todo"
+)
+
+const (
+	// icsProductID identifies the application in the calendars it writes
+	icsProductID = "-//todo-golang-rest-api//Todos//EN"
+	// icsLineOctets is the longest content line before it is folded (RFC 5545, section 3.1)
+	icsLineOctets = 75
+	// icsDateTimeLayout is the layout of UTC date-time values
+	icsDateTimeLayout = "20060102T150405Z"
+	// icsLocalDateTimeLayout is the layout of floating and TZID date-time values
+	icsLocalDateTimeLayout = "20060102T150405"
+	// icsDateLayout is the layout of DATE values
+	icsDateLayout = "20060102"
+)
+
+// icsEncoder writes todos as an RFC 5545 calendar of VTODO components, whose UID is the todo ID
+type icsEncoder struct {
+	w           *bufio.Writer
+	stamp       string
+	wroteHeader bool
+}
+
+func newICSEncoder(w io.Writer) exportEncoder {
+	return &icsEncoder{w: bufio.NewWriter(w), stamp: time.Now().UTC().Format(icsDateTimeLayout)}
+}
+
+func (e *icsEncoder) Encode(todos []*entity.Todo) error {
+	e.writeHeader()
+	for _, todo := range todos {
+		e.writeTodo(todo)
+	}
+	return e.w.Flush()
+}
+
+func (e *icsEncoder) Close() error {
+	e.writeHeader()
+	e.writeLine("END:VCALENDAR")
+	return e.w.Flush()
+}
+
+// writeHeader writes the calendar properties once
+func (e *icsEncoder) writeHeader() {
+	if e.wroteHeader {
+		return
+	}
+	e.wroteHeader = true
+	e.writeLine("BEGIN:VCALENDAR")
+	e.writeLine("VERSION:2.0")
+	e.writeLine("PRODID:" + icsProductID)
+	e.writeLine("CALSCALE:GREGORIAN")
+	e.writeLine("X-WR-CALNAME:Todos")
+}
+
+// writeTodo writes todo as a VTODO component. Completed todos have the COMPLETED status,
+// the list becomes the category and the parent is related with RELTYPE=PARENT.
+func (e *icsEncoder) writeTodo(todo *entity.Todo) {
+	e.writeLine("BEGIN:VTODO")
+	e.writeLine("UID:" + todo.ID.String())
+	e.writeLine("DTSTAMP:" + e.stamp)
+	e.writeLine("CREATED:" + todo.CreatedAt.UTC().Format(icsDateTimeLayout))
+	e.writeLine("LAST-MODIFIED:" + todo.UpdatedAt.UTC().Format(icsDateTimeLayout))
+	e.writeLine("SUMMARY:" + escapeICSText(todo.Title))
+	if todo.Description != "" {
+		e.writeLine("DESCRIPTION:" + escapeICSText(todo.Description))
+	}
+	if todo.Completed {
+		e.writeLine("STATUS:COMPLETED")
+	} else {
+		e.writeLine("STATUS:NEEDS-ACTION")
+	}
+	if todo.Priority > 0 {
+		e.writeLine("PRIORITY:" + strconv.Itoa(todo.Priority))
+	}
+	if !todo.Due.IsZero() {
+		e.writeLine("DUE:" + todo.Due.UTC().Format(icsDateTimeLayout))
+	}
+	if todo.ListID != "" {
+		e.writeLine("CATEGORIES:" + escapeICSText(todo.ListID))
+	}
+	if parentID := formatParentID(todo.ParentID); parentID != "" {
+		e.writeLine("RELATED-TO;RELTYPE=PARENT:" + parentID)
+	}
+	e.writeLine("END:VTODO")
+}
+
+// writeLine writes a content line, folded so no line exceeds icsLineOctets without
+// splitting a UTF-8 sequence. Errors are reported by the next Flush.
+func (e *icsEncoder) writeLine(line string) {
+	limit := icsLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		_, _ = e.w.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		// Continuation lines start with the folding space
+		limit = icsLineOctets - 1
+	}
+	_, _ = e.w.WriteString(line + "\r\n")
+}
+
+// encodeICSTodo returns the calendar holding todo alone, as a CalDAV resource does
+func encodeICSTodo(todo *entity.Todo) []byte {
+	var b bytes.Buffer
+	encoder := newICSEncoder(&b)
+	// Writing to a buffer does not fail
+	_ = encoder.Encode([]*entity.Todo{todo})
+	_ = encoder.Close()
+	return b.Bytes()
+}
+
+// todoICSProperties returns the properties of the VTODO component of todo
+func todoICSProperties(todo *entity.Todo) []icsProperty {
+	var properties []icsProperty
+	inTodo := false
+	for _, line := range unfoldICS(encodeICSTodo(todo)) {
+		property, err := parseICSLine(line)
+		if err != nil {
+			continue
+		}
+		switch {
+		case property.name == "BEGIN" && property.value == "VTODO":
+			inTodo = true
+		case property.name == "END" && property.value == "VTODO":
+			inTodo = false
+		case inTodo:
+			properties = append(properties, property)
+		}
+	}
+	return properties
+}
+
+// escapeICSText escapes a TEXT value (RFC 5545, section 3.3.11)
+func escapeICSText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`).Replace(s)
+}
+
+// icsProperty is a content line of an iCalendar file
+type icsProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// parseICSImport reads the VTODO components of an iCalendar file. The UID becomes the
+// external ID, a RELATED-TO parent the parent external ID and the first category the list.
+// Other components, such as VEVENT, are ignored.
+func parseICSImport(body []byte) ([]todo.ImportRow, error) {
+	lines := unfoldICS(body)
+	if len(lines) == 0 || !strings.EqualFold(lines[0], "BEGIN:VCALENDAR") {
+		return nil, &ImportFileError{Format: "ics", Reason: "must start with BEGIN:VCALENDAR"}
+	}
+
+	var rows []todo.ImportRow
+	var components []string
+	var properties []icsProperty
+	for i, line := range lines {
+		property, err := parseICSLine(line)
+		if err != nil {
+			return nil, &ImportFileError{Format: "ics", Reason: fmt.Sprintf("line %d: %s", i+1, err)}
+		}
+
+		switch property.name {
+		case "BEGIN":
+			components = append(components, strings.ToUpper(property.value))
+			if len(components) == 2 && components[1] == "VTODO" {
+				properties = properties[:0]
+			}
+		case "END":
+			if len(components) == 0 || components[len(components)-1] != strings.ToUpper(property.value) {
+				return nil, &ImportFileError{Format: "ics", Reason: fmt.Sprintf("line %d: unexpected END:%s", i+1, property.value)}
+			}
+			if len(components) == 2 && components[1] == "VTODO" {
+				rows = append(rows, newICSImportRow(properties))
+			}
+			components = components[:len(components)-1]
+		default:
+			// Properties of nested components, such as the VALARM of a VTODO, are ignored
+			if len(components) == 2 && components[1] == "VTODO" {
+				properties = append(properties, property)
+			}
+		}
+	}
+	if len(components) > 0 {
+		return nil, &ImportFileError{Format: "ics", Reason: "missing END:" + components[len(components)-1]}
+	}
+	return rows, nil
+}
+
+// unfoldICS splits body into content lines, joining folded lines and dropping blank ones
+func unfoldICS(body []byte) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(string(body), "\r\n", "\n"), "\n") {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// parseICSLine parses a content line, name *(";" param) ":" value, where parameter
+// values may be quoted
+func parseICSLine(line string) (icsProperty, error) {
+	property := icsProperty{params: make(map[string]string)}
+	quoted := false
+	start := 0
+	var parts []string
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '"':
+			quoted = !quoted
+		case !quoted && (c == ';' || c == ':'):
+			parts = append(parts, line[start:i])
+			start = i + 1
+			if c == ':' {
+				property.value = line[i+1:]
+				property.name = strings.ToUpper(parts[0])
+				for _, param := range parts[1:] {
+					key, value, _ := strings.Cut(param, "=")
+					property.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+				}
+				if property.name == "" {
+					return property, errors.New("missing property name")
+				}
+				return property, nil
+			}
+		}
+	}
+	return property, errors.New("missing ':' after the property name")
+}
+
+// newICSImportRow converts the properties of a VTODO component to an import row
+func newICSImportRow(properties []icsProperty) todo.ImportRow {
+	var request ImportTodoRequest
+	var fieldErrors []FieldError
+	for _, property := range properties {
+		switch property.name {
+		case "UID":
+			request.ExternalID = property.value
+		case "SUMMARY":
+			request.Title = unescapeICSText(property.value)
+		case "DESCRIPTION":
+			request.Description = unescapeICSText(property.value)
+		case "STATUS":
+			request.Completed = request.Completed || strings.EqualFold(property.value, "COMPLETED")
+		case "COMPLETED":
+			request.Completed = true
+		case "PRIORITY":
+			priority, err := strconv.Atoi(property.value)
+			if err != nil {
+				fieldErrors = append(fieldErrors, FieldError{Field: "priority", Rule: "type", Message: "must be a number"})
+				continue
+			}
+			request.Priority = priority
+		case "DUE":
+			due, err := parseICSDateTime(property)
+			if err != nil {
+				fieldErrors = append(fieldErrors, FieldError{Field: "due", Rule: "type", Message: "must be an iCalendar DATE or DATE-TIME"})
+				continue
+			}
+			request.Due = due.Format(time.RFC3339)
+		case "CATEGORIES":
+			if request.ListID == "" {
+				category, _ := splitICSList(property.value)
+				request.ListID = unescapeICSText(category)
+			}
+		case "RELATED-TO":
+			if relType, ok := property.params["RELTYPE"]; !ok || strings.EqualFold(relType, "PARENT") {
+				request.ParentExternalID = property.value
+			}
+		}
+	}
+	return newImportRow(request, validateRequest(&request, fieldErrors))
+}
+
+// parseICSDateTime parses a DATE or DATE-TIME value. Dates are taken as midnight UTC, and
+// floating times, or times of an unknown TZID, as UTC.
+func parseICSDateTime(property icsProperty) (time.Time, error) {
+	value := property.value
+	if strings.EqualFold(property.params["VALUE"], "DATE") || len(value) == len(icsDateLayout) {
+		return time.Parse(icsDateLayout, value)
+	}
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse(icsDateTimeLayout, value)
+	}
+
+	location := time.UTC
+	if tzid, ok := property.params["TZID"]; ok {
+		if loaded, err := time.LoadLocation(tzid); err == nil {
+			location = loaded
+		}
+	}
+	t, err := time.ParseInLocation(icsLocalDateTimeLayout, value, location)
+	return t.UTC(), err
+}
+
+// splitICSList returns the first value of a comma separated list and the rest, ignoring escaped commas
+func splitICSList(s string) (string, string) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ',':
+			return s[:i], s[i+1:]
+		}
+	}
+	return s, ""
+}
+
+// unescapeICSText reverses escapeICSText
+func unescapeICSText(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,215 @@
+package http
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/usecase/todo"
+	"go.uber.org/zap"
+)
+
+// maxImportBodyBytes bounds the size of imported files
+const maxImportBodyBytes = 10 << 20
+
+// importContentTypes maps the media types that identify the format of an imported file on their own
+var importContentTypes = map[string]string{
+	"text/csv":             "csv",
+	"text/calendar":        "ics",
+	"application/x-ndjson": "jsonl",
+	"application/jsonl":    "jsonl",
+}
+
+// ImportResponse represents an import and its outcome. Synchronous imports have no ID
+// and report every row; import jobs only report the rows that were not imported.
+type ImportResponse struct {
+	ID         string              `json:"id,omitempty"`
+	Status     string              `json:"status"`
+	Format     string              `json:"format"`
+	DryRun     bool                `json:"dry_run"`
+	Total      int                 `json:"total"`
+	Processed  int                 `json:"processed"`
+	Valid      int                 `json:"valid"`
+	Created    int                 `json:"created"`
+	Duplicates int                 `json:"duplicates"`
+	Invalid    int                 `json:"invalid"`
+	Failed     int                 `json:"failed"`
+	Rows       []ImportRowResponse `json:"rows"`
+	Error      string              `json:"error,omitempty"`
+	CreatedAt  string              `json:"created_at,omitempty"`
+	UpdatedAt  string              `json:"updated_at,omitempty"`
+	ExpiresAt  string              `json:"expires_at,omitempty"`
+}
+
+// ImportRowResponse represents the outcome of a row of an imported file
+type ImportRowResponse struct {
+	Row        int     `json:"row"`
+	ExternalID *string `json:"external_id"`
+	Status     string  `json:"status"`
+	// TodoID is the created todo, or the existing todo of a duplicate row
+	TodoID *string      `json:"todo_id"`
+	Errors []FieldError `json:"errors,omitempty"`
+	Reason string       `json:"reason,omitempty"`
+}
+
+// ImportHandler handles HTTP requests importing todos
+type ImportHandler struct {
+	useCase  *todo.ImportUseCase
+	syncRows int
+}
+
+// NewImportHandler creates a new ImportHandler instance. Files of more than syncRows
+// rows are imported by a background job.
+func NewImportHandler(useCase *todo.ImportUseCase, syncRows int) *ImportHandler {
+	return &ImportHandler{useCase: useCase, syncRows: syncRows}
+}
+
+// ImportTodos handles importing a file of todos. Small files are imported within the
+// request and answered with 200; large files, or requests preferring respond-async,
+// start a job answered with 202 and the job's location.
+func (h *ImportHandler) ImportTodos(c *gin.Context) {
+	format, dryRun, err := parseImportQuery(c)
+	if err != nil {
+		respondError(c, "Invalid query", err)
+		return
+	}
+
+	body, err := readBody(c, maxImportBodyBytes)
+	if err != nil {
+		respondError(c, "Invalid import file", err)
+		return
+	}
+	rows, err := parseImport(format, body)
+	if err != nil {
+		respondError(c, "Invalid import file", err, zap.String("format", format))
+		return
+	}
+	qualifyExternalIDs(format, rows)
+
+	async := prefersAsync(c)
+	if !async && len(rows) <= h.syncRows {
+		job, err := h.useCase.ImportTodos(c.Request.Context(), format, rows, dryRun)
+		if err != nil {
+			respondError(c, "Failed to import todos", err, zap.Int("rows", len(rows)))
+			return
+		}
+		c.JSON(http.StatusOK, newImportResponse(job, false))
+		return
+	}
+
+	job, err := h.useCase.StartImport(c.Request.Context(), format, rows, dryRun)
+	if err != nil {
+		respondError(c, "Failed to start import", err, zap.Int("rows", len(rows)))
+		return
+	}
+	c.Header("Vary", "Prefer")
+	if async {
+		c.Header("Preference-Applied", "respond-async")
+	}
+	c.Header("Location", "/imports/"+job.ID.String())
+	c.JSON(http.StatusAccepted, newImportResponse(job, true))
+}
+
+// GetImport handles retrieving the progress and outcome of an import job
+func (h *ImportHandler) GetImport(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondError(c, "Invalid import ID", fmt.Errorf("%w: %v", errInvalidImportID, err))
+		return
+	}
+
+	job, err := h.useCase.GetImport(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, "Failed to get import", err, zap.String("id", id.String()))
+		return
+	}
+	if job == nil {
+		respondError(c, "Import not found", errImportNotFound, zap.String("id", id.String()))
+		return
+	}
+
+	// Clients polling a running job are told when to come back
+	if job.Status == entity.ImportPending || job.Status == entity.ImportRunning {
+		c.Header("Retry-After", "1")
+	}
+	c.JSON(http.StatusOK, newImportResponse(job, true))
+}
+
+// parseImportQuery returns the format and dry_run parameters of an import. The format
+// defaults to the one identified by the Content-Type of the file, if any.
+func parseImportQuery(c *gin.Context) (string, bool, error) {
+	var fieldErrors []FieldError
+
+	format := c.Query("format")
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+		format = importContentTypes[mediaType]
+	}
+	if _, ok := importParsers[format]; !ok {
+		fieldErrors = append(fieldErrors, FieldError{
+			Field:   "format",
+			Rule:    "oneof",
+			Message: "must be one of csv, ics, jsonl, todoist, trello; it defaults to csv, ics or jsonl by Content-Type",
+		})
+	}
+
+	var dryRun bool
+	if value, ok := c.GetQuery("dry_run"); ok {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			fieldErrors = append(fieldErrors, FieldError{Field: "dry_run", Rule: "boolean", Message: "must be true or false"})
+		}
+	}
+
+	if len(fieldErrors) > 0 {
+		return "", false, &QueryError{Fields: fieldErrors}
+	}
+	return format, dryRun, nil
+}
+
+// newImportResponse converts an import to its wire format. The ID and timestamps are
+// only set for import jobs.
+func newImportResponse(job *entity.ImportJob, isJob bool) ImportResponse {
+	response := ImportResponse{
+		Status:     string(job.Status),
+		Format:     job.Format,
+		DryRun:     job.DryRun,
+		Total:      job.Total,
+		Processed:  job.Processed,
+		Valid:      job.Valid,
+		Created:    job.Created,
+		Duplicates: job.Duplicates,
+		Invalid:    job.Invalid,
+		Failed:     job.Failed,
+		Rows:       make([]ImportRowResponse, 0, len(job.Rows)),
+		Error:      job.Error,
+	}
+	if isJob {
+		response.ID = job.ID.String()
+		response.CreatedAt = formatTimestamp(job.CreatedAt)
+		response.UpdatedAt = formatTimestamp(job.UpdatedAt)
+		response.ExpiresAt = formatTimestamp(job.ExpiresAt)
+	}
+
+	for _, row := range job.Rows {
+		rowResponse := ImportRowResponse{
+			Row:        row.Row,
+			ExternalID: optionalString(row.ExternalID),
+			Status:     string(row.Status),
+			Reason:     row.Reason,
+		}
+		if row.TodoID != uuid.Nil {
+			todoID := row.TodoID.String()
+			rowResponse.TodoID = &todoID
+		}
+		for _, fe := range row.Errors {
+			rowResponse.Errors = append(rowResponse.Errors, FieldError(fe))
+		}
+		response.Rows = append(response.Rows, rowResponse)
+	}
+	return response
+}
@@ -0,0 +1,428 @@
+package http
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/usecase/todo"
+)
+
+// ImportFileError is returned when an imported file cannot be read as a whole
+type ImportFileError struct {
+	Format string
+	Reason string
+}
+
+func (e *ImportFileError) Error() string {
+	return "invalid " + e.Format + " file: " + e.Reason
+}
+
+// ImportTodoRequest represents a row of an imported file, whatever its format
+type ImportTodoRequest struct {
+	Title       string `json:"title" binding:"required,notblank,max=200"`
+	Description string `json:"description" binding:"max=2000"`
+	Completed   bool   `json:"completed"`
+	ListID      string `json:"list_id" binding:"max=64"`
+	// ParentID is an existing todo, ParentExternalID a todo of the file or of an earlier import
+	ParentID         string `json:"parent_id" binding:"omitempty,uuid"`
+	Due              string `json:"due" binding:"omitempty,timestamp"`
+	Priority         int    `json:"priority" binding:"min=0,max=9"`
+	ExternalID       string `json:"external_id" binding:"max=255"`
+	ParentExternalID string `json:"parent_external_id" binding:"max=255"`
+}
+
+// importParsers reads the rows of an imported file by the value of the format parameter
+var importParsers = map[string]func(body []byte) ([]todo.ImportRow, error){
+	"csv":     parseCSVImport,
+	"ics":     parseICSImport,
+	"jsonl":   parseJSONLImport,
+	"todoist": parseTodoistImport,
+	"trello":  parseTrelloImport,
+}
+
+// parseImport reads the rows of an imported file in the given format
+func parseImport(format string, body []byte) ([]todo.ImportRow, error) {
+	if !utf8.Valid(body) {
+		return nil, &ImportFileError{Format: format, Reason: "must be valid UTF-8"}
+	}
+	body = bytes.TrimPrefix(body, []byte("\ufeff"))
+
+	rows, err := importParsers[format](body)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, &ImportFileError{Format: format, Reason: "contains no rows"}
+	}
+	return rows, nil
+}
+
+// qualifyExternalIDs prefixes the external IDs of rows with the format they are imported
+// from, so that the IDs of different tools never collide. IDs already prefixed with a format,
+// as those of exported todos are, are kept.
+func qualifyExternalIDs(format string, rows []todo.ImportRow) {
+	for i := range rows {
+		rows[i].ExternalID = qualifyExternalID(format, rows[i].ExternalID)
+		rows[i].ParentExternalID = qualifyExternalID(format, rows[i].ParentExternalID)
+	}
+}
+
+// qualifyExternalID prefixes id with format unless it is empty or prefixed already
+func qualifyExternalID(format, id string) string {
+	if id == "" {
+		return ""
+	}
+	if source, _, ok := strings.Cut(id, ":"); ok {
+		if _, known := importParsers[source]; known {
+			return id
+		}
+	}
+	return format + ":" + id
+}
+
+// parseCSVImport reads a CSV file with a header row naming the fields of ImportTodoRequest.
+// title is the only required column and unknown columns are ignored.
+func parseCSVImport(body []byte) ([]todo.ImportRow, error) {
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &ImportFileError{Format: "csv", Reason: err.Error()}
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, &ImportFileError{Format: "csv", Reason: "the header row must have a title column"}
+	}
+
+	var rows []todo.ImportRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, &ImportFileError{Format: "csv", Reason: err.Error()}
+		}
+
+		cell := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return unescapeFormula(record[i])
+		}
+
+		request := ImportTodoRequest{
+			Title:            cell("title"),
+			Description:      cell("description"),
+			ListID:           cell("list_id"),
+			ParentID:         cell("parent_id"),
+			Due:              cell("due"),
+			ExternalID:       cell("external_id"),
+			ParentExternalID: cell("parent_external_id"),
+		}
+		var fieldErrors []FieldError
+		if value := strings.TrimSpace(cell("completed")); value != "" {
+			if request.Completed, err = strconv.ParseBool(value); err != nil {
+				fieldErrors = append(fieldErrors, FieldError{Field: "completed", Rule: "type", Message: "must be true or false"})
+			}
+		}
+		if value := strings.TrimSpace(cell("priority")); value != "" {
+			if request.Priority, err = strconv.Atoi(value); err != nil {
+				fieldErrors = append(fieldErrors, FieldError{Field: "priority", Rule: "type", Message: "must be a number"})
+			}
+		}
+		rows = append(rows, newImportRow(request, validateRequest(&request, fieldErrors)))
+	}
+}
+
+// unescapeFormula removes the quote escapeFormula adds, so exported files import unchanged
+func unescapeFormula(cell string) string {
+	if len(cell) > 1 && cell[0] == '\'' && strings.ContainsRune("=+-@\t\r", rune(cell[1])) {
+		return cell[1:]
+	}
+	return cell
+}
+
+// parseJSONLImport reads a JSON Lines file with one ImportTodoRequest object per line.
+// Blank lines are skipped and unknown fields are ignored, so exports import as they are.
+func parseJSONLImport(body []byte) ([]todo.ImportRow, error) {
+	var rows []todo.ImportRow
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		var request ImportTodoRequest
+		err := decodeJSON(line, &request)
+		if errors.Is(err, errMalformedBody) {
+			err = &ValidationError{Fields: []FieldError{{Field: "", Rule: "type", Message: "must be a JSON object"}}}
+		}
+		rows = append(rows, newImportRow(request, ignoreUnknownFields(err)))
+	}
+	return rows, nil
+}
+
+// ignoreUnknownFields drops the violations of unknown fields from a ValidationError
+func ignoreUnknownFields(err error) error {
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		return err
+	}
+
+	var fieldErrors []FieldError
+	for _, fe := range validationErr.Fields {
+		if fe.Rule != "unknown" {
+			fieldErrors = append(fieldErrors, fe)
+		}
+	}
+	if len(fieldErrors) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: fieldErrors}
+}
+
+// todoistTask is a task of a Todoist export, as returned by its REST and Sync APIs
+type todoistTask struct {
+	ID          flexibleString `json:"id"`
+	Content     string         `json:"content"`
+	Description string         `json:"description"`
+	Checked     flexibleBool   `json:"checked"`
+	IsCompleted bool           `json:"is_completed"`
+	ProjectID   flexibleString `json:"project_id"`
+	ParentID    flexibleString `json:"parent_id"`
+	// Priority is from 1 (natural) to 4 (urgent)
+	Priority int `json:"priority"`
+	Due      *struct {
+		// Date is either a date or a date-time, local to the user unless it has an offset
+		Date     string `json:"date"`
+		Datetime string `json:"datetime"`
+	} `json:"due"`
+}
+
+// todoistPriorities maps Todoist priorities to RFC 5545 ones
+var todoistPriorities = map[int]int{4: 1, 3: 3, 2: 5}
+
+// due returns the due date of the task as an RFC 3339 timestamp. Dates and local times are taken as UTC.
+func (t todoistTask) due() string {
+	if t.Due == nil {
+		return ""
+	}
+	due := t.Due.Datetime
+	if due == "" {
+		due = t.Due.Date
+	}
+	switch {
+	case len(due) == len(time.DateOnly):
+		return due + "T00:00:00Z"
+	case len(due) == len("2006-01-02T15:04:05"):
+		return due + "Z"
+	default:
+		return due
+	}
+}
+
+// parseTodoistImport reads a Todoist export: either an array of tasks or an object with
+// the tasks in items. The project becomes the list and subtasks keep their parent.
+func parseTodoistImport(body []byte) ([]todo.ImportRow, error) {
+	var tasks []todoistTask
+	var err error
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &tasks)
+	} else {
+		var export struct {
+			Items []todoistTask `json:"items"`
+		}
+		err = json.Unmarshal(trimmed, &export)
+		tasks = export.Items
+	}
+	if err != nil {
+		return nil, &ImportFileError{Format: "todoist", Reason: err.Error()}
+	}
+
+	rows := make([]todo.ImportRow, 0, len(tasks))
+	for _, task := range tasks {
+		request := ImportTodoRequest{
+			Title:            task.Content,
+			Description:      task.Description,
+			Completed:        bool(task.Checked) || task.IsCompleted,
+			ListID:           string(task.ProjectID),
+			Due:              task.due(),
+			Priority:         todoistPriorities[task.Priority],
+			ExternalID:       string(task.ID),
+			ParentExternalID: string(task.ParentID),
+		}
+		rows = append(rows, newImportRow(request, validateRequest(&request, nil)))
+	}
+	return rows, nil
+}
+
+// trelloBoard is the JSON export of a Trello board
+type trelloBoard struct {
+	Cards []struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		Desc        string `json:"desc"`
+		Closed      bool   `json:"closed"`
+		Due         string `json:"due"`
+		DueComplete bool   `json:"dueComplete"`
+		IDList      string `json:"idList"`
+	} `json:"cards"`
+	Checklists []struct {
+		IDCard     string `json:"idCard"`
+		CheckItems []struct {
+			ID    string `json:"id"`
+			Name  string `json:"name"`
+			State string `json:"state"`
+		} `json:"checkItems"`
+	} `json:"checklists"`
+}
+
+// parseTrelloImport reads a Trello board export. Cards become todos in the list of their
+// Trello list, followed by the items of their checklists as subtasks. Archived cards are skipped.
+func parseTrelloImport(body []byte) ([]todo.ImportRow, error) {
+	var board trelloBoard
+	if err := json.Unmarshal(body, &board); err != nil {
+		return nil, &ImportFileError{Format: "trello", Reason: err.Error()}
+	}
+
+	var rows []todo.ImportRow
+	lists := make(map[string]string, len(board.Cards))
+	for _, card := range board.Cards {
+		if card.Closed {
+			continue
+		}
+		lists[card.ID] = card.IDList
+		request := ImportTodoRequest{
+			Title:       card.Name,
+			Description: card.Desc,
+			Completed:   card.DueComplete,
+			ListID:      card.IDList,
+			Due:         card.Due,
+			ExternalID:  card.ID,
+		}
+		rows = append(rows, newImportRow(request, validateRequest(&request, nil)))
+	}
+
+	for _, checklist := range board.Checklists {
+		listID, ok := lists[checklist.IDCard]
+		if !ok {
+			continue
+		}
+		for _, item := range checklist.CheckItems {
+			request := ImportTodoRequest{
+				Title:            item.Name,
+				Completed:        item.State == "complete",
+				ListID:           listID,
+				ExternalID:       item.ID,
+				ParentExternalID: checklist.IDCard,
+			}
+			rows = append(rows, newImportRow(request, validateRequest(&request, nil)))
+		}
+	}
+	return rows, nil
+}
+
+// newImportRow converts a row and the error of its validation to the use case input
+func newImportRow(request ImportTodoRequest, err error) todo.ImportRow {
+	var fieldErrors []FieldError
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		fieldErrors = validationErr.Fields
+	} else if err != nil {
+		fieldErrors = []FieldError{{Field: "", Rule: "valid", Message: err.Error()}}
+	}
+	if request.ParentID != "" && request.ParentExternalID != "" {
+		fieldErrors = append(fieldErrors, FieldError{
+			Field:   "parent_id",
+			Rule:    "excluded_with",
+			Message: "must not be set together with parent_external_id",
+		})
+	}
+
+	row := todo.ImportRow{
+		Todo: entity.TodoCreate{
+			Title:       request.Title,
+			Description: request.Description,
+			Completed:   request.Completed,
+			ListID:      request.ListID,
+			Due:         parseDue(request.Due),
+			Priority:    request.Priority,
+		},
+		ExternalID:       request.ExternalID,
+		ParentExternalID: request.ParentExternalID,
+	}
+	if len(fieldErrors) > 0 {
+		for _, fe := range fieldErrors {
+			row.Errors = append(row.Errors, entity.ImportFieldError(fe))
+		}
+		return row
+	}
+	row.Todo.ParentID, _ = uuid.Parse(request.ParentID)
+	return row
+}
+
+// flexibleString decodes a JSON string or number, as IDs are numbers in older exports
+type flexibleString string
+
+func (s *flexibleString) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*s = ""
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var value string
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		*s = flexibleString(value)
+		return nil
+	}
+
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return fmt.Errorf("must be a string or a number: %w", err)
+	}
+	*s = flexibleString(number.String())
+	return nil
+}
+
+// flexibleBool decodes a JSON boolean, or a number where 0 is false as in older exports
+type flexibleBool bool
+
+func (b *flexibleBool) UnmarshalJSON(data []byte) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch value := value.(type) {
+	case nil:
+		*b = false
+	case bool:
+		*b = flexibleBool(value)
+	case float64:
+		*b = value != 0
+	default:
+		return errors.New("must be a boolean or a number")
+	}
+	return nil
+}
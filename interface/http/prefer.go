@@ -0,0 +1,58 @@
+package http
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	returnRepresentation = "representation"
+	returnMinimal        = "minimal"
+)
+
+// returnPreference returns the value of the "return" preference of the Prefer
+// request header (RFC 7240), or an empty string when the client expressed none
+func returnPreference(c *gin.Context) string {
+	for _, header := range c.Request.Header.Values("Prefer") {
+		for _, preference := range strings.Split(header, ",") {
+			// Parameters following the value are irrelevant for "return"
+			token, _, _ := strings.Cut(preference, ";")
+			name, value, found := strings.Cut(token, "=")
+			if !found || !strings.EqualFold(strings.TrimSpace(name), "return") {
+				continue
+			}
+
+			value = strings.ToLower(strings.Trim(strings.TrimSpace(value), `"`))
+			if value == returnRepresentation || value == returnMinimal {
+				return value
+			}
+		}
+	}
+	return ""
+}
+
+// applyReturnPreference reports the honoured "return" preference and returns whether
+// the client asked for the resource representation
+func applyReturnPreference(c *gin.Context) bool {
+	c.Header("Vary", "Prefer")
+
+	preference := returnPreference(c)
+	if preference != "" {
+		c.Header("Preference-Applied", "return="+preference)
+	}
+	return preference == returnRepresentation
+}
+
+// prefersAsync reports whether the Prefer request header has the "respond-async" preference (RFC 7240)
+func prefersAsync(c *gin.Context) bool {
+	for _, header := range c.Request.Header.Values("Prefer") {
+		for _, preference := range strings.Split(header, ",") {
+			token, _, _ := strings.Cut(preference, ";")
+			if strings.EqualFold(strings.TrimSpace(token), "respond-async") {
+				return true
+			}
+		}
+	}
+	return false
+}
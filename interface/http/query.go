@@ -0,0 +1,62 @@
+package http
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+)
+
+// QueryError is returned when query parameters are invalid
+type QueryError struct {
+	Fields []FieldError
+}
+
+func (e *QueryError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, field.Field+" "+field.Message)
+	}
+	return "invalid query: " + strings.Join(messages, ", ")
+}
+
+// parseTodoFilter parses the filters of the todo listing from the query string:
+// completed, list_id and parent_id. An empty list_id or parent_id selects the todos
+// not in a list or without a parent.
+func parseTodoFilter(c *gin.Context) (entity.TodoFilter, error) {
+	var filter entity.TodoFilter
+	var fieldErrors []FieldError
+
+	if value, ok := c.GetQuery("completed"); ok {
+		completed, err := strconv.ParseBool(value)
+		if err != nil {
+			fieldErrors = append(fieldErrors, FieldError{Field: "completed", Rule: "boolean", Message: "must be true or false"})
+		} else {
+			filter.Completed = &completed
+		}
+	}
+	if value, ok := c.GetQuery("list_id"); ok {
+		if len(value) > 64 {
+			fieldErrors = append(fieldErrors, FieldError{Field: "list_id", Rule: "max", Message: "must be at most 64 characters"})
+		} else {
+			filter.ListID = &value
+		}
+	}
+	if value, ok := c.GetQuery("parent_id"); ok {
+		parentID := uuid.Nil
+		if value != "" {
+			var err error
+			if parentID, err = uuid.Parse(value); err != nil {
+				fieldErrors = append(fieldErrors, FieldError{Field: "parent_id", Rule: "uuid", Message: "must be a UUID, or empty for top-level todos"})
+			}
+		}
+		filter.ParentID = &parentID
+	}
+
+	if len(fieldErrors) > 0 {
+		return entity.TodoFilter{}, &QueryError{Fields: fieldErrors}
+	}
+	return filter, nil
+}
@@ -0,0 +1,102 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/tenant"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/subscription"
+)
+
+// SubscriptionResponse represents the subscription URL of the calendar feed
+type SubscriptionResponse struct {
+	URL string `json:"url"`
+	// ExpiresAt is null when the URL never expires
+	ExpiresAt *string `json:"expires_at"`
+	// CalDAV holds the credentials of CalDAV clients, which may also write todos
+	CalDAV CalDAVAccountResponse `json:"caldav"`
+}
+
+// CalDAVAccountResponse represents the account CalDAV clients sign in with to sync todos both ways
+type CalDAVAccountResponse struct {
+	URL      string `json:"url"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// ExpiresAt is null when the password never expires
+	ExpiresAt *string `json:"expires_at"`
+}
+
+// SubscriptionHandler handles HTTP requests issuing calendar subscription URLs
+type SubscriptionHandler struct {
+	tokens  *subscription.Tokens
+	baseURL string
+}
+
+// NewSubscriptionHandler creates a new SubscriptionHandler instance. URLs start with
+// baseURL, or with the scheme and host of the request when it is empty.
+func NewSubscriptionHandler(tokens *subscription.Tokens, baseURL string) *SubscriptionHandler {
+	return &SubscriptionHandler{tokens: tokens, baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// CreateSubscription handles issuing a URL of the calendar feed of the tenant that calendar
+// clients can subscribe to without credentials. The filters of the query string are carried
+// over to the URL.
+func (h *SubscriptionHandler) CreateSubscription(c *gin.Context) {
+	if _, err := parseTodoFilter(c); err != nil {
+		respondError(c, "Invalid query", err)
+		return
+	}
+	tenantID, err := tenant.FromContext(c.Request.Context())
+	if err != nil {
+		respondError(c, "Failed to create subscription", err)
+		return
+	}
+
+	now := time.Now()
+	token, expiresAt := h.tokens.Issue(tenantID, subscription.ScopeFeed, now)
+	// The URL travels in calendar settings and logs, so it only grants reading the feed;
+	// CalDAV clients, which also write todos, get a password of their own
+	password, passwordExpiresAt := h.tokens.Issue(tenantID, subscription.ScopeCalDAV, now)
+	query := url.Values{}
+	for _, name := range []string{"completed", "list_id", "parent_id"} {
+		if value, ok := c.GetQuery(name); ok {
+			query.Set(name, value)
+		}
+	}
+	query.Set("token", token)
+
+	baseURL := h.baseURL
+	if baseURL == "" {
+		scheme := "http"
+		if c.Request.TLS != nil {
+			scheme = "https"
+		}
+		baseURL = scheme + "://" + c.Request.Host
+	}
+
+	response := SubscriptionResponse{
+		URL: baseURL + "/todos.ics?" + query.Encode(),
+		CalDAV: CalDAVAccountResponse{
+			URL:       baseURL + calDAVRootPath,
+			Username:  string(tenantID),
+			Password:  password,
+			ExpiresAt: optionalTimestamp(passwordExpiresAt),
+		},
+	}
+	response.ExpiresAt = optionalTimestamp(expiresAt)
+	// The URL and the CalDAV password are credentials
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, response)
+}
+
+// optionalTimestamp formats t for response bodies, nil for the zero time, serialized as null
+func optionalTimestamp(t time.Time) *string {
+	if t.IsZero() {
+		return nil
+	}
+	formatted := formatTimestamp(t)
+	return &formatted
+}
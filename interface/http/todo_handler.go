@@ -3,208 +3,244 @@ package http
 import (
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/problem"
 	"github.com/gotokazuki/todo-golang-rest-api/app/todo/usecase/todo"
 	"go.uber.org/zap"
 )
 
-// TodoHandler handles HTTP requests for todo operations
+// TodoHandler handles HTTP requests for todo operations.
+// It logs through the request-scoped logger carried by the request context.
 type TodoHandler struct {
 	useCase *todo.TodoUseCase
-	logger  *zap.Logger
 }
 
 // NewTodoHandler creates a new TodoHandler instance
-func NewTodoHandler(useCase *todo.TodoUseCase, logger *zap.Logger) *TodoHandler {
+func NewTodoHandler(useCase *todo.TodoUseCase) *TodoHandler {
 	return &TodoHandler{
 		useCase: useCase,
-		logger:  logger,
 	}
 }
 
 // CreateTodo handles the creation of a new todo item
 func (h *TodoHandler) CreateTodo(c *gin.Context) {
-	var input entity.TodoCreate
-	if err := c.ShouldBindJSON(&input); err != nil {
-		h.logger.Warn("Invalid request body",
-			zap.Error(err),
-			zap.String("path", c.Request.URL.Path),
-			zap.String("method", c.Request.Method),
-		)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request body",
-		})
+	var request CreateTodoRequest
+	if err := bindJSON(c, &request); err != nil {
+		h.respondError(c, "Invalid request body", err)
 		return
 	}
 
-	createdTodo, err := h.useCase.CreateTodo(input)
+	createdTodo, err := h.useCase.CreateTodo(c.Request.Context(), request.toEntity())
 	if err != nil {
-		h.logger.Error("Failed to create todo",
-			zap.Error(err),
-			zap.String("path", c.Request.URL.Path),
-			zap.String("method", c.Request.Method),
-		)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to create todo",
-		})
+		h.respondError(c, "Failed to create todo", err)
 		return
 	}
 
 	location := fmt.Sprintf("/todos/%s", createdTodo.ID.String())
 	c.Header("Location", location)
+	if applyReturnPreference(c) {
+		c.JSON(http.StatusCreated, newTodoResponse(createdTodo))
+		return
+	}
 	c.Status(http.StatusCreated)
 }
 
-// GetTodos handles retrieving all todo items
+// GetTodos handles retrieving the todo items selected by the query filters
 func (h *TodoHandler) GetTodos(c *gin.Context) {
-	todos, err := h.useCase.GetTodos()
+	filter, err := parseTodoFilter(c)
 	if err != nil {
-		h.logger.Error("Failed to get todos",
-			zap.Error(err),
-			zap.String("path", c.Request.URL.Path),
-			zap.String("method", c.Request.Method),
-		)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to get todos",
-		})
+		h.respondError(c, "Invalid query", err)
+		return
+	}
+
+	todos, err := h.useCase.GetTodos(c.Request.Context(), filter)
+	if err != nil {
+		h.respondError(c, "Failed to get todos", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, todos)
+	c.JSON(http.StatusOK, newTodoListResponse(todos))
 }
 
 // GetTodo handles retrieving a specific todo item
 func (h *TodoHandler) GetTodo(c *gin.Context) {
-	id, err := uuid.Parse(c.Param("id"))
+	id, err := parseTodoID(c)
 	if err != nil {
-		h.logger.Warn("Invalid todo ID",
-			zap.Error(err),
-			zap.String("path", c.Request.URL.Path),
-			zap.String("method", c.Request.Method),
-		)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid todo ID",
-		})
+		h.respondError(c, "Invalid todo ID", err)
 		return
 	}
 
-	todo, err := h.useCase.GetTodo(id)
+	todo, err := h.useCase.GetTodo(c.Request.Context(), id)
 	if err != nil {
-		h.logger.Error("Failed to get todo",
-			zap.Error(err),
-			zap.String("path", c.Request.URL.Path),
-			zap.String("method", c.Request.Method),
-			zap.String("id", id.String()),
-		)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to get todo",
-		})
+		h.respondError(c, "Failed to get todo", err, zap.String("id", id.String()))
 		return
 	}
 
 	if todo == nil {
-		h.logger.Warn("Todo not found",
-			zap.String("path", c.Request.URL.Path),
-			zap.String("method", c.Request.Method),
-			zap.String("id", id.String()),
-		)
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Todo not found",
-		})
+		h.respondError(c, "Todo not found", errTodoNotFound, zap.String("id", id.String()))
 		return
 	}
 
-	c.JSON(http.StatusOK, todo)
+	c.JSON(http.StatusOK, newTodoResponse(todo))
 }
 
 // UpdateTodo handles updating an existing todo item
 func (h *TodoHandler) UpdateTodo(c *gin.Context) {
-	id, err := uuid.Parse(c.Param("id"))
+	id, err := parseTodoID(c)
 	if err != nil {
-		h.logger.Warn("Invalid todo ID",
-			zap.Error(err),
-			zap.String("path", c.Request.URL.Path),
-			zap.String("method", c.Request.Method),
-		)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid todo ID",
-		})
+		h.respondError(c, "Invalid todo ID", err)
 		return
 	}
 
-	var input entity.TodoUpdate
-	if err := c.ShouldBindJSON(&input); err != nil {
-		h.logger.Warn("Invalid request body",
-			zap.Error(err),
-			zap.String("path", c.Request.URL.Path),
-			zap.String("method", c.Request.Method),
-		)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request body",
-		})
+	var request UpdateTodoRequest
+	if err := bindJSON(c, &request); err != nil {
+		h.respondError(c, "Invalid request body", err)
 		return
 	}
 
-	updatedTodo, err := h.useCase.UpdateTodo(id, input)
+	updatedTodo, err := h.useCase.UpdateTodo(c.Request.Context(), id, request.toEntity())
 	if err != nil {
-		h.logger.Error("Failed to update todo",
-			zap.Error(err),
-			zap.String("path", c.Request.URL.Path),
-			zap.String("method", c.Request.Method),
-			zap.String("id", id.String()),
-		)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to update todo",
-		})
+		h.respondError(c, "Failed to update todo", err, zap.String("id", id.String()))
 		return
 	}
 
 	if updatedTodo == nil {
-		h.logger.Warn("Todo not found",
-			zap.String("path", c.Request.URL.Path),
-			zap.String("method", c.Request.Method),
-			zap.String("id", id.String()),
-		)
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Todo not found",
-		})
+		h.respondError(c, "Todo not found", errTodoNotFound, zap.String("id", id.String()))
 		return
 	}
 
+	if applyReturnPreference(c) {
+		c.JSON(http.StatusOK, newTodoResponse(updatedTodo))
+		return
+	}
 	c.Status(http.StatusNoContent)
 }
 
+// ReplaceTodo handles creating a todo with a client supplied ID or replacing an existing one
+func (h *TodoHandler) ReplaceTodo(c *gin.Context) {
+	id, err := parseTodoID(c)
+	if err != nil {
+		h.respondError(c, "Invalid todo ID", err)
+		return
+	}
+
+	var request ReplaceTodoRequest
+	if err := bindJSON(c, &request); err != nil {
+		h.respondError(c, "Invalid request body", err)
+		return
+	}
+
+	replacedTodo, created, err := h.useCase.ReplaceTodo(c.Request.Context(), id, request.toEntity())
+	if err != nil {
+		h.respondError(c, "Failed to replace todo", err, zap.String("id", id.String()))
+		return
+	}
+
+	if created {
+		location := fmt.Sprintf("/todos/%s", replacedTodo.ID.String())
+		c.Header("Location", location)
+		c.JSON(http.StatusCreated, newTodoResponse(replacedTodo))
+		return
+	}
+
+	c.JSON(http.StatusOK, newTodoResponse(replacedTodo))
+}
+
 // DeleteTodo handles deleting a todo item
 func (h *TodoHandler) DeleteTodo(c *gin.Context) {
-	id, err := uuid.Parse(c.Param("id"))
+	id, err := parseTodoID(c)
 	if err != nil {
-		h.logger.Warn("Invalid todo ID",
-			zap.Error(err),
-			zap.String("path", c.Request.URL.Path),
-			zap.String("method", c.Request.Method),
-		)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid todo ID",
-		})
+		h.respondError(c, "Invalid todo ID", err)
 		return
 	}
 
-	err = h.useCase.DeleteTodo(id)
+	err = h.useCase.DeleteTodo(c.Request.Context(), id)
 	if err != nil {
-		h.logger.Error("Failed to delete todo",
-			zap.Error(err),
-			zap.String("path", c.Request.URL.Path),
-			zap.String("method", c.Request.Method),
-			zap.String("id", id.String()),
-		)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to delete todo",
-		})
+		h.respondError(c, "Failed to delete todo", err, zap.String("id", id.String()))
 		return
 	}
 
 	c.Status(http.StatusNoContent)
 }
+
+// CollectionAction handles the custom methods of the todo collection, such as POST /todos:batch
+func (h *TodoHandler) CollectionAction(c *gin.Context) {
+	switch c.Param("action") {
+	case "todos:batch":
+		h.BatchTodos(c)
+	case "todos:move":
+		h.MoveTodos(c)
+	default:
+		problem.Abort(c, problem.New(http.StatusNotFound, problem.CodeRouteNotFound, "No route matches the request"))
+	}
+}
+
+// TodoAction handles the custom methods of a todo, such as POST /todos/{id}:complete
+func (h *TodoHandler) TodoAction(c *gin.Context) {
+	id, action, _ := strings.Cut(c.Param("id"), ":")
+	switch action {
+	case "complete":
+		h.CompleteTodo(c, id)
+	default:
+		problem.Abort(c, problem.New(http.StatusNotFound, problem.CodeRouteNotFound, "No route matches the request"))
+	}
+}
+
+// MoveTodos handles moving every todo of a list to another list, all or nothing
+func (h *TodoHandler) MoveTodos(c *gin.Context) {
+	var request MoveTodosRequest
+	if err := bindJSON(c, &request); err != nil {
+		h.respondError(c, "Invalid request body", err)
+		return
+	}
+
+	moved, err := h.useCase.MoveTodos(c.Request.Context(), request.FromListID, request.ToListID)
+	if err != nil {
+		h.respondError(c, "Failed to move todos", err,
+			zap.String("from_list_id", request.FromListID),
+			zap.String("to_list_id", request.ToListID),
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, newTodoListResponse(moved))
+}
+
+// CompleteTodo handles completing a todo and all its descendants, all or nothing
+func (h *TodoHandler) CompleteTodo(c *gin.Context, rawID string) {
+	id, err := parseID(rawID)
+	if err != nil {
+		h.respondError(c, "Invalid todo ID", err)
+		return
+	}
+
+	completed, err := h.useCase.CompleteTodoTree(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, "Failed to complete todo", err, zap.String("id", id.String()))
+		return
+	}
+
+	if completed == nil {
+		h.respondError(c, "Todo not found", errTodoNotFound, zap.String("id", id.String()))
+		return
+	}
+
+	c.JSON(http.StatusOK, newTodoListResponse(completed))
+}
+
+// parseTodoID parses the todo ID path parameter
+func parseTodoID(c *gin.Context) (uuid.UUID, error) {
+	return parseID(c.Param("id"))
+}
+
+// parseID parses a todo ID
+func parseID(raw string) (uuid.UUID, error) {
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: %v", errInvalidTodoID, err)
+	}
+	return id, nil
+}
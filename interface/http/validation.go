@@ -0,0 +1,253 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/text/unicode/norm"
+)
+
+// maxRequestBodyBytes bounds the size of JSON request bodies
+const maxRequestBodyBytes = 64 << 10
+
+// errRequestTooLarge is returned when a request body exceeds maxRequestBodyBytes
+var errRequestTooLarge = errors.New("request body too large")
+
+// FieldError describes a request field violating a validation rule
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned when a request body violates one or more validation rules
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, field.Field+" "+field.Message)
+	}
+	return "validation failed: " + strings.Join(messages, ", ")
+}
+
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	// Report fields by their JSON names
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	_ = v.RegisterValidation("notblank", func(fl validator.FieldLevel) bool {
+		return strings.TrimSpace(fl.Field().String()) != ""
+	})
+	_ = v.RegisterValidation("timestamp", func(fl validator.FieldLevel) bool {
+		_, err := time.Parse(time.RFC3339, fl.Field().String())
+		return err == nil
+	})
+}
+
+// bindJSON decodes the JSON request body into dst, a pointer to a request struct.
+// Unknown fields, values of the wrong type, invalid UTF-8 and violations of the
+// binding tags are all collected into a single ValidationError. Strings are
+// normalized to Unicode NFC before validation.
+func bindJSON(c *gin.Context, dst any) error {
+	body, err := readBody(c, maxRequestBodyBytes)
+	if err != nil {
+		return err
+	}
+	return decodeJSON(body, dst)
+}
+
+// readBody reads the request body, failing with errRequestTooLarge beyond limit bytes
+func readBody(c *gin.Context, limit int64) ([]byte, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, limit))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, fmt.Errorf("%w: %w", errRequestTooLarge, maxBytesErr)
+		}
+		return nil, fmt.Errorf("%w: %v", errMalformedBody, err)
+	}
+	return body, nil
+}
+
+// decodeJSON decodes a JSON object into dst as described by bindJSON
+func decodeJSON(body []byte, dst any) error {
+	if !utf8.Valid(body) {
+		return &ValidationError{Fields: []FieldError{{
+			Field:   "",
+			Rule:    "utf8",
+			Message: "must be valid UTF-8",
+		}}}
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return fmt.Errorf("%w: %v", errMalformedBody, err)
+	}
+
+	var fieldErrors []FieldError
+	target := reflect.ValueOf(dst).Elem()
+	known := make(map[string]bool)
+	for i := 0; i < target.NumField(); i++ {
+		name, _, _ := strings.Cut(target.Type().Field(i).Tag.Get("json"), ",")
+		known[name] = true
+
+		value, ok := raw[name]
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal(value, target.Field(i).Addr().Interface()); err != nil {
+			fieldErrors = append(fieldErrors, FieldError{
+				Field:   name,
+				Rule:    "type",
+				Message: "must be a " + jsonTypeName(target.Type().Field(i).Type),
+			})
+		}
+	}
+
+	unknown := make([]string, 0)
+	for name := range raw {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		fieldErrors = append(fieldErrors, FieldError{
+			Field:   name,
+			Rule:    "unknown",
+			Message: "is not a known field",
+		})
+	}
+
+	return validateRequest(dst, fieldErrors)
+}
+
+// validateRequest normalizes the strings of dst, a pointer to a request struct, and checks
+// its binding tags. Violations are added to fieldErrors, except for the fields already
+// there, and all of them are returned as a single ValidationError.
+func validateRequest(dst any, fieldErrors []FieldError) error {
+	normalizeStrings(reflect.ValueOf(dst).Elem())
+
+	if err := binding.Validator.ValidateStruct(dst); err != nil {
+		var validationErrs validator.ValidationErrors
+		if !errors.As(err, &validationErrs) {
+			return err
+		}
+		for _, fe := range validationErrs {
+			if hasFieldError(fieldErrors, fe.Field()) {
+				// The value could not be decoded, so its rules are meaningless
+				continue
+			}
+			fieldErrors = append(fieldErrors, FieldError{
+				Field:   fe.Field(),
+				Rule:    fe.Tag(),
+				Message: ruleMessage(fe),
+			})
+		}
+	}
+
+	if len(fieldErrors) > 0 {
+		return &ValidationError{Fields: fieldErrors}
+	}
+	return nil
+}
+
+// normalizeStrings converts every string and *string field of v to Unicode NFC
+func normalizeStrings(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if field.Kind() == reflect.Pointer && !field.IsNil() {
+			field = field.Elem()
+		}
+		if field.Kind() == reflect.String {
+			field.SetString(norm.NFC.String(field.String()))
+		}
+	}
+}
+
+// hasFieldError reports whether fieldErrors already contains an error for field
+func hasFieldError(fieldErrors []FieldError, field string) bool {
+	for _, fe := range fieldErrors {
+		if fe.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonTypeName returns the JSON type expected for values of t
+func jsonTypeName(t reflect.Type) string {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// ruleMessage describes a violated validation rule
+func ruleMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "notblank":
+		return "must not be blank"
+	case "min":
+		if fe.Kind() == reflect.Int {
+			return fmt.Sprintf("must be at least %s", fe.Param())
+		}
+		return fmt.Sprintf("must be at least %s characters", fe.Param())
+	case "max":
+		if fe.Kind() == reflect.Int {
+			return fmt.Sprintf("must be at most %s", fe.Param())
+		}
+		return fmt.Sprintf("must be at most %s characters", fe.Param())
+	case "uuid":
+		return "must be a UUID"
+	case "len=0|uuid":
+		return "must be a UUID, or empty to clear it"
+	case "timestamp":
+		return "must be an RFC 3339 timestamp"
+	case "len=0|timestamp":
+		return "must be an RFC 3339 timestamp, or empty to clear it"
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", strings.ReplaceAll(fe.Param(), " ", ", "))
+	default:
+		return fmt.Sprintf("failed the %s rule", fe.Tag())
+	}
+}
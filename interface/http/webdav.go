@@ -0,0 +1,216 @@
+package http
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	davNamespace    = "DAV:"
+	calDAVNamespace = "urn:ietf:params:xml:ns:caldav"
+	// calendarServerNamespace holds getctag, which older Apple clients poll instead of the sync token
+	calendarServerNamespace = "http://calendarserver.org/ns/"
+	// davContentType is the content type of WebDAV XML bodies
+	davContentType = "application/xml; charset=utf-8"
+)
+
+// davPrefixes are the namespace prefixes declared by the XML bodies the server writes
+var davPrefixes = map[string]string{
+	davNamespace:            "D",
+	calDAVNamespace:         "C",
+	calendarServerNamespace: "CS",
+}
+
+// errMalformedDAVBody is returned when the body of a WebDAV request is not the expected XML document
+var errMalformedDAVBody = errors.New("malformed WebDAV request body")
+
+// davName returns the name of an element of the DAV: namespace
+func davName(local string) xml.Name {
+	return xml.Name{Space: davNamespace, Local: local}
+}
+
+// calDAVName returns the name of an element of the CalDAV namespace
+func calDAVName(local string) xml.Name {
+	return xml.Name{Space: calDAVNamespace, Local: local}
+}
+
+// davPropfind is the body of a PROPFIND request, whose properties REPORT requests also select
+type davPropfind struct {
+	AllProp  *struct{}   `xml:"DAV: allprop"`
+	PropName *struct{}   `xml:"DAV: propname"`
+	Prop     davPropList `xml:"DAV: prop"`
+}
+
+// davPropList lists the names of the properties of a DAV:prop element
+type davPropList struct {
+	Names []struct {
+		XMLName xml.Name
+	} `xml:",any"`
+}
+
+// davProperty is a property of a resource and its value, as an XML fragment
+type davProperty struct {
+	name  xml.Name
+	value string
+}
+
+// davResource is a resource of the WebDAV tree with its properties
+type davResource struct {
+	href  string
+	props []davProperty
+	// requestedOnly lists the properties left out of DAV:allprop, as they are expensive or verbose
+	requestedOnly map[xml.Name]bool
+}
+
+// find returns the value of the property name
+func (r davResource) find(name xml.Name) (string, bool) {
+	for _, prop := range r.props {
+		if prop.name == name {
+			return prop.value, true
+		}
+	}
+	return "", false
+}
+
+// readDAVBody decodes the XML body of a request into v, leaving it unchanged when the body is empty.
+// It reports whether there was a body.
+func readDAVBody(c *gin.Context, v any) (bool, error) {
+	body, err := readBody(c, maxRequestBodyBytes)
+	if err != nil {
+		return false, err
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return false, nil
+	}
+	if err := xml.Unmarshal(body, v); err != nil {
+		return true, fmt.Errorf("%w: %v", errMalformedDAVBody, err)
+	}
+	return true, nil
+}
+
+// davMultistatus builds a DAV:multistatus response body (RFC 4918, section 13)
+type davMultistatus struct {
+	b bytes.Buffer
+}
+
+func newDAVMultistatus() *davMultistatus {
+	m := &davMultistatus{}
+	m.b.WriteString(xml.Header)
+	m.b.WriteString(`<D:multistatus xmlns:D="DAV:" xmlns:C="` + calDAVNamespace + `" xmlns:CS="` + calendarServerNamespace + `">`)
+	return m
+}
+
+// add writes the response of resource with the properties selected by find. Requested
+// properties the resource does not have are reported as not found.
+func (m *davMultistatus) add(resource davResource, find davPropfind) {
+	var found, missing bytes.Buffer
+	switch {
+	case find.PropName != nil:
+		for _, prop := range resource.props {
+			found.WriteString(davElement(prop.name, ""))
+		}
+	case find.AllProp != nil || len(find.Prop.Names) == 0:
+		for _, prop := range resource.props {
+			if !resource.requestedOnly[prop.name] {
+				found.WriteString(davElement(prop.name, prop.value))
+			}
+		}
+	default:
+		for _, requested := range find.Prop.Names {
+			if value, ok := resource.find(requested.XMLName); ok {
+				found.WriteString(davElement(requested.XMLName, value))
+			} else {
+				missing.WriteString(davElement(requested.XMLName, ""))
+			}
+		}
+	}
+
+	m.b.WriteString("<D:response><D:href>" + escapeXML(resource.href) + "</D:href>")
+	m.writePropstat(found.String(), http.StatusOK)
+	m.writePropstat(missing.String(), http.StatusNotFound)
+	m.b.WriteString("</D:response>")
+}
+
+// addPropstat writes the response of href with every property of props reported with status
+func (m *davMultistatus) addPropstat(href string, props []xml.Name, status int) {
+	var b bytes.Buffer
+	for _, name := range props {
+		b.WriteString(davElement(name, ""))
+	}
+	m.b.WriteString("<D:response><D:href>" + escapeXML(href) + "</D:href>")
+	m.writePropstat(b.String(), status)
+	m.b.WriteString("</D:response>")
+}
+
+// addStatus writes the response of a resource without properties, such as one that does not exist
+func (m *davMultistatus) addStatus(href string, status int) {
+	m.b.WriteString("<D:response><D:href>" + escapeXML(href) + "</D:href>")
+	m.b.WriteString("<D:status>" + davStatus(status) + "</D:status></D:response>")
+}
+
+// writePropstat writes the properties sharing status, if any
+func (m *davMultistatus) writePropstat(props string, status int) {
+	if props == "" {
+		return
+	}
+	m.b.WriteString("<D:propstat><D:prop>" + props + "</D:prop>")
+	m.b.WriteString("<D:status>" + davStatus(status) + "</D:status></D:propstat>")
+}
+
+// write responds with the multistatus, ended by syncToken when it is not empty
+func (m *davMultistatus) write(c *gin.Context, syncToken string) {
+	if syncToken != "" {
+		m.b.WriteString("<D:sync-token>" + escapeXML(syncToken) + "</D:sync-token>")
+	}
+	m.b.WriteString("</D:multistatus>")
+	c.Data(http.StatusMultiStatus, davContentType, m.b.Bytes())
+}
+
+// abortDAVError responds with a DAV:error body naming the precondition that failed (RFC 4918, section 16),
+// which clients act upon, such as syncing again from scratch on DAV:valid-sync-token
+func abortDAVError(c *gin.Context, status int, condition xml.Name) {
+	logger.FromContext(c.Request.Context()).Warn("WebDAV precondition failed",
+		zap.String("condition", condition.Local),
+	)
+	body := xml.Header + `<D:error xmlns:D="DAV:" xmlns:C="` + calDAVNamespace + `">` + davElement(condition, "") + "</D:error>"
+	c.Data(status, davContentType, []byte(body))
+	c.Abort()
+}
+
+// davElement writes the element name holding the XML fragment inner
+func davElement(name xml.Name, inner string) string {
+	tag, declaration := name.Local, ""
+	if prefix, ok := davPrefixes[name.Space]; ok {
+		tag = prefix + ":" + name.Local
+	} else if name.Space != "" {
+		declaration = ` xmlns="` + escapeXML(name.Space) + `"`
+	}
+	if inner == "" {
+		return "<" + tag + declaration + "/>"
+	}
+	return "<" + tag + declaration + ">" + inner + "</" + tag + ">"
+}
+
+// davHref writes a DAV:href element
+func davHref(href string) string {
+	return "<D:href>" + escapeXML(href) + "</D:href>"
+}
+
+// davStatus returns the status line of a propstat or response
+func davStatus(status int) string {
+	return fmt.Sprintf("HTTP/1.1 %d %s", status, http.StatusText(status))
+}
+
+// escapeXML escapes s for character data and attribute values
+func escapeXML(s string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,124 @@
+package http
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+)
+
+// xlsxStaticParts are the parts of the workbook other than its single worksheet
+var xlsxStaticParts = []struct{ name, content string }{
+	{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Todos" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+// xlsxEncoder writes todos as a single-sheet XLSX workbook. Cells are written as inline
+// strings, so the rows can be streamed without collecting a shared string table first.
+type xlsxEncoder struct {
+	zw    *zip.Writer
+	sheet io.Writer
+	row   int
+}
+
+func newXLSXEncoder(w io.Writer) exportEncoder {
+	return &xlsxEncoder{zw: zip.NewWriter(w)}
+}
+
+func (e *xlsxEncoder) Encode(todos []*entity.Todo) error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	for _, todo := range todos {
+		if err := e.writeRow(exportRow(todo)); err != nil {
+			return err
+		}
+	}
+	return e.zw.Flush()
+}
+
+func (e *xlsxEncoder) Close() error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(e.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	return e.zw.Close()
+}
+
+// start writes the static parts and opens the worksheet with its header row, unless already done
+func (e *xlsxEncoder) start() error {
+	if e.sheet != nil {
+		return nil
+	}
+	for _, part := range xlsxStaticParts {
+		w, err := e.zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, part.content); err != nil {
+			return err
+		}
+	}
+
+	sheet, err := e.zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	e.sheet = sheet
+	if _, err := io.WriteString(e.sheet, xml.Header+
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`); err != nil {
+		return err
+	}
+	return e.writeRow(exportColumns)
+}
+
+// writeRow appends a row to the worksheet. The completed column is written as a boolean
+// cell once past the header row, the others as text.
+func (e *xlsxEncoder) writeRow(cells []string) error {
+	e.row++
+	var b strings.Builder
+	fmt.Fprintf(&b, `<row r="%d">`, e.row)
+	for i, cell := range cells {
+		ref := xlsxColumn(i) + strconv.Itoa(e.row)
+		if e.row > 1 && exportColumns[i] == "completed" {
+			value := "0"
+			if cell == "true" {
+				value = "1"
+			}
+			fmt.Fprintf(&b, `<c r="%s" t="b"><v>%s</v></c>`, ref, value)
+			continue
+		}
+		fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+		if err := xml.EscapeText(&b, []byte(cell)); err != nil {
+			return err
+		}
+		b.WriteString(`</t></is></c>`)
+	}
+	b.WriteString(`</row>`)
+	_, err := io.WriteString(e.sheet, b.String())
+	return err
+}
+
+// xlsxColumn returns the letter of the zero-based column i; exports have fewer than 26 columns
+func xlsxColumn(i int) string {
+	return string(rune('A' + i))
+}
@@ -2,8 +2,35 @@
 
 awslocal dynamodb create-table \
     --table-name goto-dev-todo \
-    --attribute-definitions AttributeName=id,AttributeType=S \
-    --key-schema AttributeName=id,KeyType=HASH \
+    --attribute-definitions AttributeName=tenant_id,AttributeType=S AttributeName=id,AttributeType=S AttributeName=parent_id,AttributeType=S \
+    --key-schema AttributeName=tenant_id,KeyType=HASH AttributeName=id,KeyType=RANGE \
+    --global-secondary-indexes '[{"IndexName":"parent_id-index","KeySchema":[{"AttributeName":"tenant_id","KeyType":"HASH"},{"AttributeName":"parent_id","KeyType":"RANGE"}],"Projection":{"ProjectionType":"ALL"},"ProvisionedThroughput":{"ReadCapacityUnits":1,"WriteCapacityUnits":1}}]' \
     --provisioned-throughput ReadCapacityUnits=1,WriteCapacityUnits=1
 
-awslocal dynamodb list-tables
\ No newline at end of file
+awslocal dynamodb create-table \
+    --table-name goto-dev-todo-constraints \
+    --attribute-definitions AttributeName=tenant_id,AttributeType=S AttributeName=constraint_key,AttributeType=S \
+    --key-schema AttributeName=tenant_id,KeyType=HASH AttributeName=constraint_key,KeyType=RANGE \
+    --provisioned-throughput ReadCapacityUnits=1,WriteCapacityUnits=1
+
+awslocal dynamodb create-table \
+    --table-name goto-dev-todo-idempotency \
+    --attribute-definitions AttributeName=tenant_id,AttributeType=S AttributeName=idempotency_key,AttributeType=S \
+    --key-schema AttributeName=tenant_id,KeyType=HASH AttributeName=idempotency_key,KeyType=RANGE \
+    --provisioned-throughput ReadCapacityUnits=1,WriteCapacityUnits=1
+
+awslocal dynamodb update-time-to-live \
+    --table-name goto-dev-todo-idempotency \
+    --time-to-live-specification Enabled=true,AttributeName=expires_at
+
+awslocal dynamodb create-table \
+    --table-name goto-dev-todo-imports \
+    --attribute-definitions AttributeName=tenant_id,AttributeType=S AttributeName=import_id,AttributeType=S \
+    --key-schema AttributeName=tenant_id,KeyType=HASH AttributeName=import_id,KeyType=RANGE \
+    --provisioned-throughput ReadCapacityUnits=1,WriteCapacityUnits=1
+
+awslocal dynamodb update-time-to-live \
+    --table-name goto-dev-todo-imports \
+    --time-to-live-specification Enabled=true,AttributeName=expires_at
+
+awslocal dynamodb list-tables
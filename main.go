@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
@@ -9,13 +11,20 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/tenant"
 	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/config"
 	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/dynamodb"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/eventbus"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/feature"
 	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/health"
 	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/logger"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/metrics"
 	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/middleware"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/problem"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/server"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/subscription"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/infrastructure/tracing"
 	todohttp "github.com/gotokazuki/todo-golang-rest-api/app/todo/interface/http"
 	"github.com/gotokazuki/todo-golang-rest-api/app/todo/usecase/todo"
 	"go.uber.org/zap"
@@ -24,79 +33,296 @@ import (
 // main is the entry point of the application.
 // It initializes the repository, handler, and sets up the Gin router with middleware and routes.
 func main() {
+	// The image has no shell or HTTP client, so container health checks run the binary itself
+	args := os.Args[1:]
+	healthcheck := len(args) > 0 && args[0] == "healthcheck"
+	if healthcheck {
+		args = args[1:]
+	}
+
+	// Load configuration
+	loader := config.NewLoader(args)
+	cfg, err := loader.Load()
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+	if loader.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if healthcheck {
+		if err := server.Probe(cfg.Server, "/readyz"); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Initialize logger
-	log := logger.NewLogger()
+	logLevel := zap.NewAtomicLevelAt(logger.ParseLevel(cfg.Logging.Level))
+	log := logger.NewLogger(logLevel)
 	defer log.Sync()
+	zap.ReplaceGlobals(log)
+
+	// Initialize tracing
+	tracerProvider, err := tracing.NewTracerProvider(context.Background())
+	if err != nil {
+		log.Fatal("Failed to initialize tracing", zap.Error(err))
+	}
 
-	cfg, err := config.LoadConfig()
+	// Initialize metrics
+	appMetrics := metrics.NewMetrics()
 
 	// Initialize repository
-	repo := dynamodb.NewTodoRepository(cfg)
+	repo := dynamodb.NewTodoRepository(cfg, appMetrics)
+
+	// Initialize event bus
+	eventBus := eventbus.NewBus(cfg.Events.ReplayBuffer, cfg.Events.ReplayTTL)
 
 	// Initialize use case
-	useCase := todo.NewTodoUseCase(repo)
+	useCase := todo.NewTodoUseCase(repo, func(tenantID tenant.ID) int {
+		return cfg.Tenant.MaxTodosFor(string(tenantID))
+	}, eventBus, logger.FromContext)
 
-	// Initialize handler
-	handler := todohttp.NewTodoHandler(useCase, log)
+	// Initialize handlers
+	handler := todohttp.NewTodoHandler(useCase)
+	importUseCase := todo.NewImportUseCase(useCase, dynamodb.NewImportJobRepository(repo.GetClient(), cfg), cfg.Import.TTL)
+	importHandler := todohttp.NewImportHandler(importUseCase, cfg.Import.SyncRows)
+	subscriptionTokens := subscription.NewTokens(cfg.Calendar.TokenSecret, map[subscription.Scope]time.Duration{
+		subscription.ScopeFeed:   cfg.Calendar.TokenTTL,
+		subscription.ScopeCalDAV: cfg.Calendar.CalDAVTokenTTL,
+	})
+	subscriptionHandler := todohttp.NewSubscriptionHandler(subscriptionTokens, cfg.Calendar.BaseURL)
+	calDAVHandler := todohttp.NewCalDAVHandler(useCase)
+	eventHandler := todohttp.NewEventHandler(eventBus, cfg.Events.HeartbeatInterval)
 
 	// Initialize health checker
-	healthChecker := health.NewDynamoDBHealthChecker(repo.GetClient(), repo.GetTableName(), cfg, log)
+	checkTimeout := cfg.DynamoDB.Timeout
+	healthRegistry := health.NewRegistry(log)
+	healthRegistry.Register("dynamodb", health.Critical, checkTimeout,
+		health.DynamoDBTableCheck(repo.GetClient(), repo.GetTableName()))
+	// Only requests with an Idempotency-Key depend on the idempotency table
+	healthRegistry.Register("idempotency", health.NonCritical, checkTimeout,
+		health.DynamoDBTableCheck(repo.GetClient(), cfg.Idempotency.TableName))
+	// Reads do not depend on the constraint table, only writes creating or deleting todos
+	healthRegistry.Register("constraints", health.NonCritical, checkTimeout,
+		health.DynamoDBTableCheck(repo.GetClient(), cfg.DynamoDB.ConstraintTableName))
+	// Only import jobs depend on the import table
+	healthRegistry.Register("imports", health.NonCritical, checkTimeout,
+		health.DynamoDBTableCheck(repo.GetClient(), cfg.Import.TableName))
+	probes := health.NewProbes(healthRegistry, cfg.Health.CacheTTL, cfg.Health.FailureThreshold, log)
+
+	// Initialize idempotency repository
+	idempotencyRepo := dynamodb.NewIdempotencyRepository(repo.GetClient(), cfg)
+
+	// Refresh the todo gauges in the background until shutdown
+	metricsCtx, stopMetrics := context.WithCancel(context.Background())
+	defer stopMetrics()
+	statsRepo := dynamodb.NewTodoStatisticsRepository(repo.GetClient(), cfg)
+	go appMetrics.RunTodoGauges(metricsCtx, statsRepo, cfg.Metrics.TodoRefreshInterval, log)
+
+	// Initialize rate limiter
+	limiter := middleware.NewRateLimiter(cfg.RateLimit)
+
+	// Initialize feature flags
+	features := feature.NewFlags(cfg.Features)
 
 	// Create Gin router without default middleware
 	r := gin.New()
+	// Client IPs are only read from X-Forwarded-For when set by a trusted proxy
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatal("Failed to set trusted proxies", zap.Error(err))
+	}
 
 	// Add middleware
-	r.Use(gin.Recovery())
-	r.Use(middleware.LoggingMiddleware(log))
-	r.Use(middleware.ErrorHandlerMiddleware(log))
+	r.Use(middleware.TracingMiddleware(tracing.ServiceName, "/health", "/livez", "/readyz", "/startupz", "/metrics"))
+	r.Use(middleware.RequestIDMiddleware())
+	r.Use(middleware.MetricsMiddleware(appMetrics))
+	r.Use(middleware.RequestLoggerMiddleware(log, cfg.Logging))
+	r.Use(gin.CustomRecovery(func(c *gin.Context, recovered any) {
+		logger.FromContext(c.Request.Context()).Error("Panic recovered", zap.Any("panic", recovered))
+		problem.Abort(c, problem.New(http.StatusInternalServerError, problem.CodeInternal, "An unexpected error occurred"))
+	}))
+	r.Use(middleware.LoggingMiddleware(logger.NewRedactor(
+		cfg.Logging.RedactHeaders,
+		cfg.Logging.RedactQueryParams,
+		cfg.Logging.RedactBodyFields,
+	)))
+	r.Use(middleware.ErrorHandlerMiddleware())
+	r.Use(middleware.BodyLimitMiddleware(cfg.Server.MaxBodyBytes))
 
 	// Configure CORS middleware
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
+	cors := middleware.NewCORS(cfg.CORS, cfg.Tenant.Header, cfg.RateLimit.APIKeyHeader)
+	r.Use(cors.Middleware())
+
+	// Apply the reloadable settings whenever the configuration is reloaded
+	reloader := config.NewReloader(loader, cfg, log)
+	reloader.Subscribe(func(cfg *config.Config) {
+		logLevel.SetLevel(logger.ParseLevel(cfg.Logging.Level))
+		limiter.SetConfig(cfg.RateLimit)
+		cors.Update(cfg.CORS)
+		features.Set(cfg.Features)
+	})
 
 	// Setup routes
-	r.GET("/health", func(c *gin.Context) {
-		response := healthChecker.Check(c.Request.Context())
-		c.JSON(getStatusCode(response.Status), response)
+	r.GET("/livez", func(c *gin.Context) {
+		c.JSON(http.StatusOK, probes.Live())
+	})
+	r.GET("/startupz", func(c *gin.Context) {
+		response, ok := probes.Started()
+		c.JSON(getStatusCode(ok), response)
+	})
+	ready := func(c *gin.Context) {
+		response, ok := probes.Ready(c.Request.Context())
+		c.JSON(getStatusCode(ok), response)
+	}
+	r.GET("/readyz", ready)
+	// Deprecated: kept for existing monitors, use /readyz
+	r.GET("/health", ready)
+	r.GET("/metrics", gin.WrapH(appMetrics.Handler()))
+
+	// Admin routes are only served when a token is configured
+	if cfg.Admin.Token != "" {
+		admin := r.Group("/admin", middleware.AdminAuthMiddleware(cfg.Admin.Token))
+		admin.POST("/reload", func(c *gin.Context) {
+			changes, err := reloader.Reload()
+			if err != nil {
+				problem.Abort(c, problem.New(http.StatusUnprocessableEntity, problem.CodeConfigInvalid, err.Error()))
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"changes": changes})
+		})
+		admin.GET("/config", func(c *gin.Context) {
+			c.Header("Content-Type", "application/yaml")
+			if err := reloader.Current().Print(c.Writer); err != nil {
+				c.Error(err)
+			}
+		})
+	}
+
+	// Todo routes are rate limited per client and scoped to the tenant resolved from the request
+	todos := r.Group("/todos",
+		middleware.RateLimitMiddleware(limiter),
+		middleware.TenantMiddleware(cfg.Tenant),
+	)
+	todos.GET("", handler.GetTodos)
+	todos.POST("", middleware.IdempotencyMiddleware(idempotencyRepo, cfg.Idempotency), handler.CreateTodo)
+	todos.GET("/export", handler.ExportTodos)
+	todos.GET("/events", eventHandler.StreamEvents)
+	todos.POST("/import", middleware.IdempotencyMiddleware(idempotencyRepo, cfg.Idempotency), importHandler.ImportTodos)
+	todos.GET("/:id", handler.GetTodo)
+	todos.PUT("/:id", handler.ReplaceTodo)
+	todos.PATCH("/:id", handler.UpdateTodo)
+	todos.DELETE("/:id", handler.DeleteTodo)
+	// Custom methods of a todo, such as POST /todos/{id}:complete
+	todos.POST("/:id", handler.TodoAction)
+
+	// Calendar clients cannot send headers, so the feed also accepts the token of a subscription URL
+	r.GET("/todos.ics",
+		middleware.RateLimitMiddleware(limiter),
+		middleware.SubscriptionMiddleware(subscriptionTokens, cfg.Tenant),
+		handler.GetCalendar,
+	)
+	// Subscription URLs are only issued when a token secret is configured
+	if subscriptionTokens.Enabled() {
+		r.POST("/calendar/subscriptions",
+			middleware.RateLimitMiddleware(limiter),
+			middleware.TenantMiddleware(cfg.Tenant),
+			subscriptionHandler.CreateSubscription,
+		)
+	}
+
+	// CalDAV clients sync todos both ways; they sign in with a CalDAV token, as feed tokens are read-only
+	r.GET("/.well-known/caldav", calDAVHandler.WellKnown)
+	r.Handle("PROPFIND", "/.well-known/caldav", calDAVHandler.WellKnown)
+	r.OPTIONS("/caldav/*path", calDAVHandler.Options)
+	caldav := r.Group("/caldav",
+		middleware.RateLimitMiddleware(limiter),
+		middleware.CalDAVMiddleware(subscriptionTokens, cfg.Tenant),
+	)
+	caldav.Handle("PROPFIND", "/", calDAVHandler.PropfindRoot)
+	caldav.Handle("PROPFIND", "/todos/", calDAVHandler.PropfindCollection)
+	caldav.Handle("PROPPATCH", "/todos/", calDAVHandler.ProppatchCollection)
+	caldav.Handle("REPORT", "/todos/", calDAVHandler.Report)
+	caldav.Handle("PROPFIND", "/todos/:name", calDAVHandler.PropfindTodo)
+	caldav.GET("/todos/:name", calDAVHandler.GetTodo)
+	caldav.PUT("/todos/:name", calDAVHandler.PutTodo)
+	caldav.DELETE("/todos/:name", calDAVHandler.DeleteTodo)
+
+	imports := r.Group("/imports",
+		middleware.RateLimitMiddleware(limiter),
+		middleware.TenantMiddleware(cfg.Tenant),
+	)
+	imports.GET("/:id", importHandler.GetImport)
+
+	// Custom methods of the todo collection, such as POST /todos:batch
+	actions := r.Group("/:action",
+		middleware.ActionMiddleware("todos:batch", "todos:move"),
+		middleware.FeatureMiddleware(features, "batch", "todos:batch"),
+		middleware.RateLimitMiddleware(limiter),
+		middleware.TenantMiddleware(cfg.Tenant),
+	)
+	actions.POST("", middleware.IdempotencyMiddleware(idempotencyRepo, cfg.Idempotency), handler.CollectionAction)
+
+	r.NoRoute(func(c *gin.Context) {
+		problem.Abort(c, problem.New(http.StatusNotFound, problem.CodeRouteNotFound, "No route matches the request"))
 	})
-	r.GET("/todos", handler.GetTodos)
-	r.POST("/todos", handler.CreateTodo)
-	r.GET("/todos/:id", handler.GetTodo)
-	r.PATCH("/todos/:id", handler.UpdateTodo)
-	r.DELETE("/todos/:id", handler.DeleteTodo)
 
 	// Create HTTP server
-	srv := &http.Server{
-		Addr:    ":8080",
-		Handler: r,
+	srv, err := server.New(cfg.Server, r, log)
+	if err != nil {
+		log.Fatal("Failed to create server", zap.Error(err))
+	}
+
+	// Bind before reporting started so the startup probe never passes ahead of the listener
+	listener, err := srv.Listen()
+	if err != nil {
+		log.Fatal("Failed to start server", zap.Error(err))
 	}
 
 	// Start server in a goroutine
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := srv.Serve(listener); err != nil {
 			log.Fatal("Failed to start server", zap.Error(err))
 		}
 	}()
+	probes.MarkStarted()
+	log.Info("Server started", zap.String("address", srv.Address()), zap.Bool("tls", cfg.Server.TLS.Enabled()))
+
+	// Reload the configuration on SIGHUP
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		for range hangup {
+			log.Info("Reloading configuration")
+			_, _ = reloader.Reload()
+		}
+	}()
 
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Info("Shutting down server...")
+	stopMetrics()
 
-	timeout, err := time.ParseDuration(cfg.ShutdownTimeout)
-	if err != nil {
-		panic(fmt.Sprintf("Invalid shutdown timeout format: %v", err))
-	}
+	// Fail readiness first and keep serving while load balancers stop routing to this instance
+	probes.Drain()
+	log.Info("Draining", zap.Duration("delay", cfg.DrainDelay))
+	time.Sleep(cfg.DrainDelay)
+
+	// End the event streams, which would otherwise hold the shutdown until its deadline
+	eventBus.Close()
 
 	// Create a deadline for server shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
 	// Attempt graceful shutdown
@@ -104,17 +330,23 @@ func main() {
 		log.Fatal("Server forced to shutdown", zap.Error(err))
 	}
 
+	// Let running import jobs finish, or record them as interrupted
+	if err := importUseCase.Shutdown(ctx); err != nil {
+		log.Warn("Interrupted running import jobs", zap.Error(err))
+	}
+
+	// Flush the spans of the last requests
+	if err := tracerProvider.Shutdown(ctx); err != nil {
+		log.Error("Failed to flush traces", zap.Error(err))
+	}
+
 	log.Info("Server exiting")
 }
 
-// getStatusCode returns the appropriate HTTP status code based on the health status
-func getStatusCode(status string) int {
-	switch status {
-	case string(health.StatusOK):
-		return 200
-	case string(health.StatusFail):
-		return 500
-	default:
-		return 500
+// getStatusCode returns the appropriate HTTP status code based on the probe result
+func getStatusCode(ok bool) int {
+	if ok {
+		return http.StatusOK
 	}
+	return http.StatusServiceUnavailable
 }
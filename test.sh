@@ -8,10 +8,77 @@ YELLOW='\033[0;33m'
 NC='\033[0m'
 
 BASE_URL=${1:-http://localhost:8080}
+TENANT_ID=${2:-test-tenant}
 
-# Test GET /health
-echo -e "${YELLOW}Checking health endpoint...${NC}"
-GET_HEALTH_RESPONSE=`curl -s -X GET "${BASE_URL}/health"`
+# Contract of components.schemas.Todo, read from docs/openapi.yaml itself
+OPENAPI_FILE="$(dirname "$0")/docs/openapi.yaml"
+TODO_SCHEMA=`python3 -c 'import json, sys, yaml; print(json.dumps(yaml.safe_load(open(sys.argv[1]))["components"]["schemas"]["Todo"]))' "${OPENAPI_FILE}"`
+TODO_LIST_SCHEMA=`jq -cn --argjson todo "${TODO_SCHEMA}" '{type: "array", items: $todo}'`
+
+# jq program listing the violations of the OpenAPI schema $schema by its input, one per line.
+# It supports type, nullable, enum, required, properties, additionalProperties, items,
+# format (uuid and date-time), pattern, minLength, maxLength, minimum and maximum.
+SCHEMA_VALIDATOR='
+def violations($schema; $path):
+  if . == null and $schema.nullable == true then empty
+  elif $schema.type != null and ((if $schema.type == "integer" then type == "number" and . == floor else type == $schema.type end) | not) then
+    "\($path): must be of type \($schema.type)\(if $schema.nullable == true then " or null" else "" end)"
+  else
+    (select($schema.enum != null and (. as $value | $schema.enum | index([$value]) | not))
+      | "\($path): must be one of \($schema.enum | map(tojson) | join(", "))"),
+    (if type == "object" then
+      (($schema.required // [])[] as $key | select(has($key) | not) | "\($path).\($key): is required"),
+      (select($schema.additionalProperties == false) | keys[] as $key
+        | select($schema.properties // {} | has($key) | not) | "\($path).\($key): is not defined by the schema"),
+      (to_entries[] | select($schema.properties[.key] != null) | .key as $key
+        | .value | violations($schema.properties[$key]; "\($path).\($key)"))
+    elif type == "array" and $schema.items != null then
+      to_entries[] | .key as $index | .value | violations($schema.items; "\($path)[\($index)]")
+    elif type == "string" then
+      (select($schema.format == "uuid" and (test("^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$") | not))
+        | "\($path): must be a UUID"),
+      (select($schema.format == "date-time" and (test("^[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}(\\.[0-9]+)?(Z|[+-][0-9]{2}:[0-9]{2})$") | not))
+        | "\($path): must be an RFC 3339 date-time"),
+      (select($schema.pattern != null and (test($schema.pattern) | not)) | "\($path): must match \($schema.pattern)"),
+      (select($schema.minLength != null and length < $schema.minLength) | "\($path): must be at least \($schema.minLength) characters long"),
+      (select($schema.maxLength != null and length > $schema.maxLength) | "\($path): must be at most \($schema.maxLength) characters long")
+    elif type == "number" then
+      (select($schema.minimum != null and . < $schema.minimum) | "\($path): must be at least \($schema.minimum)"),
+      (select($schema.maximum != null and . > $schema.maximum) | "\($path): must be at most \($schema.maximum)")
+    else empty end)
+  end;
+violations($schema; "$")
+'
+
+# schema_violations prints the violations of the schema $2 by the JSON $1, one per line
+schema_violations() {
+  echo "$1" | jq -r --argjson schema "$2" "${SCHEMA_VALIDATOR}" 2>/dev/null || echo "$: must be valid JSON"
+}
+
+# assert_todo_schema fails when the given JSON is not a valid Todo
+assert_todo_schema() {
+  local violations=`schema_violations "$1" "${TODO_SCHEMA}"`
+  if [[ -n "${violations}" ]]; then
+    echo -e "${RED}Response does not match the Todo schema!${NC}"
+    echo "${violations}"
+    exit 1
+  fi
+}
+
+# Test GET /livez
+echo -e "${YELLOW}Checking liveness probe...${NC}"
+LIVEZ_HTTP_CODE=`curl -s -o /dev/null -w "%{http_code}" -X GET "${BASE_URL}/livez"`
+
+if [[ ${LIVEZ_HTTP_CODE} -eq 200 ]]; then
+  echo -e "${GREEN}Liveness probe passed!${NC}"
+else
+  echo -e "${RED}Liveness probe failed!${NC}"
+  exit 1
+fi
+
+# Test GET /readyz
+echo -e "${YELLOW}Checking readiness probe...${NC}"
+GET_HEALTH_RESPONSE=`curl -s -X GET "${BASE_URL}/readyz"`
 
 sleep 1
 
@@ -29,6 +96,7 @@ fi
 # Test POST /todos
 echo -e "${YELLOW}Creating a TODO item...${NC}"
 POST_TODO=`curl -i -s -X POST "${BASE_URL}/todos" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
   -H "Content-Type: application/json" \
   -d '{"title": "Sample Todo", "description": "This is a test todo"}'`
 
@@ -38,19 +106,126 @@ LOCATION_HEADER_VALUE=$(echo "${POST_TODO}" | grep -i location | cut -d ' ' -f2
 
 echo -e "${GREEN}TODO item created successfully! The created item location: ${LOCATION_HEADER_VALUE}${NC}"
 
+# Test request validation
+echo -e "${YELLOW}Creating an invalid TODO item...${NC}"
+INVALID_TODO=`curl -s -w "\n%{http_code}" -X POST "${BASE_URL}/todos" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  -H "Content-Type: application/json" \
+  -d '{"title": " ", "completed": "yes", "due": "tomorrow"}'`
+
+sleep 1
+
+INVALID_HTTP_CODE=`echo "${INVALID_TODO}" | tail -n 1`
+INVALID_FIELDS=`echo "${INVALID_TODO}" | head -n 1 | jq -c '[.errors[].field]'`
+
+if [[ ${INVALID_HTTP_CODE} -eq 422 && ${INVALID_FIELDS} == '["completed","due","title"]' ]]; then
+  echo -e "${GREEN}Invalid TODO item rejected with field details!${NC}"
+else
+  echo -e "${RED}Invalid TODO item was not rejected as expected!${NC}"
+  exit 1
+fi
+
+# Test POST /todos with an Idempotency-Key
+echo -e "${YELLOW}Creating a TODO item with an Idempotency-Key...${NC}"
+IDEMPOTENCY_KEY=`cat /proc/sys/kernel/random/uuid`
+IDEMPOTENT_HEADERS=`mktemp`
+IDEMPOTENT_REPLAY_HEADERS=`mktemp`
+IDEMPOTENT=`curl -s -D "${IDEMPOTENT_HEADERS}" -w "\n%{http_code}" -X POST "${BASE_URL}/todos" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  -H "Content-Type: application/json" \
+  -H "Idempotency-Key: ${IDEMPOTENCY_KEY}" \
+  -d '{"title": "Idempotent Todo"}'`
+IDEMPOTENT_REPLAY=`curl -s -D "${IDEMPOTENT_REPLAY_HEADERS}" -w "\n%{http_code}" -X POST "${BASE_URL}/todos" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  -H "Content-Type: application/json" \
+  -H "Idempotency-Key: ${IDEMPOTENCY_KEY}" \
+  -d '{"title": "Idempotent Todo"}'`
+IDEMPOTENT_REUSED=`curl -s -w "\n%{http_code}" -X POST "${BASE_URL}/todos" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  -H "Content-Type: application/json" \
+  -H "Idempotency-Key: ${IDEMPOTENCY_KEY}" \
+  -d '{"title": "Another Todo"}'`
+IDEMPOTENT_LOCATION=`tr -d '\r' < "${IDEMPOTENT_HEADERS}" | sed -n 's/^[Ll]ocation: //p'`
+IDEMPOTENT_REPLAY_LOCATION=`tr -d '\r' < "${IDEMPOTENT_REPLAY_HEADERS}" | sed -n 's/^[Ll]ocation: //p'`
+
+if [[ `echo "${IDEMPOTENT}" | tail -n 1` -eq 201 && `echo "${IDEMPOTENT_REPLAY}" | tail -n 1` -eq 201 ]] \
+  && [[ -n "${IDEMPOTENT_LOCATION}" && "${IDEMPOTENT_REPLAY_LOCATION}" == "${IDEMPOTENT_LOCATION}" ]] \
+  && [[ `echo "${IDEMPOTENT}" | head -n -1` == `echo "${IDEMPOTENT_REPLAY}" | head -n -1` ]] \
+  && grep -qi '^idempotent-replayed: true' "${IDEMPOTENT_REPLAY_HEADERS}" \
+  && ! grep -qi '^idempotent-replayed' "${IDEMPOTENT_HEADERS}" \
+  && [[ `echo "${IDEMPOTENT_REUSED}" | tail -n 1` -eq 422 ]] \
+  && [[ `echo "${IDEMPOTENT_REUSED}" | head -n 1 | jq -r '.code'` == "idempotency-key-reused" ]]; then
+  echo -e "${GREEN}Idempotent response replayed and reused key rejected!${NC}"
+else
+  echo -e "${RED}Failed to replay the idempotent response!${NC}"
+  exit 1
+fi
+rm -f "${IDEMPOTENT_HEADERS}" "${IDEMPOTENT_REPLAY_HEADERS}"
+curl -s -o /dev/null -X DELETE "${BASE_URL}${IDEMPOTENT_LOCATION}" \
+  -H "X-Tenant-ID: ${TENANT_ID}"
+
+# Test concurrent POST /todos with the same Idempotency-Key
+echo -e "${YELLOW}Creating a TODO item concurrently with the same Idempotency-Key...${NC}"
+IDEMPOTENCY_KEY=`cat /proc/sys/kernel/random/uuid`
+IN_FLIGHT_DIR=`mktemp -d`
+for i in 1 2 3 4 5; do
+  curl -s -D "${IN_FLIGHT_DIR}/${i}.headers" -o "${IN_FLIGHT_DIR}/${i}.body" -X POST "${BASE_URL}/todos" \
+    -H "X-Tenant-ID: ${TENANT_ID}" \
+    -H "Content-Type: application/json" \
+    -H "Idempotency-Key: ${IDEMPOTENCY_KEY}" \
+    -d '{"title": "Idempotent Todo"}' &
+done
+wait
+IN_FLIGHT_CREATED=`cat "${IN_FLIGHT_DIR}"/*.headers | tr -d '\r' | grep -c '^HTTP/[0-9.]* 201' || true`
+IN_FLIGHT_LOCATIONS=`cat "${IN_FLIGHT_DIR}"/*.headers | tr -d '\r' | sed -n 's/^[Ll]ocation: //p' | sort -u`
+IN_FLIGHT_CONFLICTS=`cat "${IN_FLIGHT_DIR}"/*.body | jq -r '.code? // empty' | grep -c '^idempotency-key-in-progress$' || true`
+
+if [[ $((IN_FLIGHT_CREATED + IN_FLIGHT_CONFLICTS)) -eq 5 && ${IN_FLIGHT_CONFLICTS} -ge 1 ]] \
+  && [[ `echo "${IN_FLIGHT_LOCATIONS}" | wc -l` -eq 1 ]] \
+  && [[ `cat "${IN_FLIGHT_DIR}"/*.headers | grep -ci '^retry-after:'` -eq ${IN_FLIGHT_CONFLICTS} ]]; then
+  echo -e "${GREEN}Requests in flight with the same Idempotency-Key rejected with 409!${NC}"
+else
+  echo -e "${RED}Requests in flight with the same Idempotency-Key were not rejected!${NC}"
+  exit 1
+fi
+curl -s -o /dev/null -X DELETE "${BASE_URL}${IN_FLIGHT_LOCATIONS}" \
+  -H "X-Tenant-ID: ${TENANT_ID}"
+rm -rf "${IN_FLIGHT_DIR}"
+
+# Test tenant isolation
+echo -e "${YELLOW}Fetching the TODO item as another tenant...${NC}"
+OTHER_TENANT_HTTP_CODE=`curl -s -o /dev/null -w "%{http_code}" -X GET "${BASE_URL}${LOCATION_HEADER_VALUE}" \
+  -H "X-Tenant-ID: ${TENANT_ID}-other"`
+
+if [[ ${OTHER_TENANT_HTTP_CODE} -eq 404 ]]; then
+  echo -e "${GREEN}TODO item is isolated from other tenants!${NC}"
+else
+  echo -e "${RED}TODO item is visible to another tenant!${NC}"
+  exit 1
+fi
+
 # Test GET /todos
 echo -e "${YELLOW}Fetch all TODO items...${NC}"
-GET_TODOS=`curl -s -X GET "${BASE_URL}/todos"`
+GET_TODOS=`curl -s -X GET "${BASE_URL}/todos" \
+  -H "X-Tenant-ID: ${TENANT_ID}"`
 
 sleep 1
 
 echo ${GET_TODOS} | jq -c .
 
+if [[ -z `schema_violations "${GET_TODOS}" "${TODO_LIST_SCHEMA}"` ]]; then
+  echo -e "${GREEN}TODO list matches the schema!${NC}"
+else
+  echo -e "${RED}TODO list does not match the schema!${NC}"
+  exit 1
+fi
+
 # Test PATCH /todos/:id
 echo -e "${YELLOW}Updating a TODO item...${NC}"
 PATCH_HTTP_CODE=`curl -s -w "%{http_code}" -X PATCH "${BASE_URL}${LOCATION_HEADER_VALUE}" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
   -H "Content-Type: application/json" \
-  -d '{"title": "Updated Todo", "description": "This is an updated test todo","completed":true}'`
+  -d '{"title": "Updated Todo", "description": "This is an updated test todo","completed":true, "due": "2030-01-31T09:00:00Z", "priority": 1}'`
 
 sleep 1
 
@@ -64,15 +239,79 @@ fi
 
 # Test GET /todos/:id
 echo -e "${YELLOW}Fetching an updated TODO item...${NC}"
-GET_TODO=`curl -s -X GET "${BASE_URL}${LOCATION_HEADER_VALUE}"`
+GET_TODO=`curl -s -X GET "${BASE_URL}${LOCATION_HEADER_VALUE}" \
+  -H "X-Tenant-ID: ${TENANT_ID}"`
 
 sleep 1
 
 echo ${GET_TODO} | jq -c .
 
+assert_todo_schema "${GET_TODO}"
+echo -e "${GREEN}TODO item matches the schema!${NC}"
+
+if [[ `echo "${GET_TODO}" | jq -c '[.due, .priority]'` != '["2030-01-31T09:00:00.000Z",1]' ]]; then
+  echo -e "${RED}TODO item due date and priority were not updated!${NC}"
+  exit 1
+fi
+
+# Test GET /todos.ics
+echo -e "${YELLOW}Fetching the calendar feed...${NC}"
+TODO_ID=`basename "${LOCATION_HEADER_VALUE}"`
+CALENDAR=`curl -s -X GET "${BASE_URL}/todos.ics" \
+  -H "X-Tenant-ID: ${TENANT_ID}" | tr -d '\r'`
+VTODO=`echo "${CALENDAR}" | sed -n "/^UID:${TODO_ID}$/,/^END:VTODO$/p"`
+
+if [[ `echo "${CALENDAR}" | head -1` == "BEGIN:VCALENDAR" ]] \
+  && echo "${VTODO}" | grep -q '^DUE:20300131T090000Z$' \
+  && echo "${VTODO}" | grep -q '^PRIORITY:1$' \
+  && echo "${VTODO}" | grep -q '^STATUS:COMPLETED$'; then
+  echo -e "${GREEN}Calendar feed fetched successfully!${NC}"
+else
+  echo -e "${RED}Failed to fetch the calendar feed!${NC}"
+  exit 1
+fi
+
+# Test POST /calendar/subscriptions, only served when a token secret is configured
+echo -e "${YELLOW}Subscribing to the calendar feed...${NC}"
+SUBSCRIPTION=`curl -s -w "\n%{http_code}" -X POST "${BASE_URL}/calendar/subscriptions?completed=true" \
+  -H "X-Tenant-ID: ${TENANT_ID}"`
+
+if [[ `echo "${SUBSCRIPTION}" | tail -n 1` -eq 404 ]]; then
+  echo -e "${YELLOW}Calendar subscriptions are disabled, skipped${NC}"
+else
+  SUBSCRIPTION_URL=`echo "${SUBSCRIPTION}" | head -n 1 | jq -r '.url'`
+  SUBSCRIBED_CALENDAR=`curl -s -X GET "${BASE_URL}/todos.ics?${SUBSCRIPTION_URL#*\?}" | tr -d '\r'`
+  FORGED_HTTP_CODE=`curl -s -o /dev/null -w "%{http_code}" -X GET "${BASE_URL}/todos.ics?token=${TENANT_ID}.0.forged"`
+
+  if echo "${SUBSCRIBED_CALENDAR}" | grep -q "^UID:${TODO_ID}$" && [[ ${FORGED_HTTP_CODE} -eq 401 ]]; then
+    echo -e "${GREEN}Calendar feed subscribed successfully!${NC}"
+  else
+    echo -e "${RED}Failed to subscribe to the calendar feed!${NC}"
+    exit 1
+  fi
+
+  # The read-only token of the feed URL must not unlock CalDAV, nor the CalDAV password the feed
+  echo -e "${YELLOW}Signing in to CalDAV with the feed token...${NC}"
+  FEED_TOKEN=`echo "${SUBSCRIPTION_URL}" | sed 's/.*[?&]token=\([^&]*\).*/\1/'`
+  CALDAV_PASSWORD=`echo "${SUBSCRIPTION}" | head -n 1 | jq -r '.caldav.password'`
+  FEED_TOKEN_HTTP_CODE=`curl -s -o /dev/null -w "%{http_code}" -X PROPFIND "${BASE_URL}/caldav/todos/" \
+    -u "${TENANT_ID}:${FEED_TOKEN}" -H "Depth: 0"`
+  CALDAV_PASSWORD_HTTP_CODE=`curl -s -o /dev/null -w "%{http_code}" -X PROPFIND "${BASE_URL}/caldav/todos/" \
+    -u "${TENANT_ID}:${CALDAV_PASSWORD}" -H "Depth: 0"`
+  CALDAV_FEED_HTTP_CODE=`curl -s -o /dev/null -w "%{http_code}" -X GET "${BASE_URL}/todos.ics?token=${CALDAV_PASSWORD}"`
+
+  if [[ ${FEED_TOKEN_HTTP_CODE} -eq 401 && ${CALDAV_PASSWORD_HTTP_CODE} -eq 207 && ${CALDAV_FEED_HTTP_CODE} -eq 401 ]]; then
+    echo -e "${GREEN}Feed tokens and CalDAV passwords are scoped!${NC}"
+  else
+    echo -e "${RED}Feed tokens and CalDAV passwords are not scoped!${NC}"
+    exit 1
+  fi
+fi
+
 # Test DELETE /todos/:id
 echo -e "${YELLOW}Deleting a TODO item...${NC}"
-DELETE_HTTP_CODE=`curl -s -w "%{http_code}" -X DELETE "${BASE_URL}${LOCATION_HEADER_VALUE}"`
+DELETE_HTTP_CODE=`curl -s -w "%{http_code}" -X DELETE "${BASE_URL}${LOCATION_HEADER_VALUE}" \
+  -H "X-Tenant-ID: ${TENANT_ID}"`
 
 sleep 1
 
@@ -82,4 +321,443 @@ else
   echo -e "${RED}Failed to delete TODO item!${NC}"
   exit 1
 fi
+
+# Test GET /todos/events
+echo -e "${YELLOW}Streaming TODO events...${NC}"
+EVENTS_FILE=`mktemp`
+curl -s -N --max-time 4 "${BASE_URL}/todos/events" \
+  -H "X-Tenant-ID: ${TENANT_ID}" > ${EVENTS_FILE} &
+EVENTS_PID=$!
+
+sleep 1
+
+EVENT_TODO_ID=`curl -s -X POST "${BASE_URL}/todos" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  -H "Content-Type: application/json" \
+  -d '{"title": "Streamed Todo"}' | jq -r .id`
+curl -s -o /dev/null -X DELETE "${BASE_URL}/todos/${EVENT_TODO_ID}" \
+  -H "X-Tenant-ID: ${TENANT_ID}"
+
+wait ${EVENTS_PID} || true
+
+EVENT_TYPES=`grep '^data: ' ${EVENTS_FILE} | cut -c 7- | jq -s -c "[.[] | select(.todo_id == \"${EVENT_TODO_ID}\") | .type]"`
+CREATED_EVENT_ID=`grep '^data: ' ${EVENTS_FILE} | cut -c 7- | jq -r "select(.type == \"todo.created\" and .todo_id == \"${EVENT_TODO_ID}\") | .id"`
+rm -f ${EVENTS_FILE}
+
+echo ${EVENT_TYPES}
+
+if [[ ${EVENT_TYPES} == '["todo.created","todo.deleted"]' ]]; then
+  echo -e "${GREEN}TODO events streamed successfully!${NC}"
+else
+  echo -e "${RED}TODO events were not streamed!${NC}"
+  exit 1
+fi
+
+echo -e "${YELLOW}Resuming the TODO event stream...${NC}"
+RESUMED_EVENTS=`curl -s -N --max-time 1 "${BASE_URL}/todos/events" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  -H "Last-Event-ID: ${CREATED_EVENT_ID}" || true`
+
+if [[ `echo "${RESUMED_EVENTS}" | grep '^data: ' | cut -c 7- | jq -s -c '[.[].type]'` == '["todo.deleted"]' ]]; then
+  echo -e "${GREEN}Missed TODO events replayed successfully!${NC}"
+else
+  echo -e "${RED}Missed TODO events were not replayed!${NC}"
+  exit 1
+fi
+
+# Test PUT /todos/:id
+CLIENT_ID=`cat /proc/sys/kernel/random/uuid`
+
+echo -e "${YELLOW}Creating a TODO item with a client supplied ID...${NC}"
+PUT_CREATE_HTTP_CODE=`curl -s -o /dev/null -w "%{http_code}" -X PUT "${BASE_URL}/todos/${CLIENT_ID}" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  -H "Content-Type: application/json" \
+  -d '{"title": "Offline Todo", "description": "This todo was created offline"}'`
+
+sleep 1
+
+if [[ ${PUT_CREATE_HTTP_CODE} -eq 201 ]]; then
+  echo -e "${GREEN}TODO item created successfully!${NC}"
+else
+  echo -e "${RED}Failed to create TODO item with a client supplied ID!${NC}"
+  exit 1
+fi
+
+echo -e "${YELLOW}Replacing a TODO item with a client supplied ID...${NC}"
+PUT_REPLACE_HTTP_CODE=`curl -s -o /dev/null -w "%{http_code}" -X PUT "${BASE_URL}/todos/${CLIENT_ID}" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  -H "Content-Type: application/json" \
+  -d '{"title": "Offline Todo", "description": "This todo was replaced offline", "completed": true}'`
+
+sleep 1
+
+if [[ ${PUT_REPLACE_HTTP_CODE} -eq 200 ]]; then
+  echo -e "${GREEN}TODO item replaced successfully!${NC}"
+else
+  echo -e "${RED}Failed to replace TODO item!${NC}"
+  exit 1
+fi
+
+curl -s -o /dev/null -X DELETE "${BASE_URL}/todos/${CLIENT_ID}" \
+  -H "X-Tenant-ID: ${TENANT_ID}"
+
+# Test POST /todos:batch
+echo -e "${YELLOW}Creating TODO items in bulk...${NC}"
+BATCH_CREATE=`curl -s -w "\n%{http_code}" -X POST "${BASE_URL}/todos:batch" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  -H "Content-Type: application/json" \
+  -d '{"operations": [{"op": "create", "todo": {"title": "Batch Todo 1"}}, {"op": "create", "todo": {"title": " "}}]}'`
+
+sleep 1
+
+BATCH_CREATE_HTTP_CODE=$(echo "${BATCH_CREATE}" | tail -n1)
+BATCH_CREATE_BODY=$(echo "${BATCH_CREATE}" | sed '$d')
+echo ${BATCH_CREATE_BODY} | jq -c '[.results[] | {status, code: .error.code}]'
+
+if [[ ${BATCH_CREATE_HTTP_CODE} -eq 207 ]] \
+  && [[ `echo "${BATCH_CREATE_BODY}" | jq -c '[.results[].status]'` == "[201,422]" ]]; then
+  assert_todo_schema "`echo "${BATCH_CREATE_BODY}" | jq '.results[0].todo'`"
+  echo -e "${GREEN}Bulk creation reported each operation!${NC}"
+else
+  echo -e "${RED}Bulk creation failed!${NC}"
+  exit 1
+fi
+
+BATCH_ID=`echo "${BATCH_CREATE_BODY}" | jq -r '.results[0].todo.id'`
+
+echo -e "${YELLOW}Updating and deleting TODO items atomically...${NC}"
+BATCH_ATOMIC=`curl -s -X POST "${BASE_URL}/todos:batch" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  -H "Content-Type: application/json" \
+  -d '{"atomic": true, "operations": [{"op": "update", "id": "'${BATCH_ID}'", "todo": {"completed": true}}, {"op": "update", "id": "00000000-0000-4000-8000-000000000000", "todo": {"completed": true}}]}'`
+
+sleep 1
+
+echo ${BATCH_ATOMIC} | jq -c '[.results[] | {status, code: .error.code}]'
+
+if [[ `echo "${BATCH_ATOMIC}" | jq -c '[.results[].status]'` != "[424,404]" ]]; then
+  echo -e "${RED}Atomic batch was not aborted!${NC}"
+  exit 1
+fi
+
+BATCH_DELETE=`curl -s -X POST "${BASE_URL}/todos:batch" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  -H "Content-Type: application/json" \
+  -d '{"atomic": true, "operations": [{"op": "delete", "id": "'${BATCH_ID}'"}]}'`
+
+if [[ `echo "${BATCH_DELETE}" | jq -c '[.results[].status]'` == "[204]" ]]; then
+  echo -e "${GREEN}Atomic batch applied all or nothing!${NC}"
+else
+  echo -e "${RED}Atomic batch failed!${NC}"
+  exit 1
+fi
+
+BATCH_DELETE_AGAIN=`curl -s -X POST "${BASE_URL}/todos:batch" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  -H "Content-Type: application/json" \
+  -d '{"operations": [{"op": "delete", "id": "'${BATCH_ID}'"}]}'`
+
+if [[ `echo "${BATCH_DELETE_AGAIN}" | jq -c '[.results[].status]'` == "[404]" ]]; then
+  echo -e "${GREEN}Batch delete of a missing todo was reported as not found!${NC}"
+else
+  echo -e "${RED}Batch delete of a missing todo was not reported as not found!${NC}"
+  exit 1
+fi
+
+# Test POST /todos/:id:complete
+echo -e "${YELLOW}Completing a TODO item and its children...${NC}"
+PARENT_ID=`curl -s -X POST "${BASE_URL}/todos" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  -H "Content-Type: application/json" \
+  -H "Prefer: return=representation" \
+  -d '{"title": "Parent Todo", "list_id": "inbox"}' | jq -r '.id'`
+CHILD_ID=`curl -s -X POST "${BASE_URL}/todos" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  -H "Content-Type: application/json" \
+  -H "Prefer: return=representation" \
+  -d '{"title": "Child Todo", "list_id": "inbox", "parent_id": "'${PARENT_ID}'"}' | jq -r '.id'`
+
+sleep 1
+
+COMPLETE_TREE=`curl -s -X POST "${BASE_URL}/todos/${PARENT_ID}:complete" \
+  -H "X-Tenant-ID: ${TENANT_ID}"`
+
+echo ${COMPLETE_TREE} | jq -c '[.[] | {id, parent_id, completed}]'
+
+if [[ `echo "${COMPLETE_TREE}" | jq -c '[.[] | .completed]'` == "[true,true]" ]] \
+  && [[ `echo "${COMPLETE_TREE}" | jq -r '.[1].parent_id'` == "${PARENT_ID}" ]]; then
+  echo -e "${GREEN}TODO item and its children completed successfully!${NC}"
+else
+  echo -e "${RED}Failed to complete TODO item and its children!${NC}"
+  exit 1
+fi
+
+# Test POST /todos:move
+echo -e "${YELLOW}Moving the TODO items of a list...${NC}"
+MOVE_TODOS=`curl -s -X POST "${BASE_URL}/todos:move" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  -H "Content-Type: application/json" \
+  -d '{"from_list_id": "inbox", "to_list_id": "archive"}'`
+
+sleep 1
+
+if [[ `echo "${MOVE_TODOS}" | jq -c '[.[] | .list_id] | unique'` == '["archive"]' ]] \
+  && [[ `echo "${MOVE_TODOS}" | jq 'length'` -eq 2 ]]; then
+  echo -e "${GREEN}TODO items moved successfully!${NC}"
+else
+  echo -e "${RED}Failed to move TODO items!${NC}"
+  exit 1
+fi
+
+# Test GET /todos with filters
+echo -e "${YELLOW}Filtering TODO items...${NC}"
+FILTERED_TODOS=`curl -s -X GET "${BASE_URL}/todos?list_id=archive&parent_id=${PARENT_ID}" \
+  -H "X-Tenant-ID: ${TENANT_ID}"`
+INVALID_FILTER=`curl -s -X GET "${BASE_URL}/todos?completed=maybe" \
+  -H "X-Tenant-ID: ${TENANT_ID}"`
+
+if [[ `echo "${FILTERED_TODOS}" | jq -r 'map(.id) | join(",")'` == "${CHILD_ID}" ]] \
+  && [[ `echo "${INVALID_FILTER}" | jq -r '.code'` == "invalid-query" ]]; then
+  echo -e "${GREEN}TODO items filtered successfully!${NC}"
+else
+  echo -e "${RED}Failed to filter TODO items!${NC}"
+  exit 1
+fi
+
+# Test GET /todos/export
+echo -e "${YELLOW}Exporting TODO items...${NC}"
+EXPORT_HEADERS=`mktemp`
+EXPORT_CSV=`curl -s -D "${EXPORT_HEADERS}" -X GET "${BASE_URL}/todos/export?format=csv&list_id=archive" \
+  -H "X-Tenant-ID: ${TENANT_ID}"`
+EXPORT_JSONL=`curl -s -X GET "${BASE_URL}/todos/export?format=jsonl&list_id=archive" \
+  -H "X-Tenant-ID: ${TENANT_ID}"`
+
+if [[ `echo "${EXPORT_CSV}" | head -1 | tr -d '\r'` == "id,title,description,completed,list_id,parent_id,due,priority,external_id,created_at,updated_at" ]] \
+  && [[ `echo "${EXPORT_CSV}" | wc -l` -eq 3 ]] \
+  && grep -qi '^content-disposition: attachment; filename=todos-.*\.csv' "${EXPORT_HEADERS}" \
+  && [[ `echo "${EXPORT_JSONL}" | jq -s 'length'` -eq 2 ]] \
+  && [[ -z `schema_violations "$(echo "${EXPORT_JSONL}" | jq -s .)" "${TODO_LIST_SCHEMA}"` ]]; then
+  echo -e "${GREEN}TODO items exported successfully!${NC}"
+else
+  echo -e "${RED}Failed to export TODO items!${NC}"
+  exit 1
+fi
+rm -f "${EXPORT_HEADERS}"
+
+# Test POST /todos/import
+echo -e "${YELLOW}Importing TODO items...${NC}"
+IMPORT_PREFIX="import-$(date +%s)-$$"
+IMPORT_FILE='{"title": "Imported parent", "external_id": "'${IMPORT_PREFIX}'-1"}
+{"title": "Imported child", "external_id": "'${IMPORT_PREFIX}'-2", "parent_external_id": "'${IMPORT_PREFIX}'-1"}
+{"title": " ", "external_id": "'${IMPORT_PREFIX}'-3"}'
+IMPORT_DRY_RUN=`curl -s -X POST "${BASE_URL}/todos/import?dry_run=true" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  -H "Content-Type: application/x-ndjson" \
+  --data-binary "${IMPORT_FILE}"`
+IMPORT=`curl -s -X POST "${BASE_URL}/todos/import?format=jsonl" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  --data-binary "${IMPORT_FILE}"`
+IMPORT_AGAIN=`curl -s -X POST "${BASE_URL}/todos/import?format=jsonl" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  --data-binary "${IMPORT_FILE}"`
+IMPORTED_PARENT_ID=`echo "${IMPORT}" | jq -r '.rows[0].todo_id'`
+IMPORTED_CHILD_ID=`echo "${IMPORT}" | jq -r '.rows[1].todo_id'`
+IMPORTED_CHILD=`curl -s -X GET "${BASE_URL}/todos/${IMPORTED_CHILD_ID}" \
+  -H "X-Tenant-ID: ${TENANT_ID}"`
+
+if [[ `echo "${IMPORT_DRY_RUN}" | jq -c '[.valid, .invalid, [.rows[].status]]'` == '[2,1,["valid","valid","invalid"]]' ]] \
+  && [[ `echo "${IMPORT}" | jq -c '[.status, .created, .invalid, .rows[2].errors[0].rule]'` == '["succeeded",2,1,"notblank"]' ]] \
+  && [[ `echo "${IMPORT_AGAIN}" | jq -c '[.created, .duplicates, .rows[0].todo_id]'` == "[0,2,\"${IMPORTED_PARENT_ID}\"]" ]] \
+  && [[ `echo "${IMPORTED_CHILD}" | jq -r '.parent_id'` == "${IMPORTED_PARENT_ID}" ]] \
+  && [[ `echo "${IMPORTED_CHILD}" | jq -r '.external_id'` == "jsonl:${IMPORT_PREFIX}-2" ]]; then
+  assert_todo_schema "${IMPORTED_CHILD}"
+  echo -e "${GREEN}TODO items imported successfully!${NC}"
+else
+  echo -e "${RED}Failed to import TODO items!${NC}"
+  exit 1
+fi
+
+# Test concurrent POST /todos/import of the same external ID
+CONCURRENT_FILE='{"title": "Imported concurrently", "external_id": "'${IMPORT_PREFIX}'-concurrent"}'
+for i in 1 2 3; do
+  curl -s -o /dev/null -X POST "${BASE_URL}/todos/import?format=jsonl" \
+    -H "X-Tenant-ID: ${TENANT_ID}" \
+    --data-binary "${CONCURRENT_FILE}" &
+done
+wait
+CONCURRENT_COUNT=`curl -s -X GET "${BASE_URL}/todos" \
+  -H "X-Tenant-ID: ${TENANT_ID}" | jq "[.[] | select(.external_id == \"jsonl:${IMPORT_PREFIX}-concurrent\")] | length"`
+
+if [[ ${CONCURRENT_COUNT} -eq 1 ]]; then
+  echo -e "${GREEN}Concurrent imports created the todo once!${NC}"
+else
+  echo -e "${RED}Concurrent imports created ${CONCURRENT_COUNT} todos!${NC}"
+  exit 1
+fi
+
+# Test POST /todos/import with an Idempotency-Key reused without dry_run
+echo -e "${YELLOW}Importing TODO items after a dry run with the same Idempotency-Key...${NC}"
+IMPORT_KEY=`cat /proc/sys/kernel/random/uuid`
+IMPORT_KEY_FILE='{"title": "Imported with a key", "external_id": "'${IMPORT_PREFIX}'-key"}'
+IMPORT_KEY_DRY_RUN_HTTP_CODE=`curl -s -o /dev/null -w "%{http_code}" -X POST "${BASE_URL}/todos/import?format=jsonl&dry_run=true" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  -H "Idempotency-Key: ${IMPORT_KEY}" \
+  --data-binary "${IMPORT_KEY_FILE}"`
+IMPORT_KEY_REUSED=`curl -s -w "\n%{http_code}" -X POST "${BASE_URL}/todos/import?format=jsonl" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  -H "Idempotency-Key: ${IMPORT_KEY}" \
+  --data-binary "${IMPORT_KEY_FILE}"`
+
+if [[ ${IMPORT_KEY_DRY_RUN_HTTP_CODE} -eq 200 ]] \
+  && [[ `echo "${IMPORT_KEY_REUSED}" | tail -n 1` -eq 422 ]] \
+  && [[ `echo "${IMPORT_KEY_REUSED}" | head -n 1 | jq -r '.code'` == "idempotency-key-reused" ]]; then
+  echo -e "${GREEN}Idempotency-Key of a dry run rejected for a real import!${NC}"
+else
+  echo -e "${RED}Idempotency-Key of a dry run was replayed for a real import!${NC}"
+  exit 1
+fi
+
+# Test POST /todos/import as a job
+echo -e "${YELLOW}Importing TODO items with a job...${NC}"
+IMPORT_JOB_HEADERS=`mktemp`
+IMPORT_JOB=`curl -s -D "${IMPORT_JOB_HEADERS}" -X POST "${BASE_URL}/todos/import" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  -H "Content-Type: text/csv" \
+  -H "Prefer: respond-async" \
+  --data-binary $'title,external_id\r\nImported by a job,jsonl:'${IMPORT_PREFIX}'-1\r\n'`
+IMPORT_JOB_ID=`echo "${IMPORT_JOB}" | jq -r '.id'`
+
+for i in {1..10}; do
+  IMPORT_JOB=`curl -s -X GET "${BASE_URL}/imports/${IMPORT_JOB_ID}" \
+    -H "X-Tenant-ID: ${TENANT_ID}"`
+  if [[ `echo "${IMPORT_JOB}" | jq -r '.status'` == "succeeded" ]]; then
+    break
+  fi
+  sleep 1
+done
+
+if grep -qi "^location: /imports/${IMPORT_JOB_ID}" "${IMPORT_JOB_HEADERS}" \
+  && [[ `echo "${IMPORT_JOB}" | jq -c '[.status, .duplicates, .rows[0].todo_id]'` == "[\"succeeded\",1,\"${IMPORTED_PARENT_ID}\"]" ]]; then
+  echo -e "${GREEN}TODO items imported with a job successfully!${NC}"
+else
+  echo -e "${RED}Failed to import TODO items with a job!${NC}"
+  exit 1
+fi
+rm -f "${IMPORT_JOB_HEADERS}"
+
+curl -s -o /dev/null -X POST "${BASE_URL}/todos:batch" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  -H "Content-Type: application/json" \
+  -d '{"operations": [{"op": "delete", "id": "'${PARENT_ID}'"}, {"op": "delete", "id": "'${CHILD_ID}'"},
+    {"op": "delete", "id": "'${IMPORTED_PARENT_ID}'"}, {"op": "delete", "id": "'${IMPORTED_CHILD_ID}'"}]}'
+
+# Test the CalDAV collection
+CALDAV_ID=`cat /proc/sys/kernel/random/uuid`
+CALDAV_URL="${BASE_URL}/caldav/todos/${CALDAV_ID}.ics"
+CALDAV_TODO=$'BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VTODO\r\nUID:'${CALDAV_ID}$'\r\nSUMMARY:Synced over CalDAV\r\nPRIORITY:5\r\nEND:VTODO\r\nEND:VCALENDAR\r\n'
+
+echo -e "${YELLOW}Creating a TODO item over CalDAV...${NC}"
+CALDAV_CREATE_HTTP_CODE=`curl -s -o /dev/null -w "%{http_code}" -X PUT "${CALDAV_URL}" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  -H "Content-Type: text/calendar" \
+  -H "If-None-Match: *" \
+  --data-binary "${CALDAV_TODO}"`
+CALDAV_EXISTS_HTTP_CODE=`curl -s -o /dev/null -w "%{http_code}" -X PUT "${CALDAV_URL}" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  -H "Content-Type: text/calendar" \
+  -H "If-None-Match: *" \
+  --data-binary "${CALDAV_TODO}"`
+CALDAV_ETAG=`curl -s -D - -o /dev/null -X GET "${CALDAV_URL}" \
+  -H "X-Tenant-ID: ${TENANT_ID}" | tr -d '\r' | sed -n 's/^[Ee][Tt][Aa][Gg]: //p'`
+
+if [[ ${CALDAV_CREATE_HTTP_CODE} -eq 201 && ${CALDAV_EXISTS_HTTP_CODE} -eq 412 && -n "${CALDAV_ETAG}" ]]; then
+  echo -e "${GREEN}TODO item created over CalDAV successfully!${NC}"
+else
+  echo -e "${RED}Failed to create TODO item over CalDAV!${NC}"
+  exit 1
+fi
+
+echo -e "${YELLOW}Syncing the CalDAV collection...${NC}"
+SYNC=`curl -s -X REPORT "${BASE_URL}/caldav/todos/" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  -H "Content-Type: application/xml" \
+  -d '<D:sync-collection xmlns:D="DAV:"><D:sync-token/><D:sync-level>1</D:sync-level><D:prop><D:getetag/></D:prop></D:sync-collection>'`
+SYNC_TOKEN=`echo "${SYNC}" | sed -n 's/.*<D:sync-token>\([^<]*\)<\/D:sync-token>.*/\1/p'`
+
+if echo "${SYNC}" | grep -q "<D:href>/caldav/todos/${CALDAV_ID}.ics</D:href>" && [[ -n "${SYNC_TOKEN}" ]]; then
+  echo -e "${GREEN}CalDAV collection synced successfully!${NC}"
+else
+  echo -e "${RED}Failed to sync the CalDAV collection!${NC}"
+  exit 1
+fi
+
+echo -e "${YELLOW}Replacing a TODO item over CalDAV with ETags...${NC}"
+CALDAV_REPLACE_HTTP_CODE=`curl -s -o /dev/null -w "%{http_code}" -X PUT "${CALDAV_URL}" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  -H "Content-Type: text/calendar" \
+  -H "If-Match: ${CALDAV_ETAG}" \
+  --data-binary "${CALDAV_TODO/Synced/Resynced}"`
+CALDAV_STALE_HTTP_CODE=`curl -s -o /dev/null -w "%{http_code}" -X DELETE "${CALDAV_URL}" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  -H "If-Match: ${CALDAV_ETAG}"`
+CALDAV_ETAG=`curl -s -D - -o /dev/null -X GET "${CALDAV_URL}" \
+  -H "X-Tenant-ID: ${TENANT_ID}" | tr -d '\r' | sed -n 's/^[Ee][Tt][Aa][Gg]: //p'`
+CALDAV_DELETE_HTTP_CODE=`curl -s -o /dev/null -w "%{http_code}" -X DELETE "${CALDAV_URL}" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  -H "If-Match: ${CALDAV_ETAG}"`
+
+if [[ ${CALDAV_REPLACE_HTTP_CODE} -eq 204 && ${CALDAV_STALE_HTTP_CODE} -eq 412 && ${CALDAV_DELETE_HTTP_CODE} -eq 204 ]]; then
+  echo -e "${GREEN}TODO item replaced and deleted over CalDAV successfully!${NC}"
+else
+  echo -e "${RED}Failed to replace or delete TODO item over CalDAV!${NC}"
+  exit 1
+fi
+
+echo -e "${YELLOW}Syncing the CalDAV collection after a deletion...${NC}"
+RESYNC_HTTP_CODE=`curl -s -o /dev/null -w "%{http_code}" -X REPORT "${BASE_URL}/caldav/todos/" \
+  -H "X-Tenant-ID: ${TENANT_ID}" \
+  -H "Content-Type: application/xml" \
+  -d '<D:sync-collection xmlns:D="DAV:"><D:sync-token>'${SYNC_TOKEN}'</D:sync-token><D:sync-level>1</D:sync-level><D:prop><D:getetag/></D:prop></D:sync-collection>'`
+
+if [[ ${RESYNC_HTTP_CODE} -eq 403 ]]; then
+  echo -e "${GREEN}Sync token expired by the deletion as expected!${NC}"
+else
+  echo -e "${RED}Sync token was not expired by the deletion!${NC}"
+  exit 1
+fi
+
+# Test rate limiting, last as it exhausts the bucket of this client on the route. The forged
+# X-Forwarded-For headers must not give the requests buckets of their own.
+echo -e "${YELLOW}Exceeding the rate limit...${NC}"
+RATE_LIMIT_URL="${BASE_URL}/todos/not-a-uuid"
+RATE_LIMIT_HEADERS=`curl -s -D - -o /dev/null -X GET "${RATE_LIMIT_URL}" \
+  -H "X-Tenant-ID: ${TENANT_ID}" | tr -d '\r'`
+RATE_LIMIT_LIMIT=`echo "${RATE_LIMIT_HEADERS}" | sed -n 's/^[Rr]ate[Ll]imit-[Ll]imit: //p'`
+RATE_LIMIT_REMAINING=`echo "${RATE_LIMIT_HEADERS}" | sed -n 's/^[Rr]ate[Ll]imit-[Rr]emaining: //p'`
+
+if [[ -z "${RATE_LIMIT_LIMIT}" ]]; then
+  echo -e "${YELLOW}Rate limiting is disabled, skipping${NC}"
+else
+  RATE_LIMIT_DIR=`mktemp -d`
+  for i in `seq $((RATE_LIMIT_REMAINING + 10))`; do
+    curl -s -D "${RATE_LIMIT_DIR}/${i}.headers" -o /dev/null -X GET "${RATE_LIMIT_URL}" \
+      -H "X-Tenant-ID: ${TENANT_ID}" \
+      -H "X-Forwarded-For: 203.0.113.${i}" &
+  done
+  wait
+  RATE_LIMITED=`cat "${RATE_LIMIT_DIR}"/*.headers | tr -d '\r'`
+
+  if [[ `echo "${RATE_LIMITED}" | grep -c '^HTTP/[0-9.]* 429'` -ge 1 ]] \
+    && echo "${RATE_LIMITED}" | grep -qi "^ratelimit-limit: ${RATE_LIMIT_LIMIT}$" \
+    && echo "${RATE_LIMITED}" | grep -qi '^ratelimit-remaining: 0$' \
+    && echo "${RATE_LIMITED}" | grep -qi '^ratelimit-reset: [0-9]*$' \
+    && echo "${RATE_LIMITED}" | grep -qi '^retry-after: [0-9]*$'; then
+    echo -e "${GREEN}Rate limit enforced with 429 and RateLimit headers!${NC}"
+  else
+    echo -e "${RED}Rate limit was not enforced!${NC}"
+    exit 1
+  fi
+  rm -rf "${RATE_LIMIT_DIR}"
+fi
+
 echo -e "${YELLOW}All tests completed successfully!${NC}"
\ No newline at end of file
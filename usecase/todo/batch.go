@@ -0,0 +1,232 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/repository"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrNotFound is returned for a delete, batch or conditional operation on a todo that does not exist
+	ErrNotFound = repository.ErrNotFound
+	// ErrDuplicateTarget is returned for a batch operation on a todo already targeted by an earlier operation
+	ErrDuplicateTarget = errors.New("todo already targeted by the batch")
+	// ErrBatchAborted is returned for the operations of an atomic batch cancelled by another operation
+	ErrBatchAborted = errors.New("atomic batch aborted")
+)
+
+// BatchOp is the kind of a batch operation
+type BatchOp string
+
+const (
+	BatchCreate BatchOp = "create"
+	BatchUpdate BatchOp = "update"
+	BatchDelete BatchOp = "delete"
+)
+
+// BatchOperation is a single operation of a batch. Create uses Create, update uses ID and
+// Update, and delete uses ID.
+type BatchOperation struct {
+	Op     BatchOp
+	ID     uuid.UUID
+	Create entity.TodoCreate
+	Update entity.TodoUpdate
+	// Err rejects the operation before it is executed, for instance when its input is invalid
+	Err error
+}
+
+// BatchResult is the outcome of a batch operation
+type BatchResult struct {
+	// Todo is the created or updated todo, nil for deletes and failures
+	Todo *entity.Todo
+	Err  error
+}
+
+// ExecuteBatch creates, updates and deletes todos in a single request. Operations are
+// applied independently unless atomic is set, in which case either all of them are
+// applied or none is, and the operations that did not fail are reported as ErrBatchAborted.
+func (u *TodoUseCase) ExecuteBatch(ctx context.Context, ops []BatchOperation, atomic bool) (_ []BatchResult, err error) {
+	ctx, span := startSpan(ctx, "ExecuteBatch",
+		attribute.Int("batch.size", len(ops)),
+		attribute.Bool("batch.atomic", atomic),
+	)
+	defer func() { endSpan(span, err) }()
+
+	results := make([]BatchResult, len(ops))
+	for i, op := range ops {
+		results[i].Err = op.Err
+	}
+	rejectDuplicateTargets(ops, results)
+
+	existing, err := u.repo.FindByIDs(ctx, updateTargets(ops, results))
+	if err != nil {
+		return nil, err
+	}
+
+	quota, err := u.quotaFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// writes[j] is the write of the operation at position indexes[j]
+	var writes []repository.TodoWrite
+	var indexes []int
+	now := time.Now()
+	for i, op := range ops {
+		if results[i].Err != nil {
+			continue
+		}
+
+		write := repository.TodoWrite{ID: op.ID}
+		switch op.Op {
+		case BatchCreate:
+			if err := u.checkParent(ctx, uuid.Nil, op.Create.ParentID); err != nil {
+				results[i].Err = err
+				continue
+			}
+			write.Kind = repository.WriteCreate
+			write.Todo = newTodo(uuid.New(), op.Create, now)
+		case BatchUpdate:
+			todo, ok := existing[op.ID]
+			if !ok {
+				results[i].Err = ErrNotFound
+				continue
+			}
+			if op.Update.ParentID != nil {
+				if err := u.checkParent(ctx, op.ID, *op.Update.ParentID); err != nil {
+					results[i].Err = err
+					continue
+				}
+			}
+			write.ReadUpdatedAt = todo.UpdatedAt
+			applyUpdate(todo, op.Update, now)
+			write.Kind = repository.WriteUpdate
+			write.Todo = todo
+		case BatchDelete:
+			write.Kind = repository.WriteDelete
+		}
+		writes = append(writes, write)
+		indexes = append(indexes, i)
+		results[i].Todo = write.Todo
+	}
+
+	if atomic {
+		if failed(results) {
+			abortBatch(results)
+			return results, nil
+		}
+		uow := u.repo.NewUnitOfWork()
+		uow.LimitTodos(quota)
+		for _, write := range writes {
+			switch write.Kind {
+			case repository.WriteCreate:
+				uow.Create(write.Todo)
+			case repository.WriteUpdate:
+				uow.Update(write.Todo, write.ReadUpdatedAt)
+			case repository.WriteDelete:
+				uow.Delete(write.ID)
+			}
+		}
+		err := uow.Commit(ctx)
+		var transactionErr *repository.TransactionError
+		if errors.As(err, &transactionErr) {
+			results[indexes[transactionErr.Index]].Err = transactionErr.Err
+			abortBatch(results)
+			return results, nil
+		}
+		if errors.Is(err, ErrQuotaExceeded) {
+			for j, write := range writes {
+				if write.Kind == repository.WriteCreate {
+					results[indexes[j]] = BatchResult{Err: ErrQuotaExceeded}
+				}
+			}
+			abortBatch(results)
+			return results, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else if len(writes) > 0 {
+		errs, err := u.repo.BatchWrite(ctx, writes, quota)
+		if err != nil {
+			return nil, err
+		}
+		for j, writeErr := range errs {
+			if writeErr != nil {
+				results[indexes[j]] = BatchResult{Err: writeErr}
+			}
+		}
+	}
+
+	for j, write := range writes {
+		if results[indexes[j]].Err != nil {
+			continue
+		}
+		switch write.Kind {
+		case repository.WriteCreate:
+			u.publish(ctx, entity.TodoCreated, write.Todo.ID, write.Todo)
+		case repository.WriteUpdate:
+			u.publish(ctx, entity.TodoUpdated, write.Todo.ID, write.Todo)
+		case repository.WriteDelete:
+			u.publish(ctx, entity.TodoDeleted, write.ID, nil)
+		}
+	}
+
+	u.logger(ctx).Debug("Executed batch",
+		zap.Int("operations", len(ops)),
+		zap.Int("writes", len(writes)),
+		zap.Bool("atomic", atomic),
+	)
+	return results, nil
+}
+
+// rejectDuplicateTargets fails the operations targeting a todo already targeted by an
+// earlier operation, which DynamoDB rejects within a single batch or transaction
+func rejectDuplicateTargets(ops []BatchOperation, results []BatchResult) {
+	seen := make(map[uuid.UUID]bool)
+	for i, op := range ops {
+		if op.Op == BatchCreate || results[i].Err != nil {
+			continue
+		}
+		if seen[op.ID] {
+			results[i].Err = ErrDuplicateTarget
+		}
+		seen[op.ID] = true
+	}
+}
+
+// updateTargets returns the IDs of the todos updated by the operations not yet failed
+func updateTargets(ops []BatchOperation, results []BatchResult) []uuid.UUID {
+	var ids []uuid.UUID
+	for i, op := range ops {
+		if op.Op == BatchUpdate && results[i].Err == nil {
+			ids = append(ids, op.ID)
+		}
+	}
+	return ids
+}
+
+// failed reports whether any operation failed
+func failed(results []BatchResult) bool {
+	for _, result := range results {
+		if result.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// abortBatch reports every operation that did not fail as aborted
+func abortBatch(results []BatchResult) {
+	for i := range results {
+		if results[i].Err == nil {
+			results[i] = BatchResult{Err: ErrBatchAborted}
+		}
+	}
+}
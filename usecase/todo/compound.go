@@ -0,0 +1,115 @@
+package todo
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// MoveTodos moves every todo of the list from to the list to, all or nothing.
+// A todo changed concurrently aborts the move with repository.ErrConcurrentModification,
+// and lists of more than repository.MaxUnitOfWorkWrites todos fail with repository.ErrTooManyWrites.
+func (u *TodoUseCase) MoveTodos(ctx context.Context, from, to string) (_ []*entity.Todo, err error) {
+	ctx, span := startSpan(ctx, "MoveTodos",
+		attribute.String("todo.list.from", from),
+		attribute.String("todo.list.to", to),
+	)
+	defer func() { endSpan(span, err) }()
+
+	todos, err := u.repo.FindAll(ctx, entity.TodoFilter{ListID: &from})
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("todo.count", len(todos)))
+	if from == to || len(todos) == 0 {
+		return todos, nil
+	}
+
+	moved := make([]*entity.Todo, 0, len(todos))
+	uow := u.repo.NewUnitOfWork()
+	now := time.Now()
+	for _, todo := range todos {
+		readUpdatedAt := todo.UpdatedAt
+		todo.ListID = to
+		todo.UpdatedAt = now
+		uow.Update(todo, readUpdatedAt)
+		moved = append(moved, todo)
+	}
+
+	if err := uow.Commit(ctx); err != nil {
+		return nil, err
+	}
+	for _, todo := range moved {
+		u.publish(ctx, entity.TodoUpdated, todo.ID, todo)
+	}
+
+	u.logger(ctx).Debug("Moved todos",
+		zap.String("from", from),
+		zap.String("to", to),
+		zap.Int("count", len(moved)),
+	)
+	return moved, nil
+}
+
+// CompleteTodoTree marks a todo and all its descendants as completed, all or nothing.
+// It returns the todo followed by its descendants, or nil when the todo does not exist.
+func (u *TodoUseCase) CompleteTodoTree(ctx context.Context, id uuid.UUID) (_ []*entity.Todo, err error) {
+	ctx, span := startSpan(ctx, "CompleteTodoTree", attribute.String("todo.id", id.String()))
+	defer func() { endSpan(span, err) }()
+
+	root, err := u.repo.FindByID(ctx, id)
+	if err != nil || root == nil {
+		return nil, err
+	}
+
+	// Walk the tree breadth first, reading the children of each todo, so the todo comes first
+	// and children follow their parent. Visited todos are skipped in case concurrent writes
+	// left a cycle.
+	tree := []*entity.Todo{root}
+	visited := map[uuid.UUID]bool{root.ID: true}
+	for i := 0; i < len(tree); i++ {
+		parentID := tree[i].ID
+		children, err := u.repo.FindAll(ctx, entity.TodoFilter{ParentID: &parentID})
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range children {
+			if !visited[child.ID] {
+				visited[child.ID] = true
+				tree = append(tree, child)
+			}
+		}
+	}
+	span.SetAttributes(attribute.Int("todo.count", len(tree)))
+
+	uow := u.repo.NewUnitOfWork()
+	now := time.Now()
+	var changed []*entity.Todo
+	for _, todo := range tree {
+		if todo.Completed {
+			continue
+		}
+		readUpdatedAt := todo.UpdatedAt
+		todo.Completed = true
+		todo.UpdatedAt = now
+		uow.Update(todo, readUpdatedAt)
+		changed = append(changed, todo)
+	}
+	if err := uow.Commit(ctx); err != nil {
+		return nil, err
+	}
+	for _, todo := range changed {
+		u.publish(ctx, entity.TodoUpdated, todo.ID, todo)
+	}
+
+	u.logger(ctx).Debug("Completed todo tree",
+		zap.String("id", id.String()),
+		zap.Int("count", len(tree)),
+		zap.Int("changed", uow.Len()),
+	)
+	return tree, nil
+}
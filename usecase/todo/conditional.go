@@ -0,0 +1,121 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/repository"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// ErrPreconditionFailed is returned when a todo is not in the state a conditional write expects
+var ErrPreconditionFailed = errors.New("todo precondition failed")
+
+// Precondition makes a write conditional on the state of the todo, such as the version a client last read.
+// The zero value accepts any state.
+type Precondition struct {
+	// Exists, when set, requires the todo to exist (true) or not to exist (false)
+	Exists *bool
+	// UpdatedAt, when set, requires the todo to exist and be unchanged since it was updated at this time
+	UpdatedAt *time.Time
+}
+
+// isSet reports whether p restricts the state of the todo
+func (p Precondition) isSet() bool {
+	return p.Exists != nil || p.UpdatedAt != nil
+}
+
+// check returns ErrPreconditionFailed when todo, nil if it does not exist, does not satisfy p
+func (p Precondition) check(todo *entity.Todo) error {
+	if p.Exists != nil && *p.Exists != (todo != nil) {
+		return ErrPreconditionFailed
+	}
+	if p.UpdatedAt != nil && (todo == nil || !todo.UpdatedAt.Equal(*p.UpdatedAt)) {
+		return ErrPreconditionFailed
+	}
+	return nil
+}
+
+// SaveTodo creates a todo with a client supplied ID or fully replaces the existing one, keeping
+// its creation time and external ID, when the todo satisfies pre. It reports whether the todo
+// was created. The write is conditional on the todo read, so a concurrent change fails it with
+// ErrPreconditionFailed when pre is set, and with a repository error otherwise, instead of
+// being overwritten.
+func (u *TodoUseCase) SaveTodo(ctx context.Context, id uuid.UUID, input entity.TodoReplace, pre Precondition) (_ *entity.Todo, _ bool, err error) {
+	ctx, span := startSpan(ctx, "SaveTodo", attribute.String("todo.id", id.String()))
+	defer func() { endSpan(span, err) }()
+
+	existing, err := u.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := pre.check(existing); err != nil {
+		return nil, false, err
+	}
+	quota, err := u.quotaFor(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := u.checkParent(ctx, id, input.ParentID); err != nil {
+		return nil, false, err
+	}
+
+	todo := newTodo(id, entity.TodoCreate(input), time.Now())
+	uow := u.repo.NewUnitOfWork()
+	uow.LimitTodos(quota)
+	if existing == nil {
+		uow.Create(todo)
+	} else {
+		todo.CreatedAt = existing.CreatedAt
+		todo.ExternalID = existing.ExternalID
+		uow.Update(todo, existing.UpdatedAt)
+	}
+	if err := uow.Commit(ctx); err != nil {
+		return nil, false, conditionalError(err, pre)
+	}
+
+	u.logger(ctx).Debug("Saved todo", zap.String("id", id.String()), zap.Bool("created", existing == nil))
+	u.publishWrite(ctx, existing == nil, todo)
+	return todo, existing == nil, nil
+}
+
+// DeleteTodoIf deletes a todo when it satisfies pre. It returns ErrNotFound when the todo does
+// not exist and pre accepts that, and fails as SaveTodo does when the todo changes concurrently.
+func (u *TodoUseCase) DeleteTodoIf(ctx context.Context, id uuid.UUID, pre Precondition) (err error) {
+	ctx, span := startSpan(ctx, "DeleteTodoIf", attribute.String("todo.id", id.String()))
+	defer func() { endSpan(span, err) }()
+
+	existing, err := u.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := pre.check(existing); err != nil {
+		return err
+	}
+	if existing == nil {
+		return ErrNotFound
+	}
+
+	uow := u.repo.NewUnitOfWork()
+	uow.DeleteUnchanged(id, existing.UpdatedAt)
+	if err := uow.Commit(ctx); err != nil {
+		return conditionalError(err, pre)
+	}
+
+	u.logger(ctx).Debug("Deleted todo", zap.String("id", id.String()))
+	u.publish(ctx, entity.TodoDeleted, id, nil)
+	return nil
+}
+
+// conditionalError translates the failure of a conditional write to ErrPreconditionFailed
+// when the caller set a precondition, as the todo no longer is in the state it expects
+func conditionalError(err error, pre Precondition) error {
+	if pre.isSet() && (errors.Is(err, repository.ErrConcurrentModification) || errors.Is(err, repository.ErrAlreadyExists)) {
+		return ErrPreconditionFailed
+	}
+	return err
+}
@@ -0,0 +1,546 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/repository"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxReportedRows bounds the rows kept in the outcome of a background job, so it fits in a DynamoDB item
+	maxReportedRows = 500
+	// importChunkRows is the number of rows written between two progress reports of a background job
+	importChunkRows = 500
+)
+
+// errImportInterrupted is the error of background jobs stopped by a shutdown
+var errImportInterrupted = errors.New("import interrupted by a shutdown; import the file again, " +
+	"rows with an external ID that were already created are reported as duplicates")
+
+// ImportRow is a row of an imported file
+type ImportRow struct {
+	Todo       entity.TodoCreate
+	ExternalID string
+	// ParentExternalID makes the todo a child of the todo imported with this external ID,
+	// from the same file or from an earlier import
+	ParentExternalID string
+	// Errors rejects the row before it is imported, for instance when its fields are invalid
+	Errors []entity.ImportFieldError
+}
+
+// ImportUseCase handles importing files of todos, either within the request or as background jobs
+type ImportUseCase struct {
+	todos *TodoUseCase
+	jobs  repository.ImportJobRepository
+	ttl   time.Duration
+	// ctx is cancelled to interrupt the running jobs on shutdown
+	ctx     context.Context
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+}
+
+// NewImportUseCase creates a new ImportUseCase instance. The outcome of background jobs
+// can be retrieved for ttl after they were started.
+func NewImportUseCase(todos *TodoUseCase, jobs repository.ImportJobRepository, ttl time.Duration) *ImportUseCase {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ImportUseCase{todos: todos, jobs: jobs, ttl: ttl, ctx: ctx, cancel: cancel}
+}
+
+// ImportTodos imports rows within the request and returns the outcome of every row.
+// Nothing is written when dryRun is set.
+func (u *ImportUseCase) ImportTodos(ctx context.Context, format string, rows []ImportRow, dryRun bool) (_ *entity.ImportJob, err error) {
+	ctx, span := startSpan(ctx, "ImportTodos",
+		attribute.String("import.format", format),
+		attribute.Int("import.rows", len(rows)),
+		attribute.Bool("import.dry_run", dryRun),
+	)
+	defer func() { endSpan(span, err) }()
+
+	job := u.newJob(format, rows, dryRun)
+	job.Status = entity.ImportRunning
+	if err := u.run(ctx, job, rows, true, nil); err != nil {
+		return nil, err
+	}
+	job.Status = entity.ImportSucceeded
+	return job, nil
+}
+
+// StartImport stores a pending job importing rows in the background and returns it.
+// Its progress and outcome are retrieved with GetImport.
+func (u *ImportUseCase) StartImport(ctx context.Context, format string, rows []ImportRow, dryRun bool) (_ *entity.ImportJob, err error) {
+	ctx, span := startSpan(ctx, "StartImport",
+		attribute.String("import.format", format),
+		attribute.Int("import.rows", len(rows)),
+		attribute.Bool("import.dry_run", dryRun),
+	)
+	defer func() { endSpan(span, err) }()
+
+	job := u.newJob(format, rows, dryRun)
+	span.SetAttributes(attribute.String("import.id", job.ID.String()))
+	if err := u.jobs.Save(ctx, job); err != nil {
+		return nil, err
+	}
+	started := *job
+
+	// The job outlives the request but keeps its tenant and logger
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(u.ctx, cancel)
+	u.running.Add(1)
+	go func() {
+		defer u.running.Done()
+		defer stop()
+		defer cancel()
+		u.runJob(jobCtx, job, rows)
+	}()
+
+	return &started, nil
+}
+
+// GetImport retrieves an import job by ID, or nil when it does not exist or has expired
+func (u *ImportUseCase) GetImport(ctx context.Context, id uuid.UUID) (_ *entity.ImportJob, err error) {
+	ctx, span := startSpan(ctx, "GetImport", attribute.String("import.id", id.String()))
+	defer func() { endSpan(span, err) }()
+
+	return u.jobs.FindByID(ctx, id)
+}
+
+// Shutdown waits for the running jobs until ctx is done, then interrupts the remaining
+// ones and waits for them to record their failure
+func (u *ImportUseCase) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		u.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		u.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// newJob creates a pending job for rows
+func (u *ImportUseCase) newJob(format string, rows []ImportRow, dryRun bool) *entity.ImportJob {
+	now := time.Now()
+	return &entity.ImportJob{
+		ID:        uuid.New(),
+		Format:    format,
+		DryRun:    dryRun,
+		Status:    entity.ImportPending,
+		Total:     len(rows),
+		Rows:      []entity.ImportRowResult{},
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: now.Add(u.ttl),
+	}
+}
+
+// runJob imports rows for a background job, saving its progress as rows are written
+func (u *ImportUseCase) runJob(ctx context.Context, job *entity.ImportJob, rows []ImportRow) {
+	ctx, span := startSpan(ctx, "ImportJob", attribute.String("import.id", job.ID.String()))
+	log := u.todos.logger(ctx).With(zap.String("import_id", job.ID.String()))
+
+	save := func() error {
+		job.UpdatedAt = time.Now()
+		return u.jobs.Save(ctx, job)
+	}
+
+	job.Status = entity.ImportRunning
+	err := save()
+	if err == nil {
+		err = u.run(ctx, job, rows, false, save)
+	}
+	if err != nil {
+		job.Status = entity.ImportFailed
+		job.Error = err.Error()
+		if u.ctx.Err() != nil {
+			job.Error = errImportInterrupted.Error()
+		}
+	} else {
+		job.Status = entity.ImportSucceeded
+	}
+	endSpan(span, err)
+
+	// The outcome is recorded even when the job was interrupted
+	job.UpdatedAt = time.Now()
+	if saveErr := u.jobs.Save(context.WithoutCancel(ctx), job); saveErr != nil {
+		log.Error("Failed to save import job", zap.Error(saveErr))
+	}
+
+	fields := []zap.Field{
+		zap.String("status", string(job.Status)),
+		zap.Int("created", job.Created),
+		zap.Int("duplicates", job.Duplicates),
+		zap.Int("invalid", job.Invalid),
+		zap.Int("failed", job.Failed),
+	}
+	if err != nil {
+		log.Error("Import job failed", append(fields, zap.Error(err))...)
+		return
+	}
+	log.Info("Import job finished", fields...)
+}
+
+// run imports rows into job. Rows whose external ID matches an existing todo or an earlier
+// row are duplicates, parents are resolved by external ID, and todos are written parents
+// first so a child is never created without its parent. progress, when set, is called
+// after every chunk of written rows. Every row is reported when allRows is set, otherwise
+// only the rows that were not imported, up to maxReportedRows.
+func (u *ImportUseCase) run(ctx context.Context, job *entity.ImportJob, rows []ImportRow, allRows bool, progress func() error) error {
+	imp := &importRun{
+		rows:    rows,
+		results: make([]entity.ImportRowResult, len(rows)),
+		todos:   make([]*entity.Todo, len(rows)),
+		rowOf:   make(map[uuid.UUID]int),
+	}
+	report := func() error {
+		imp.tally(job, allRows)
+		if progress == nil {
+			return nil
+		}
+		return progress()
+	}
+
+	for i, row := range rows {
+		imp.results[i] = entity.ImportRowResult{Row: i + 1, ExternalID: row.ExternalID}
+		if len(row.Errors) > 0 {
+			imp.results[i].Status = entity.ImportRowInvalid
+			imp.results[i].Errors = row.Errors
+		}
+	}
+
+	externalIDs, err := u.externalIDs(ctx, rows)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i, row := range rows {
+		if imp.results[i].Status != "" {
+			continue
+		}
+		if id, ok := externalIDs[row.ExternalID]; ok && row.ExternalID != "" {
+			imp.results[i].Status = entity.ImportRowDuplicate
+			imp.results[i].TodoID = id
+			continue
+		}
+
+		todo := newTodo(uuid.New(), row.Todo, now)
+		todo.ExternalID = row.ExternalID
+		if row.ExternalID != "" {
+			externalIDs[row.ExternalID] = todo.ID
+		}
+		imp.todos[i] = todo
+		imp.rowOf[todo.ID] = i
+		imp.results[i].TodoID = todo.ID
+	}
+
+	for i, row := range rows {
+		if imp.todos[i] == nil || row.ParentExternalID == "" {
+			continue
+		}
+		parentID, ok := externalIDs[row.ParentExternalID]
+		if !ok {
+			imp.reject(i, entity.ImportFieldError{
+				Field:   "parent_external_id",
+				Rule:    "exists",
+				Message: "must be the external ID of a todo of the file or of an earlier import",
+			})
+			continue
+		}
+		imp.todos[i].ParentID = parentID
+	}
+
+	if err := u.checkParents(ctx, imp); err != nil {
+		return err
+	}
+	imp.dropOrphans()
+
+	if job.DryRun {
+		// The quota is enforced as the todos are written, so a dry run can only estimate it
+		remaining := -1
+		if slices.ContainsFunc(imp.todos, func(todo *entity.Todo) bool { return todo != nil }) {
+			if remaining, err = u.todos.remainingQuota(ctx); err != nil {
+				return err
+			}
+		}
+		for i := range rows {
+			if imp.todos[i] == nil {
+				continue
+			}
+			if remaining == 0 {
+				imp.fail(i, ErrQuotaExceeded.Error())
+				continue
+			}
+			if remaining > 0 {
+				remaining--
+			}
+		}
+		imp.dropOrphans()
+		for i, todo := range imp.todos {
+			if todo != nil {
+				imp.results[i].Status = entity.ImportRowValid
+				imp.results[i].TodoID = uuid.Nil
+			}
+		}
+		return report()
+	}
+	if err := report(); err != nil {
+		return err
+	}
+
+	// A level is only written once the previous one is, so the children of the rows that
+	// failed are dropped instead of being created without their parent
+	for _, level := range imp.levels() {
+		imp.dropOrphans()
+		for start := 0; start < len(level); start += importChunkRows {
+			if err := u.writeRows(ctx, imp, level[start:min(start+importChunkRows, len(level))]); err != nil {
+				return err
+			}
+			if err := report(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return report()
+}
+
+// writeRows creates the todos of the given rows that are still to be created
+func (u *ImportUseCase) writeRows(ctx context.Context, imp *importRun, rows []int) error {
+	var writes []repository.TodoWrite
+	var indexes []int
+	for _, i := range rows {
+		if imp.todos[i] == nil {
+			continue
+		}
+		writes = append(writes, repository.TodoWrite{Kind: repository.WriteCreate, Todo: imp.todos[i]})
+		indexes = append(indexes, i)
+	}
+	if len(writes) == 0 {
+		return nil
+	}
+
+	quota, err := u.todos.quotaFor(ctx)
+	if err != nil {
+		return err
+	}
+	errs, err := u.todos.repo.BatchWrite(ctx, writes, quota)
+	if err != nil {
+		return err
+	}
+	for j, writeErr := range errs {
+		switch {
+		case errors.Is(writeErr, repository.ErrDuplicateExternalID):
+			// Another import created a todo with the same external ID in the meantime
+			imp.results[indexes[j]].Status = entity.ImportRowDuplicate
+			imp.results[indexes[j]].TodoID = uuid.Nil
+			imp.todos[indexes[j]] = nil
+		case writeErr != nil:
+			reason := writeErr.Error()
+			if !errors.Is(writeErr, repository.ErrWriteUnprocessed) && !errors.Is(writeErr, ErrQuotaExceeded) {
+				// Errors of the database are not shown to clients
+				u.todos.logger(ctx).Error("Failed to import todo", zap.Error(writeErr))
+				reason = "todo could not be written"
+			}
+			imp.fail(indexes[j], reason)
+		default:
+			imp.results[indexes[j]].Status = entity.ImportRowCreated
+			u.todos.publish(ctx, entity.TodoCreated, writes[j].Todo.ID, writes[j].Todo)
+		}
+	}
+	return nil
+}
+
+// externalIDs maps the external IDs of the todos of the tenant to their IDs. The tenant
+// is only read when the rows use external IDs.
+func (u *ImportUseCase) externalIDs(ctx context.Context, rows []ImportRow) (map[string]uuid.UUID, error) {
+	externalIDs := make(map[string]uuid.UUID)
+	if !slices.ContainsFunc(rows, func(row ImportRow) bool { return row.ExternalID != "" || row.ParentExternalID != "" }) {
+		return externalIDs, nil
+	}
+
+	err := u.todos.repo.ForEachPage(ctx, entity.TodoFilter{}, func(todos []*entity.Todo) error {
+		for _, todo := range todos {
+			if todo.ExternalID != "" {
+				externalIDs[todo.ExternalID] = todo.ID
+			}
+		}
+		return nil
+	})
+	return externalIDs, err
+}
+
+// checkParents rejects the todos whose ancestors form a cycle, are nested too deep,
+// or end with a todo of the tenant that does not exist
+func (u *ImportUseCase) checkParents(ctx context.Context, imp *importRun) error {
+	// Rows are only rejected once every row was checked, so all the rows of a cycle are
+	// reported as such. Existing todos are checked once, however many rows they are the ancestor of.
+	rejected := make(map[int]entity.ImportFieldError)
+	checked := make(map[uuid.UUID]error)
+	for i, todo := range imp.todos {
+		if todo == nil || todo.ParentID == uuid.Nil {
+			continue
+		}
+
+		field := "parent_id"
+		if imp.rows[i].ParentExternalID != "" {
+			field = "parent_external_id"
+		}
+
+		ancestor := todo.ParentID
+		cycle := false
+		for depth := 1; ancestor != uuid.Nil && !cycle; depth++ {
+			j, ok := imp.rowOf[ancestor]
+			if !ok || imp.todos[j] == nil {
+				break
+			}
+			cycle = depth > maxTodoDepth
+			ancestor = imp.todos[j].ParentID
+		}
+		if cycle {
+			rejected[i] = entity.ImportFieldError{
+				Field:   field,
+				Rule:    "acyclic",
+				Message: "must not make the todo its own ancestor or nest todos too deep",
+			}
+			continue
+		}
+		if _, ok := imp.rowOf[ancestor]; ok || ancestor == uuid.Nil {
+			// The top level, or a rejected row of the file which dropOrphans takes care of
+			continue
+		}
+
+		err, ok := checked[ancestor]
+		if !ok {
+			err = u.todos.checkParent(ctx, uuid.Nil, ancestor)
+			checked[ancestor] = err
+		}
+		switch {
+		case errors.Is(err, ErrParentNotFound):
+			rejected[i] = entity.ImportFieldError{Field: field, Rule: "exists", Message: "must be an existing todo"}
+		case errors.Is(err, ErrParentCycle):
+			rejected[i] = entity.ImportFieldError{Field: field, Rule: "acyclic", Message: "must not nest todos too deep"}
+		case err != nil:
+			return err
+		}
+	}
+
+	for i, fe := range rejected {
+		imp.reject(i, fe)
+	}
+	return nil
+}
+
+// importRun is the state of an import while its rows are checked and written
+type importRun struct {
+	rows    []ImportRow
+	results []entity.ImportRowResult
+	// todos[i] is the todo created for row i, nil once the row is not to be created
+	todos []*entity.Todo
+	// rowOf maps the IDs of the todos created by the import to their row
+	rowOf map[uuid.UUID]int
+}
+
+// reject marks row i as invalid
+func (r *importRun) reject(i int, fe entity.ImportFieldError) {
+	r.results[i].Status = entity.ImportRowInvalid
+	r.results[i].TodoID = uuid.Nil
+	r.results[i].Errors = append(r.results[i].Errors, fe)
+	r.todos[i] = nil
+}
+
+// fail marks the valid row i as failed
+func (r *importRun) fail(i int, reason string) {
+	r.results[i].Status = entity.ImportRowFailed
+	r.results[i].TodoID = uuid.Nil
+	r.results[i].Reason = reason
+	r.todos[i] = nil
+}
+
+// dropOrphans fails the rows whose parent is a row of the file that is not created
+func (r *importRun) dropOrphans() {
+	for changed := true; changed; {
+		changed = false
+		for i, todo := range r.todos {
+			if todo == nil {
+				continue
+			}
+			if j, ok := r.rowOf[todo.ParentID]; ok && r.todos[j] == nil {
+				r.fail(i, "the parent row was not imported")
+				changed = true
+			}
+		}
+	}
+}
+
+// levels groups the rows to create by their depth among the todos of the file, in
+// file order within a level, so every todo is in a later level than its parent
+func (r *importRun) levels() [][]int {
+	depths := make(map[int]int)
+	var depth func(i int) int
+	depth = func(i int) int {
+		if d, ok := depths[i]; ok {
+			return d
+		}
+		d := 0
+		if j, ok := r.rowOf[r.todos[i].ParentID]; ok {
+			d = depth(j) + 1
+		}
+		depths[i] = d
+		return d
+	}
+
+	var levels [][]int
+	for i, todo := range r.todos {
+		if todo == nil {
+			continue
+		}
+		d := depth(i)
+		for len(levels) <= d {
+			levels = append(levels, nil)
+		}
+		levels[d] = append(levels[d], i)
+	}
+	return levels
+}
+
+// tally updates the counts and rows of job from the results so far
+func (r *importRun) tally(job *entity.ImportJob, allRows bool) {
+	job.Processed, job.Valid, job.Created, job.Duplicates, job.Invalid, job.Failed = 0, 0, 0, 0, 0, 0
+	job.Rows = job.Rows[:0]
+	for _, result := range r.results {
+		switch result.Status {
+		case "":
+			continue
+		case entity.ImportRowValid:
+			job.Valid++
+		case entity.ImportRowCreated:
+			job.Created++
+		case entity.ImportRowDuplicate:
+			job.Duplicates++
+		case entity.ImportRowInvalid:
+			job.Invalid++
+		case entity.ImportRowFailed:
+			job.Failed++
+		}
+		job.Processed++
+
+		imported := result.Status == entity.ImportRowValid || result.Status == entity.ImportRowCreated
+		if allRows || (!imported && len(job.Rows) < maxReportedRows) {
+			job.Rows = append(job.Rows, result)
+		}
+	}
+}
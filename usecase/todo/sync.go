@@ -0,0 +1,69 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// ErrSyncStateExpired is returned when the changes since a sync state cannot be listed,
+// because todos that existed then were deleted since
+var ErrSyncStateExpired = errors.New("sync state expired")
+
+// SyncState identifies the todos of a tenant at a point in time, for clients syncing their changes
+type SyncState struct {
+	// UpdatedAt is the latest update time of the todos, the zero time when there were none
+	UpdatedAt time.Time
+	// Count is the number of todos
+	Count int
+}
+
+// SyncTodos returns the todos changed since the state since, or all of them when since is nil,
+// along with the current state. Deletions are not recorded, so when a todo that existed in since
+// was deleted, ErrSyncStateExpired is returned and the client must sync all the todos again.
+func (u *TodoUseCase) SyncTodos(ctx context.Context, since *SyncState) (_ []*entity.Todo, _ SyncState, err error) {
+	ctx, span := startSpan(ctx, "SyncTodos")
+	defer func() { endSpan(span, err) }()
+
+	todos, err := u.repo.FindAll(ctx, entity.TodoFilter{})
+	if err != nil {
+		return nil, SyncState{}, err
+	}
+
+	state := SyncState{Count: len(todos)}
+	for _, todo := range todos {
+		if todo.UpdatedAt.After(state.UpdatedAt) {
+			state.UpdatedAt = todo.UpdatedAt
+		}
+	}
+	if since == nil {
+		return todos, state, nil
+	}
+
+	// Every todo of since was created by its latest update, so fewer such todos now means
+	// some were deleted. More means a write was in flight when since was taken.
+	existed := 0
+	changed := make([]*entity.Todo, 0)
+	for _, todo := range todos {
+		if !todo.CreatedAt.After(since.UpdatedAt) {
+			existed++
+		}
+		if todo.UpdatedAt.After(since.UpdatedAt) {
+			changed = append(changed, todo)
+		}
+	}
+	if existed != since.Count {
+		u.logger(ctx).Debug("Sync state expired",
+			zap.Int("count", since.Count),
+			zap.Int("existing", existed),
+		)
+		return nil, SyncState{}, ErrSyncStateExpired
+	}
+
+	span.SetAttributes(attribute.Int("todo.count", len(changed)))
+	return changed, state, nil
+}
@@ -1,67 +1,330 @@
 package todo
 
 import (
+	"context"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/entity"
 	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/repository"
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/tenant"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
 )
 
+// maxTodoDepth bounds the number of ancestors of a todo
+const maxTodoDepth = 32
+
+var (
+	// ErrQuotaExceeded is returned when a tenant already owns its maximum number of todos
+	ErrQuotaExceeded = repository.ErrQuotaExceeded
+	// ErrParentNotFound is returned when the parent of a todo does not exist
+	ErrParentNotFound = errors.New("parent todo not found")
+	// ErrParentCycle is returned when a parent would make a todo its own ancestor,
+	// or nest todos more than maxTodoDepth levels deep
+	ErrParentCycle = errors.New("parent would create a cycle")
+)
+
+// QuotaPolicy returns the maximum number of todos a tenant may own. Zero means unlimited.
+type QuotaPolicy func(tenantID tenant.ID) int
+
+// Logger returns the logger of the request bound to ctx, carrying its request-scoped fields
+type Logger func(ctx context.Context) *zap.Logger
+
+// EventPublisher is notified of the todos written successfully, such as an event bus
+type EventPublisher interface {
+	// Publish sends event to the subscribers of the tenant bound to ctx
+	Publish(ctx context.Context, event entity.TodoEvent)
+}
+
 // TodoUseCase handles the business logic for todo operations
 type TodoUseCase struct {
-	repo repository.TodoRepository
+	repo   repository.TodoRepository
+	quota  QuotaPolicy
+	events EventPublisher
+	log    Logger
+}
+
+// NewTodoUseCase creates a new TodoUseCase instance. Events are not published when events
+// is nil, and nothing is logged when log is nil.
+func NewTodoUseCase(repo repository.TodoRepository, quota QuotaPolicy, events EventPublisher, log Logger) *TodoUseCase {
+	return &TodoUseCase{repo: repo, quota: quota, events: events, log: log}
 }
 
-// NewTodoUseCase creates a new TodoUseCase instance
-func NewTodoUseCase(repo repository.TodoRepository) *TodoUseCase {
-	return &TodoUseCase{repo: repo}
+// logger returns the logger of the request bound to ctx
+func (u *TodoUseCase) logger(ctx context.Context) *zap.Logger {
+	if u.log == nil {
+		return zap.NewNop()
+	}
+	return u.log(ctx)
 }
 
 // CreateTodo creates a new todo item
-func (u *TodoUseCase) CreateTodo(input entity.TodoCreate) (*entity.Todo, error) {
-	now := time.Now()
-	todo := &entity.Todo{
-		ID:          uuid.New(),
-		Title:       input.Title,
-		Description: input.Description,
-		Completed:   false,
-		CreatedAt:   now,
-		UpdatedAt:   now,
+func (u *TodoUseCase) CreateTodo(ctx context.Context, input entity.TodoCreate) (_ *entity.Todo, err error) {
+	ctx, span := startSpan(ctx, "CreateTodo")
+	defer func() { endSpan(span, err) }()
+
+	quota, err := u.quotaFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.checkParent(ctx, uuid.Nil, input.ParentID); err != nil {
+		return nil, err
+	}
+
+	todo := newTodo(uuid.New(), input, time.Now())
+
+	span.SetAttributes(attribute.String("todo.id", todo.ID.String()))
+	u.logger(ctx).Debug("Creating todo", zap.String("id", todo.ID.String()))
+	created, err := u.repo.Create(ctx, todo, quota)
+	if err != nil {
+		return nil, err
 	}
-	return u.repo.Create(todo)
+	u.publish(ctx, entity.TodoCreated, created.ID, created)
+	return created, nil
+}
+
+// GetTodos retrieves the todo items selected by filter
+func (u *TodoUseCase) GetTodos(ctx context.Context, filter entity.TodoFilter) (_ []*entity.Todo, err error) {
+	ctx, span := startSpan(ctx, "GetTodos")
+	defer func() { endSpan(span, err) }()
+
+	return u.repo.FindAll(ctx, filter)
 }
 
-// GetTodos retrieves all todo items
-func (u *TodoUseCase) GetTodos() ([]*entity.Todo, error) {
-	return u.repo.FindAll()
+// ExportTodos calls fn with each page of the todo items selected by filter, so they can be
+// written out without holding every todo in memory
+func (u *TodoUseCase) ExportTodos(ctx context.Context, filter entity.TodoFilter, fn func(todos []*entity.Todo) error) (err error) {
+	ctx, span := startSpan(ctx, "ExportTodos")
+	defer func() { endSpan(span, err) }()
+
+	count := 0
+	err = u.repo.ForEachPage(ctx, filter, func(todos []*entity.Todo) error {
+		count += len(todos)
+		return fn(todos)
+	})
+	span.SetAttributes(attribute.Int("todo.count", count))
+	u.logger(ctx).Debug("Exported todos", zap.Int("count", count))
+	return err
 }
 
 // GetTodo retrieves a todo item by ID
-func (u *TodoUseCase) GetTodo(id uuid.UUID) (*entity.Todo, error) {
-	return u.repo.FindByID(id)
+func (u *TodoUseCase) GetTodo(ctx context.Context, id uuid.UUID) (_ *entity.Todo, err error) {
+	ctx, span := startSpan(ctx, "GetTodo", attribute.String("todo.id", id.String()))
+	defer func() { endSpan(span, err) }()
+
+	return u.repo.FindByID(ctx, id)
+}
+
+// GetTodosByIDs retrieves the todo items with the given IDs. Todos that do not exist are left out of the map.
+func (u *TodoUseCase) GetTodosByIDs(ctx context.Context, ids []uuid.UUID) (_ map[uuid.UUID]*entity.Todo, err error) {
+	ctx, span := startSpan(ctx, "GetTodosByIDs", attribute.Int("todo.count", len(ids)))
+	defer func() { endSpan(span, err) }()
+
+	return u.repo.FindByIDs(ctx, ids)
 }
 
 // UpdateTodo updates an existing todo item
-func (u *TodoUseCase) UpdateTodo(id uuid.UUID, input entity.TodoUpdate) (*entity.Todo, error) {
-	todo, err := u.repo.FindByID(id)
+func (u *TodoUseCase) UpdateTodo(ctx context.Context, id uuid.UUID, input entity.TodoUpdate) (_ *entity.Todo, err error) {
+	ctx, span := startSpan(ctx, "UpdateTodo", attribute.String("todo.id", id.String()))
+	defer func() { endSpan(span, err) }()
+
+	todo, err := u.repo.FindByID(ctx, id)
+	if err != nil || todo == nil {
+		return nil, err
+	}
+	if input.ParentID != nil {
+		if err := u.checkParent(ctx, id, *input.ParentID); err != nil {
+			return nil, err
+		}
+	}
+
+	applyUpdate(todo, input, time.Now())
+
+	u.logger(ctx).Debug("Updating todo", zap.String("id", id.String()))
+	updated, err := u.repo.Update(ctx, todo)
 	if err != nil {
 		return nil, err
 	}
+	u.publish(ctx, entity.TodoUpdated, id, updated)
+	return updated, nil
+}
+
+// ReplaceTodo creates a todo with a client supplied ID or fully replaces the existing one.
+// It reports whether the todo was created.
+func (u *TodoUseCase) ReplaceTodo(ctx context.Context, id uuid.UUID, input entity.TodoReplace) (_ *entity.Todo, _ bool, err error) {
+	ctx, span := startSpan(ctx, "ReplaceTodo", attribute.String("todo.id", id.String()))
+	defer func() { endSpan(span, err) }()
 
-	if input.Title != "" {
-		todo.Title = input.Title
+	quota, err := u.quotaFor(ctx)
+	if err != nil {
+		return nil, false, err
 	}
-	if input.Description != "" {
-		todo.Description = input.Description
+	if err := u.checkParent(ctx, id, input.ParentID); err != nil {
+		return nil, false, err
 	}
-	todo.Completed = input.Completed
-	todo.UpdatedAt = time.Now()
 
-	return u.repo.Update(todo)
+	todo := newTodo(id, entity.TodoCreate(input), time.Now())
+
+	replaced, created, err := u.repo.Replace(ctx, todo, quota)
+	if err != nil {
+		return nil, false, err
+	}
+	u.logger(ctx).Debug("Replaced todo", zap.String("id", id.String()), zap.Bool("created", created))
+	u.publishWrite(ctx, created, replaced)
+	return replaced, created, nil
 }
 
-// DeleteTodo deletes a todo item
-func (u *TodoUseCase) DeleteTodo(id uuid.UUID) error {
-	return u.repo.Delete(id)
+// DeleteTodo deletes a todo item. It returns ErrNotFound when the todo does not exist.
+func (u *TodoUseCase) DeleteTodo(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, span := startSpan(ctx, "DeleteTodo", attribute.String("todo.id", id.String()))
+	defer func() { endSpan(span, err) }()
+
+	u.logger(ctx).Debug("Deleting todo", zap.String("id", id.String()))
+	deleted, err := u.repo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNotFound
+	}
+	u.publish(ctx, entity.TodoDeleted, id, nil)
+	return nil
+}
+
+// publish notifies the event publisher of a successful write of the todo id, given as
+// written unless it was deleted
+func (u *TodoUseCase) publish(ctx context.Context, eventType entity.TodoEventType, id uuid.UUID, todo *entity.Todo) {
+	if u.events == nil {
+		return
+	}
+	event := entity.TodoEvent{Type: eventType, TodoID: id, OccurredAt: time.Now()}
+	if todo != nil {
+		// Subscribers share the event, so they get a copy the caller cannot change
+		written := *todo
+		event.Todo = &written
+	}
+	u.events.Publish(ctx, event)
+}
+
+// publishWrite notifies the event publisher that todo was created or updated
+func (u *TodoUseCase) publishWrite(ctx context.Context, created bool, todo *entity.Todo) {
+	eventType := entity.TodoUpdated
+	if created {
+		eventType = entity.TodoCreated
+	}
+	u.publish(ctx, eventType, todo.ID, todo)
+}
+
+// newTodo creates a todo from input, created and updated at now
+func newTodo(id uuid.UUID, input entity.TodoCreate, now time.Time) *entity.Todo {
+	return &entity.Todo{
+		ID:          id,
+		Title:       input.Title,
+		Description: input.Description,
+		Completed:   input.Completed,
+		ListID:      input.ListID,
+		ParentID:    input.ParentID,
+		Due:         input.Due,
+		Priority:    input.Priority,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// applyUpdate sets the fields of todo given in input
+func applyUpdate(todo *entity.Todo, input entity.TodoUpdate, now time.Time) {
+	if input.Title != nil {
+		todo.Title = *input.Title
+	}
+	if input.Description != nil {
+		todo.Description = *input.Description
+	}
+	if input.Completed != nil {
+		todo.Completed = *input.Completed
+	}
+	if input.ListID != nil {
+		todo.ListID = *input.ListID
+	}
+	if input.ParentID != nil {
+		todo.ParentID = *input.ParentID
+	}
+	if input.Due != nil {
+		todo.Due = *input.Due
+	}
+	if input.Priority != nil {
+		todo.Priority = *input.Priority
+	}
+	todo.UpdatedAt = now
+}
+
+// checkParent ensures parentID can be the parent of the todo id, which is uuid.Nil for a new todo.
+// The ancestors of the parent are walked so that a todo never becomes its own ancestor.
+func (u *TodoUseCase) checkParent(ctx context.Context, id, parentID uuid.UUID) error {
+	if parentID == uuid.Nil {
+		return nil
+	}
+
+	ancestor := parentID
+	for depth := 0; depth < maxTodoDepth; depth++ {
+		if ancestor == id {
+			return ErrParentCycle
+		}
+		todo, err := u.repo.FindByID(ctx, ancestor)
+		if err != nil {
+			return err
+		}
+		if todo == nil {
+			if ancestor == parentID {
+				return ErrParentNotFound
+			}
+			// The parent of an ancestor was deleted, which ends the chain
+			return nil
+		}
+		if todo.ParentID == uuid.Nil {
+			return nil
+		}
+		ancestor = todo.ParentID
+	}
+	return ErrParentCycle
+}
+
+// quotaFor returns the maximum number of todos the tenant bound to ctx may own, zero when
+// unlimited. The repository enforces it with the write creating todos.
+func (u *TodoUseCase) quotaFor(ctx context.Context) (int, error) {
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if u.quota == nil {
+		return 0, nil
+	}
+	return max(u.quota(tenantID), 0), nil
+}
+
+// remainingQuota estimates how many more todos the tenant bound to ctx can own, or -1 when
+// unlimited. It is only meant for dry runs, as the todos may change before they are written.
+func (u *TodoUseCase) remainingQuota(ctx context.Context) (int, error) {
+	limit, err := u.quotaFor(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if limit == 0 {
+		return -1, nil
+	}
+
+	count, err := u.repo.Count(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if count >= limit {
+		u.logger(ctx).Info("Todo quota exceeded",
+			zap.Int("count", count),
+			zap.Int("limit", limit),
+		)
+		return 0, nil
+	}
+	return limit - count, nil
 }
@@ -0,0 +1,35 @@
+package todo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gotokazuki/todo-golang-rest-api/app/todo/domain/tenant"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of use case methods
+var tracer = otel.Tracer("github.com/gotokazuki/todo-golang-rest-api/app/todo/usecase/todo")
+
+// startSpan starts the span of a TodoUseCase method, tagged with the tenant bound to ctx
+func startSpan(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if tenantID, err := tenant.FromContext(ctx); err == nil {
+		attrs = append(attrs, attribute.String("tenant.id", string(tenantID)))
+	}
+	return tracer.Start(ctx, "TodoUseCase."+method, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends span, marking it as failed when err is an unexpected error.
+// Business rule violations such as an exceeded quota are recorded as events only.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		if !errors.Is(err, ErrQuotaExceeded) {
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+	span.End()
+}